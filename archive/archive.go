@@ -0,0 +1,328 @@
+// archive package
+
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+// Archive Defaults
+const (
+	DefaultAfterHours      = 24
+	DefaultIntervalMinutes = 10
+	DefaultRetries         = 3
+)
+
+var retryDelay = 2 * time.Second
+
+// DefaultCategories are archived when none are configured
+var DefaultCategories = []string{monitor.CategoryRecordings, monitor.CategoryAlerts}
+
+// Archiver moves finished files older than a set age from the data directory to a target
+// and keeps a local index so they can still be listed and read
+type Archiver struct {
+	dataDir     string
+	after       time.Duration
+	categories  []string
+	interval    time.Duration
+	retries     int
+	deleteAfter time.Duration
+	limiter     *limiter
+	target      Target
+	index       *index
+	protected   func(category string) func(rel string) bool
+	cancel      chan bool
+	cancelOnce  sync.Once
+	done        chan bool
+}
+
+// NewArchiver creates an Archiver of the data directory.
+// protected returns the check of the protected files of a category, which stay local and are never deleted from the target.
+func NewArchiver(dataDir string, conf *Config, protected func(category string) func(rel string) bool) (*Archiver, error) {
+	target, err := newTarget(conf)
+	if err != nil {
+		return nil, err
+	}
+	return newArchiver(dataDir, conf, target, protected)
+}
+
+func newArchiver(dataDir string, conf *Config, target Target, protected func(category string) func(rel string) bool) (*Archiver, error) {
+	idx, err := newIndex(filepath.Join(dataDir, IndexFilename))
+	if err != nil {
+		target.Close()
+		return nil, err
+	}
+	a := &Archiver{
+		dataDir:    filepath.Clean(dataDir),
+		after:      DefaultAfterHours * time.Hour,
+		categories: DefaultCategories,
+		interval:   DefaultIntervalMinutes * time.Minute,
+		retries:    DefaultRetries,
+		limiter:    newLimiter(int64(conf.MaxKBps) * 1024),
+		target:     target,
+		index:      idx,
+		protected:  protected,
+		cancel:     make(chan bool),
+		done:       make(chan bool),
+	}
+	if conf.AfterHours > 0 {
+		a.after = time.Duration(conf.AfterHours) * time.Hour
+	}
+	if len(conf.Categories) > 0 {
+		a.categories = conf.Categories
+	}
+	if conf.IntervalMinutes > 0 {
+		a.interval = time.Duration(conf.IntervalMinutes) * time.Minute
+	}
+	if conf.Retries > 0 {
+		a.retries = conf.Retries
+	}
+	if conf.DeleteAfterDays > 0 {
+		a.deleteAfter = time.Duration(conf.DeleteAfterDays) * 24 * time.Hour
+	}
+	return a, nil
+}
+
+// Start archiving now and every interval
+func (a *Archiver) Start() {
+	go func() {
+		defer close(a.done)
+		tick := time.NewTicker(a.interval)
+		defer tick.Stop()
+		a.pass(time.Now())
+		for {
+			select {
+			case <-tick.C:
+				a.pass(time.Now())
+			case <-a.cancel:
+				return
+			}
+		}
+	}()
+}
+
+// Stop archiving
+func (a *Archiver) Stop() {
+	a.cancelOnce.Do(func() {
+		close(a.cancel)
+	})
+}
+
+// Wait until done and close the index and target
+func (a *Archiver) Wait() {
+	<-a.done
+	a.index.Close()
+	a.target.Close()
+}
+
+func (a *Archiver) stopped() bool {
+	select {
+	case <-a.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// List returns the archived files of the category
+func (a *Archiver) List(category string) []Entry {
+	return a.index.List(category, nil)
+}
+
+// Archived returns whether the file at the slash path relative to the category directory is archived
+func (a *Archiver) Archived(category string, rel string) bool {
+	return a.index.Get(category, rel) != nil
+}
+
+// Find returns the archived file at the slash path relative to the category directory,
+// or with its filename for paths from before the per-monitor layout, or nil when not archived
+func (a *Archiver) Find(category string, rel string) *Entry {
+	return a.index.Find(category, rel)
+}
+
+// Open reads the archived file at the slash path relative to the category directory from offset
+func (a *Archiver) Open(category string, rel string, offset int64) (io.ReadCloser, *Entry, error) {
+	entry := a.index.Find(category, rel)
+	if entry == nil {
+		return nil, nil, ErrNotArchived
+	}
+	r, err := a.target.Open(entry.Key, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, entry, nil
+}
+
+// pass archives the files older than the set age and deletes expired archived files
+func (a *Archiver) pass(now time.Time) {
+	archived := 0
+	for _, category := range a.categories {
+		for _, rel := range a.candidates(category, now) {
+			if a.stopped() {
+				return
+			}
+			if err := a.archiveWithRetry(category, rel); err != nil {
+				log.Warnln("Archive failed", category, rel, err)
+				continue
+			}
+			archived++
+		}
+	}
+	if archived > 0 {
+		log.Infoln("Archived", archived, "files")
+	}
+	if a.deleteAfter > 0 {
+		a.deleteExpired(now)
+	}
+}
+
+// candidates returns the files of the category ready to archive.
+// Sidecars stay local, as do files still in a monitor directory waiting to be filed by date.
+func (a *Archiver) candidates(category string, now time.Time) []string {
+	result := make([]string, 0)
+	isProtected := a.isProtected(category)
+	categoryDir := filepath.Join(a.dataDir, category)
+	filepath.WalkDir(categoryDir, func(fullPath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(categoryDir, fullPath)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.Count(rel, "/") == 1 || monitor.IsRecordingSidecar(rel) || strings.HasSuffix(rel, ".tmp") {
+			return nil
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil || now.Sub(info.ModTime()) < a.after {
+			return nil
+		}
+		if isProtected(rel) {
+			return nil
+		}
+		result = append(result, rel)
+		return nil
+	})
+	return result
+}
+
+// isProtected returns whether a file of the category is protected
+func (a *Archiver) isProtected(category string) func(rel string) bool {
+	if a.protected == nil {
+		return func(rel string) bool { return false }
+	}
+	return a.protected(category)
+}
+
+func (a *Archiver) archiveWithRetry(category string, rel string) (err error) {
+	for attempt := 0; attempt <= a.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(retryDelay << (attempt - 1)):
+			case <-a.cancel:
+				return err
+			}
+		}
+		if err = a.archive(category, rel); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// archive copies the file to the target, reads it back to verify the checksum,
+// records it in the index, and then removes the local file
+func (a *Archiver) archive(category string, rel string) error {
+	fullPath := filepath.Join(a.dataDir, category, filepath.FromSlash(rel))
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	key := category + "/" + rel
+	hasher := sha256.New()
+	err = a.target.Put(key, a.limiter.Reader(io.TeeReader(f, hasher)), info.Size())
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if err = a.verify(key, sum); err != nil {
+		a.target.Delete(key)
+		return err
+	}
+	entry := Entry{
+		Category:   category,
+		Path:       rel,
+		Key:        key,
+		Size:       info.Size(),
+		SHA256:     sum,
+		ModTime:    info.ModTime(),
+		ArchivedAt: time.Now(),
+	}
+	if err = a.index.Put(entry); err != nil {
+		return err
+	}
+	if current, statErr := os.Stat(fullPath); statErr != nil || !current.ModTime().Equal(info.ModTime()) || current.Size() != info.Size() {
+		// changed while archiving, so the next pass archives it again
+		a.index.Delete(category, rel)
+		return errors.New("changed while archiving")
+	}
+	return os.Remove(fullPath)
+}
+
+// verify reads the archived file back and compares its checksum
+func (a *Archiver) verify(key string, sum string) error {
+	r, err := a.target.Open(key, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, a.limiter.Reader(r)); err != nil {
+		return err
+	}
+	if archivedSum := hex.EncodeToString(hasher.Sum(nil)); archivedSum != sum {
+		return fmt.Errorf("%w: %s", ErrVerifyFailed, key)
+	}
+	return nil
+}
+
+// deleteExpired removes the archived files older than the delete age from the target and index
+func (a *Archiver) deleteExpired(now time.Time) {
+	deleted := 0
+	for _, category := range a.categories {
+		isProtected := a.isProtected(category)
+		expired := a.index.List(category, func(entry Entry) bool {
+			return now.Sub(entry.ModTime) > a.deleteAfter && !isProtected(entry.Path)
+		})
+		for _, entry := range expired {
+			if err := a.target.Delete(entry.Key); err != nil {
+				log.Warnln("Archive could not delete", entry.Key, err)
+				continue
+			}
+			a.index.Delete(entry.Category, entry.Path)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		log.Infoln("Archive deleted", deleted, "expired files")
+	}
+}
@@ -0,0 +1,180 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+// corruptTarget corrupts the first puts of a path target
+type corruptTarget struct {
+	*pathTarget
+	corrupt int
+	puts    int
+}
+
+func (c *corruptTarget) Put(key string, r io.Reader, size int64) error {
+	c.puts++
+	if c.corrupt > 0 {
+		c.corrupt--
+		io.Copy(io.Discard, r)
+		return c.pathTarget.Put(key, bytes.NewReader([]byte("corrupt")), 7)
+	}
+	return c.pathTarget.Put(key, r, size)
+}
+
+func TestArchivePass(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	data := []byte("recording data")
+	files := []struct {
+		category string
+		name     string
+		modTime  time.Time
+		archived bool
+	}{
+		{monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.mp4", now.Add(-48 * time.Hour), true},
+		{monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.json", now.Add(-48 * time.Hour), false},
+		{monitor.CategoryRecordings, "cam1/2024-01-02/cam1_b.mp4", now.Add(-time.Hour), false},
+		{monitor.CategoryRecordings, "cam1/cam1_c.mp4", now.Add(-48 * time.Hour), false},
+		{monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg", now.Add(-48 * time.Hour), false},
+	}
+	for _, cur := range files {
+		path := filepath.Join(dataDir, cur.category, filepath.FromSlash(cur.name))
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		os.WriteFile(path, data, 0644)
+		os.Chtimes(path, cur.modTime, cur.modTime)
+	}
+
+	protected := func(category string) func(rel string) bool {
+		return func(rel string) bool {
+			return category == monitor.CategoryAlerts && rel == "cam1/2024-01-01/cam1_a.jpg"
+		}
+	}
+	a, err := newArchiver(dataDir, &Config{}, newPathTarget(t.TempDir()), protected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.index.Close()
+	a.pass(now)
+
+	for _, cur := range files {
+		_, err := os.Stat(filepath.Join(dataDir, cur.category, filepath.FromSlash(cur.name)))
+		if local := err == nil; local == cur.archived {
+			t.Errorf("pass() %s local = %t, expected %t", cur.name, local, !cur.archived)
+		}
+	}
+	entries := a.List(monitor.CategoryRecordings)
+	if len(entries) != 1 || entries[0].Path != "cam1/2024-01-01/cam1_a.mp4" {
+		t.Fatalf("List() = %v, expected the old recording", entries)
+	}
+
+	r, entry, err := a.Open(monitor.CategoryRecordings, "cam1_a.mp4", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	read, _ := io.ReadAll(r)
+	if string(read) != "data" || entry.Size != int64(len(data)) {
+		t.Errorf("Open() = %q size %d, expected %q size %d", read, entry.Size, "data", len(data))
+	}
+	if _, _, err = a.Open(monitor.CategoryRecordings, "cam1_b.mp4", 0); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("Open() = %v, expected %v", err, ErrNotArchived)
+	}
+}
+
+func TestArchiveVerifyRetry(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = time.Millisecond
+	modTime := time.Now().Add(-48 * time.Hour)
+	dataDir := t.TempDir()
+	old := filepath.Join(dataDir, monitor.CategoryAlerts, "cam1", "2024-01-01", "cam1_a.jpg")
+	os.MkdirAll(filepath.Dir(old), os.ModePerm)
+	os.WriteFile(old, []byte("alert image"), 0644)
+	os.Chtimes(old, modTime, modTime)
+
+	target := &corruptTarget{pathTarget: newPathTarget(t.TempDir()), corrupt: 1}
+	a, err := newArchiver(dataDir, &Config{Retries: 1}, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.index.Close()
+	if err = a.archive(monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg"); !errors.Is(err, ErrVerifyFailed) {
+		t.Fatalf("archive() = %v, expected %v", err, ErrVerifyFailed)
+	}
+	if _, err = os.Stat(old); err != nil || len(a.List(monitor.CategoryAlerts)) != 0 {
+		t.Fatal("archive() expected the local file kept and nothing indexed")
+	}
+
+	target.corrupt = 1
+	target.puts = 0
+	if err = a.archiveWithRetry(monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg"); err != nil {
+		t.Fatalf("archiveWithRetry() = %v, expected nil", err)
+	}
+	if target.puts != 2 {
+		t.Errorf("archiveWithRetry() puts = %d, expected 2", target.puts)
+	}
+	if _, err = os.Stat(old); err == nil || len(a.List(monitor.CategoryAlerts)) != 1 {
+		t.Error("archiveWithRetry() expected the file archived")
+	}
+}
+
+func TestArchiveDeleteExpired(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	recordingDir := filepath.Join(dataDir, monitor.CategoryRecordings, "cam1")
+	files := map[string]time.Time{
+		"2024-01-01/cam1_a.mp4": now.Add(-10 * 24 * time.Hour),
+		"2024-01-05/cam1_b.mp4": now.Add(-2 * 24 * time.Hour),
+		"2024-01-02/cam1_c.mp4": now.Add(-3 * 24 * time.Hour),
+	}
+	for name, modTime := range files {
+		path := filepath.Join(recordingDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		os.WriteFile(path, []byte(name), 0644)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	a, err := newArchiver(dataDir, &Config{DeleteAfterDays: 7}, newPathTarget(t.TempDir()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.index.Close()
+	a.pass(now)
+	entries := a.List(monitor.CategoryRecordings)
+	if len(entries) != 2 {
+		t.Errorf("pass() = %v, expected the unexpired recordings", entries)
+	}
+
+	a.protected = func(category string) func(rel string) bool {
+		return func(rel string) bool {
+			return rel == "cam1/2024-01-02/cam1_c.mp4"
+		}
+	}
+	a.pass(now.Add(7 * 24 * time.Hour))
+	entries = a.List(monitor.CategoryRecordings)
+	if len(entries) != 1 || entries[0].Path != "cam1/2024-01-02/cam1_c.mp4" {
+		t.Errorf("pass() = %v, expected only the protected recording kept", entries)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1000)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, l.Reader(bytes.NewReader(make([]byte, 2500))))
+	if err != nil || n != 2500 {
+		t.Fatalf("Reader() = %d %v, expected 2500", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("Reader() took %v, expected at least 2s", elapsed)
+	}
+	if newLimiter(0) != nil {
+		t.Error("newLimiter(0) expected nil")
+	}
+}
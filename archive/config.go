@@ -0,0 +1,38 @@
+package archive
+
+// Config contains the parameters for the archive tier.
+// One of Path, SFTP, or S3 is the target.
+type Config struct {
+	AfterHours      int         `yaml:"afterHours,omitempty"`
+	Categories      []string    `yaml:"categories,omitempty"`
+	IntervalMinutes int         `yaml:"intervalMinutes,omitempty"`
+	Retries         int         `yaml:"retries,omitempty"`
+	MaxKBps         int         `yaml:"maxKBps,omitempty"`
+	DeleteAfterDays int         `yaml:"deleteAfterDays,omitempty"`
+	Path            string      `yaml:"path,omitempty"`
+	SFTP            *SFTPConfig `yaml:"sftp,omitempty"`
+	S3              *S3Config   `yaml:"s3,omitempty"`
+}
+
+// SFTPConfig contains the parameters for an SFTP target
+type SFTPConfig struct {
+	Host                  string `yaml:"host"`
+	Port                  int    `yaml:"port,omitempty"`
+	User                  string `yaml:"user"`
+	Password              string `yaml:"password,omitempty"`
+	KeyFile               string `yaml:"keyFile,omitempty"`
+	KnownHostsFile        string `yaml:"knownHostsFile,omitempty"`
+	InsecureIgnoreHostKey bool   `yaml:"insecureIgnoreHostKey,omitempty"`
+	Path                  string `yaml:"path,omitempty"`
+}
+
+// S3Config contains the parameters for an S3-compatible target
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region,omitempty"`
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	Prefix    string `yaml:"prefix,omitempty"`
+	UseSSL    bool   `yaml:"useSSL,omitempty"`
+}
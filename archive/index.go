@@ -0,0 +1,129 @@
+package archive
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// IndexFilename is the local index of archived files in the data directory
+const IndexFilename = "archive.db"
+
+var bucketArchived = []byte("archived")
+
+// Entry is an archived file, where Path is the slash path relative to its category directory
+type Entry struct {
+	Category   string
+	Path       string
+	Key        string
+	Size       int64
+	SHA256     string
+	ModTime    time.Time
+	ArchivedAt time.Time
+}
+
+// index stores the archived files keyed by category and path
+type index struct {
+	db *bolt.DB
+}
+
+func newIndex(dbPath string) (*index, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketArchived)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	i := &index{
+		db: db,
+	}
+	return i, nil
+}
+
+func (i *index) Close() {
+	if err := i.db.Close(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func entryKey(category string, rel string) []byte {
+	return []byte(category + "/" + rel)
+}
+
+func (i *index) Put(entry Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketArchived).Put(entryKey(entry.Category, entry.Path), value)
+	})
+}
+
+func (i *index) Get(category string, rel string) (result *Entry) {
+	i.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(bucketArchived).Get(entryKey(category, rel))
+		if value == nil {
+			return nil
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal(value, entry); err != nil {
+			log.Errorln(err)
+			return nil
+		}
+		result = entry
+		return nil
+	})
+	return
+}
+
+func (i *index) Delete(category string, rel string) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketArchived).Delete(entryKey(category, rel))
+	})
+}
+
+// List returns the entries of the category matching keep
+func (i *index) List(category string, keep func(entry Entry) bool) []Entry {
+	result := make([]Entry, 0)
+	prefix := entryKey(category, "")
+	i.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketArchived).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			entry := Entry{}
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			if keep == nil || keep(entry) {
+				result = append(result, entry)
+			}
+		}
+		return nil
+	})
+	return result
+}
+
+// Find returns the entry at the path, or else the entry with its filename for paths from before the per-monitor layout
+func (i *index) Find(category string, rel string) *Entry {
+	if entry := i.Get(category, rel); entry != nil {
+		return entry
+	}
+	base := path.Base(rel)
+	matched := i.List(category, func(entry Entry) bool {
+		return path.Base(entry.Path) == base
+	})
+	if len(matched) == 0 {
+		return nil
+	}
+	return &matched[0]
+}
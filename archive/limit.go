@@ -0,0 +1,64 @@
+package archive
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// limiter spaces the reads of all its readers to stay under a rate in bytes per second
+type limiter struct {
+	rate int64
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newLimiter(bytesPerSec int64) *limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &limiter{
+		rate: bytesPerSec,
+	}
+}
+
+// wait sleeps until the bytes read before n are within the rate
+func (l *limiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// Reader returns r limited by the rate
+func (l *limiter) Reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{
+		r: r,
+		l: l,
+	}
+}
+
+type limitedReader struct {
+	r io.Reader
+	l *limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	// read at most a second worth at a time to keep the spacing smooth
+	if int64(len(p)) > lr.l.rate {
+		p = p[:lr.l.rate]
+	}
+	lr.l.wait(len(p))
+	return lr.r.Read(p)
+}
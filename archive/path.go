@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// pathTarget stores files in a mounted directory
+type pathTarget struct {
+	root string
+}
+
+func newPathTarget(root string) *pathTarget {
+	return &pathTarget{
+		root: filepath.Clean(root),
+	}
+}
+
+func (p *pathTarget) path(key string) string {
+	return filepath.Join(p.root, filepath.FromSlash(key))
+}
+
+func (p *pathTarget) Put(key string, r io.Reader, size int64) error {
+	fullPath := p.path(key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+		return err
+	}
+	tmpPath := fullPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fullPath)
+}
+
+func (p *pathTarget) Open(key string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(p.path(key))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *pathTarget) Delete(key string) error {
+	err := os.Remove(p.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *pathTarget) Close() error {
+	return nil
+}
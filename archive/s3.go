@@ -0,0 +1,73 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Target stores files in a bucket of an S3-compatible service
+type s3Target struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Target(conf *S3Config) (*s3Target, error) {
+	if conf.Endpoint == "" || conf.Bucket == "" {
+		return nil, errors.New("s3 needs endpoint and bucket")
+	}
+	client, err := minio.New(conf.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(conf.AccessKey, conf.SecretKey, ""),
+		Secure: conf.UseSSL,
+		Region: conf.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &s3Target{
+		client: client,
+		bucket: conf.Bucket,
+		prefix: conf.Prefix,
+	}
+	return s, nil
+}
+
+func (s *s3Target) object(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return path.Join(s.prefix, key)
+}
+
+func (s *s3Target) Put(key string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.object(key), r, size,
+		minio.PutObjectOptions{ContentType: "application/octet-stream", SendContentMd5: true})
+	return err
+}
+
+func (s *s3Target) Open(key string, offset int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{}
+	if offset > 0 {
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := s.client.GetObject(context.Background(), s.bucket, s.object(key), opts)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *s3Target) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, s.object(key), minio.RemoveObjectOptions{})
+}
+
+func (s *s3Target) Close() error {
+	return nil
+}
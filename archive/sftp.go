@@ -0,0 +1,196 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	defaultSFTPPort = 22
+	sftpDialTimeout = 30 * time.Second
+)
+
+// sftpTarget stores files on an SFTP server, connecting again after a failure
+type sftpTarget struct {
+	addr      string
+	root      string
+	sshConfig *ssh.ClientConfig
+	mu        sync.Mutex
+	conn      *ssh.Client
+	client    *sftp.Client
+}
+
+func newSFTPTarget(conf *SFTPConfig) (*sftpTarget, error) {
+	if conf.Host == "" || conf.User == "" {
+		return nil, errors.New("sftp needs host and user")
+	}
+	auth := make([]ssh.AuthMethod, 0)
+	if conf.KeyFile != "" {
+		key, err := os.ReadFile(conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("sftp key file: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if conf.Password != "" {
+		auth = append(auth, ssh.Password(conf.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("sftp needs password or keyFile")
+	}
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case conf.KnownHostsFile != "":
+		callback, err := knownhosts.New(conf.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("sftp known hosts file: %w", err)
+		}
+		hostKeyCallback = callback
+	case conf.InsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, errors.New("sftp needs knownHostsFile or insecureIgnoreHostKey")
+	}
+	port := conf.Port
+	if port <= 0 {
+		port = defaultSFTPPort
+	}
+	s := &sftpTarget{
+		addr: net.JoinHostPort(conf.Host, strconv.Itoa(port)),
+		root: conf.Path,
+		sshConfig: &ssh.ClientConfig{
+			User:            conf.User,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sftpDialTimeout,
+		},
+	}
+	return s, nil
+}
+
+func (s *sftpTarget) path(key string) string {
+	if s.root == "" {
+		return key
+	}
+	return path.Join(s.root, key)
+}
+
+// connected returns the client, connecting when needed
+func (s *sftpTarget) connected() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	conn, err := ssh.Dial("tcp", s.addr, s.sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	s.client = client
+	return client, nil
+}
+
+// failed drops the connection after an error so the next call connects again
+func (s *sftpTarget) failed(client *sftp.Client, err error) error {
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == client {
+		s.closeLocked()
+	}
+	return err
+}
+
+func (s *sftpTarget) Put(key string, r io.Reader, size int64) error {
+	client, err := s.connected()
+	if err != nil {
+		return err
+	}
+	fullPath := s.path(key)
+	if err = client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return s.failed(client, err)
+	}
+	tmpPath := fullPath + ".tmp"
+	f, err := client.Create(tmpPath)
+	if err != nil {
+		return s.failed(client, err)
+	}
+	_, err = f.ReadFrom(r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		client.Remove(tmpPath)
+		return s.failed(client, err)
+	}
+	return s.failed(client, client.PosixRename(tmpPath, fullPath))
+}
+
+func (s *sftpTarget) Open(key string, offset int64) (io.ReadCloser, error) {
+	client, err := s.connected()
+	if err != nil {
+		return nil, err
+	}
+	f, err := client.Open(s.path(key))
+	if err != nil {
+		return nil, s.failed(client, err)
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, s.failed(client, err)
+	}
+	return f, nil
+}
+
+func (s *sftpTarget) Delete(key string) error {
+	client, err := s.connected()
+	if err != nil {
+		return err
+	}
+	err = client.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return s.failed(client, err)
+}
+
+func (s *sftpTarget) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+	return nil
+}
+
+func (s *sftpTarget) closeLocked() {
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
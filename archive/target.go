@@ -0,0 +1,51 @@
+package archive
+
+import (
+	"errors"
+	"io"
+)
+
+// Target stores archived files by slash separated key
+type Target interface {
+	// Put stores size bytes of r at key, replacing an existing file
+	Put(key string, r io.Reader, size int64) error
+	// Open reads the file at key from offset
+	Open(key string, offset int64) (io.ReadCloser, error)
+	// Delete removes the file at key
+	Delete(key string) error
+	// Close releases the connection
+	Close() error
+}
+
+// Target Errors
+var (
+	ErrNoTarget     = errors.New("archive needs one of path, sftp, or s3")
+	ErrManyTargets  = errors.New("archive has more than one of path, sftp, or s3")
+	ErrNotArchived  = errors.New("not archived")
+	ErrVerifyFailed = errors.New("archived checksum does not match")
+)
+
+// newTarget creates the configured target
+func newTarget(conf *Config) (Target, error) {
+	count := 0
+	if conf.Path != "" {
+		count++
+	}
+	if conf.SFTP != nil {
+		count++
+	}
+	if conf.S3 != nil {
+		count++
+	}
+	switch {
+	case count == 0:
+		return nil, ErrNoTarget
+	case count > 1:
+		return nil, ErrManyTargets
+	case conf.SFTP != nil:
+		return newSFTPTarget(conf.SFTP)
+	case conf.S3 != nil:
+		return newS3Target(conf.S3)
+	}
+	return newPathTarget(conf.Path), nil
+}
@@ -0,0 +1,228 @@
+// eventdb package
+
+package eventdb
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jonoton/scout/monitor"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventDB Constants
+const (
+	Filename = "events.db"
+
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+var bucketEvents = []byte("events")
+
+// Query contains the filters and paging for listing events
+type Query struct {
+	Monitor       string
+	Label         string
+	Zone          string
+	Start         time.Time
+	End           time.Time
+	MinConfidence int
+	Offset        int
+	Limit         int
+}
+
+// EventDB stores events in an embedded database keyed by event id
+type EventDB struct {
+	db *bolt.DB
+}
+
+// NewEventDB opens or creates the database at dbPath
+func NewEventDB(dbPath string) *EventDB {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.Errorf("Could not open event database %s: %v", dbPath, err)
+		return nil
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketEvents)
+		return err
+	})
+	if err != nil {
+		log.Errorf("Could not create event database buckets %s: %v", dbPath, err)
+		db.Close()
+		return nil
+	}
+	e := &EventDB{
+		db: db,
+	}
+	return e
+}
+
+// Close the database
+func (e *EventDB) Close() {
+	if err := e.db.Close(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// Put adds or replaces the event
+func (e *EventDB) Put(event monitor.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return e.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketEvents).Put([]byte(event.ID), value)
+	})
+}
+
+// Get returns the event or nil when not found
+func (e *EventDB) Get(id string) (result *monitor.Event) {
+	e.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(bucketEvents).Get([]byte(id))
+		if value == nil {
+			return nil
+		}
+		event := &monitor.Event{}
+		if err := json.Unmarshal(value, event); err != nil {
+			log.Errorln(err)
+			return nil
+		}
+		result = event
+		return nil
+	})
+	return
+}
+
+// Delete removes the event
+func (e *EventDB) Delete(id string) error {
+	return e.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketEvents).Delete([]byte(id))
+	})
+}
+
+// Retain walks all events and removes those keep returns false for, and stores those changed by keep.
+// Returns the number of events removed.
+func (e *EventDB) Retain(keep func(event *monitor.Event) (kept bool, changed bool)) (removed int, err error) {
+	err = e.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketEvents)
+		deletes := make([][]byte, 0)
+		updates := make(map[string][]byte)
+		err := bucket.ForEach(func(k, v []byte) error {
+			event := &monitor.Event{}
+			if err := json.Unmarshal(v, event); err != nil {
+				return nil
+			}
+			kept, changed := keep(event)
+			if !kept {
+				deletes = append(deletes, append([]byte(nil), k...))
+			} else if changed {
+				value, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				updates[string(k)] = value
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// the bucket cannot change while it is walked
+		for _, k := range deletes {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		for k, value := range updates {
+			if err := bucket.Put([]byte(k), value); err != nil {
+				return err
+			}
+		}
+		removed = len(deletes)
+		return nil
+	})
+	return
+}
+
+// Query returns a page of matching events newest first and the total number matched
+func (e *EventDB) Query(query Query) (result []monitor.Event, total int) {
+	result = make([]monitor.Event, 0)
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	e.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketEvents).Cursor()
+		// ids begin with the start time so the cursor walks newest first
+		var k, v []byte
+		if query.End.IsZero() {
+			k, v = c.Last()
+		} else {
+			k, v = c.Seek([]byte(monitor.EventIDPrefix(query.End.Add(time.Nanosecond))))
+			if k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+		startPrefix := ""
+		if !query.Start.IsZero() {
+			startPrefix = monitor.EventIDPrefix(query.Start)
+		}
+		for ; k != nil; k, v = c.Prev() {
+			if startPrefix != "" && string(k) < startPrefix {
+				break
+			}
+			event := monitor.Event{}
+			if err := json.Unmarshal(v, &event); err != nil {
+				continue
+			}
+			if !query.matches(event) {
+				continue
+			}
+			if total >= query.Offset && len(result) < limit {
+				result = append(result, event)
+			}
+			total++
+		}
+		return nil
+	})
+	return
+}
+
+func (q Query) matches(event monitor.Event) bool {
+	if q.Monitor != "" && event.Monitor != q.Monitor {
+		return false
+	}
+	if q.Zone != "" {
+		found := false
+		for _, zone := range event.Zones {
+			if strings.EqualFold(zone, q.Zone) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Label == "" && q.MinConfidence <= 0 {
+		return true
+	}
+	for _, label := range event.Labels {
+		if q.Label != "" && !strings.EqualFold(label.Label, q.Label) {
+			continue
+		}
+		if label.MaxConfidence >= q.MinConfidence {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package eventdb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+func TestQuery(t *testing.T) {
+	e := NewEventDB(filepath.Join(t.TempDir(), Filename))
+	if e == nil {
+		t.Fatal("NewEventDB() returned nil")
+	}
+	defer e.Close()
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []monitor.Event{
+		{ID: monitor.EventIDPrefix(base) + "a", Monitor: "cam1", StartTime: base,
+			Labels: []monitor.EventLabel{{Label: "person", MaxConfidence: 90}}},
+		{ID: monitor.EventIDPrefix(base.Add(time.Minute)) + "b", Monitor: "cam2", StartTime: base.Add(time.Minute),
+			Labels: []monitor.EventLabel{{Label: "car", MaxConfidence: 60}}, Zones: []string{"driveway"}},
+		{ID: monitor.EventIDPrefix(base.Add(2*time.Minute)) + "c", Monitor: "cam1", StartTime: base.Add(2 * time.Minute),
+			Labels: []monitor.EventLabel{{Label: "person", MaxConfidence: 50}}},
+	}
+	for _, event := range events {
+		if err := e.Put(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+		total    int
+	}{
+		{name: "all newest first", query: Query{}, expected: []string{"c", "b", "a"}, total: 3},
+		{name: "monitor", query: Query{Monitor: "cam1"}, expected: []string{"c", "a"}, total: 2},
+		{name: "label confidence", query: Query{Label: "person", MinConfidence: 80}, expected: []string{"a"}, total: 1},
+		{name: "zone", query: Query{Zone: "driveway"}, expected: []string{"b"}, total: 1},
+		{name: "time range", query: Query{Start: base.Add(time.Minute), End: base.Add(time.Minute)}, expected: []string{"b"}, total: 1},
+		{name: "paging", query: Query{Offset: 1, Limit: 1}, expected: []string{"b"}, total: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, total := e.Query(tt.query)
+			if total != tt.total {
+				t.Errorf("total = %d, expected %d", total, tt.total)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("len = %d, expected %d", len(result), len(tt.expected))
+			}
+			for i, event := range result {
+				if suffix := event.ID[len(event.ID)-1:]; suffix != tt.expected[i] {
+					t.Errorf("result[%d] = %s, expected %s", i, suffix, tt.expected[i])
+				}
+			}
+		})
+	}
+	if event := e.Get(events[1].ID); event == nil || event.Monitor != "cam2" {
+		t.Errorf("Get() = %v, expected cam2 event", event)
+	}
+}
+
+func TestRetain(t *testing.T) {
+	e := NewEventDB(filepath.Join(t.TempDir(), Filename))
+	if e == nil {
+		t.Fatal("NewEventDB() returned nil")
+	}
+	defer e.Close()
+	events := []monitor.Event{
+		{ID: "a", SnapshotPath: "gone.jpg"},
+		{ID: "b", SnapshotPath: "kept.jpg", RecordingPath: "gone.mp4"},
+		{ID: "c", SnapshotPath: "kept.jpg", RecordingPath: "kept.mp4"},
+	}
+	for _, event := range events {
+		if err := e.Put(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := e.Retain(func(event *monitor.Event) (bool, bool) {
+		if event.SnapshotPath == "gone.jpg" {
+			return false, false
+		}
+		if event.RecordingPath == "gone.mp4" {
+			event.RecordingPath = ""
+			return true, true
+		}
+		return true, false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("Retain() = %d, expected 1", removed)
+	}
+	if event := e.Get("a"); event != nil {
+		t.Errorf("Get(a) = %v, expected nil", event)
+	}
+	if event := e.Get("b"); event == nil || event.RecordingPath != "" {
+		t.Errorf("Get(b) = %v, expected the recording cleared", event)
+	}
+	if event := e.Get("c"); event == nil || event.RecordingPath != "kept.mp4" {
+		t.Errorf("Get(c) = %v, expected unchanged", event)
+	}
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/jonoton/scout/monitor"
+)
+
+// armHandler arms all monitors
+// @Summary Arm all monitors
+// @Description Arm detection, alerting, and recording for all monitors. The arm state persists across restarts.
+// @Tags Arm
+// @Security ApiKeyAuth
+// @Success 200 {string} string "OK"
+// @Failure 404 {string} string "Not Found"
+// @Router /arm [post]
+func (h *Http) armHandler(c *fiber.Ctx) error {
+	return h.setArmState(c, "", monitor.ArmState{Armed: true})
+}
+
+// armNameHandler arms a monitor
+// @Summary Arm monitor
+// @Description Arm detection, alerting, and recording for a specific monitor. The arm state persists across restarts.
+// @Tags Arm
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Success 200 {string} string "OK"
+// @Failure 404 {string} string "Not Found"
+// @Router /arm/{name} [post]
+func (h *Http) armNameHandler(c *fiber.Ctx) error {
+	return h.setArmState(c, c.Params("name"), monitor.ArmState{Armed: true})
+}
+
+// disarmHandler disarms all monitors
+// @Summary Disarm all monitors
+// @Description Disarm detection, alerting, and recording for all monitors while live view keeps working. The arm state persists across restarts.
+// @Tags Arm
+// @Security ApiKeyAuth
+// @Param continuous query bool false "Keep continuous recording while disarmed"
+// @Success 200 {string} string "OK"
+// @Failure 404 {string} string "Not Found"
+// @Router /disarm [post]
+func (h *Http) disarmHandler(c *fiber.Ctx) error {
+	return h.setArmState(c, "", monitor.ArmState{Armed: false, ContinuousWhenDisarmed: c.QueryBool("continuous")})
+}
+
+// disarmNameHandler disarms a monitor
+// @Summary Disarm monitor
+// @Description Disarm detection, alerting, and recording for a specific monitor while live view keeps working. The arm state persists across restarts.
+// @Tags Arm
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Param continuous query bool false "Keep continuous recording while disarmed"
+// @Success 200 {string} string "OK"
+// @Failure 404 {string} string "Not Found"
+// @Router /disarm/{name} [post]
+func (h *Http) disarmNameHandler(c *fiber.Ctx) error {
+	return h.setArmState(c, c.Params("name"), monitor.ArmState{Armed: false, ContinuousWhenDisarmed: c.QueryBool("continuous")})
+}
+
+func (h *Http) setArmState(c *fiber.Ctx, monitorName string, armState monitor.ArmState) error {
+	if !h.manage.SetMonitorArmState(monitorName, armState, 2000) {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/jonoton/go-notify"
+	"github.com/jonoton/scout/rtc"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 
@@ -20,6 +21,7 @@ type UserAuth struct {
 	User      string          `yaml:"user"`
 	Password  string          `yaml:"password"`
 	TwoFactor notify.RxConfig `yaml:"twoFactor"`
+	Admin     bool            `yaml:"admin,omitempty"`
 }
 
 type link struct {
@@ -31,16 +33,31 @@ type link struct {
 
 // Config contains the parameters for Http
 type Config struct {
-	Port                int        `yaml:"port,omitempty"`
-	LimitPerSecond      int        `yaml:"limitPerSecond,omitempty"`
-	LoginLimitPerSecond int        `yaml:"loginLimitPerSecond,omitempty"`
-	Users               []UserAuth `yaml:"users,omitempty"`
-	SignInExpireDays    int        `yaml:"signInExpireDays,omitempty"`
-	Links               []link     `yaml:"links,omitempty"`
-	LinkRetry           int        `yaml:"linkRetry,omitempty"`
-	TwoFactorTimeoutSec int        `yaml:"twoFactorTimeoutSec,omitempty"`
-	LoginSigningKey     string     `yaml:"loginSigningKey,omitempty"`
-	EnableSwagger       bool       `yaml:"enableSwagger,omitempty"`
+	Port                int         `yaml:"port,omitempty"`
+	LimitPerSecond      int         `yaml:"limitPerSecond,omitempty"`
+	LoginLimitPerSecond int         `yaml:"loginLimitPerSecond,omitempty"`
+	Users               []UserAuth  `yaml:"users,omitempty"`
+	SignInExpireDays    int         `yaml:"signInExpireDays,omitempty"`
+	Links               []link      `yaml:"links,omitempty"`
+	LinkRetry           int         `yaml:"linkRetry,omitempty"`
+	TwoFactorTimeoutSec int         `yaml:"twoFactorTimeoutSec,omitempty"`
+	LoginSigningKey     string      `yaml:"loginSigningKey,omitempty"`
+	EnableSwagger       bool        `yaml:"enableSwagger,omitempty"`
+	MaxStreams          int         `yaml:"maxStreams,omitempty"`
+	MaxStreamsPerUser   int         `yaml:"maxStreamsPerUser,omitempty"`
+	Hls                 *HlsConfig  `yaml:"hls,omitempty"`
+	WebRTC              *rtc.Config `yaml:"webrtc,omitempty"`
+}
+
+// HlsConfig contains the parameters for the HLS streams
+type HlsConfig struct {
+	SegmentSec int    `yaml:"segmentSec,omitempty"`
+	ListSize   int    `yaml:"listSize,omitempty"`
+	Fps        int    `yaml:"fps,omitempty"`
+	Width      int    `yaml:"width,omitempty"`
+	Codec      string `yaml:"codec,omitempty"`
+	Overlay    bool   `yaml:"overlay,omitempty"`
+	IdleSec    int    `yaml:"idleSec,omitempty"`
 }
 
 // NewConfig creates a new Config
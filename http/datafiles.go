@@ -0,0 +1,276 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/go-dir"
+	"github.com/jonoton/scout/archive"
+	"github.com/jonoton/scout/seal"
+)
+
+// dataFileSettle is how long a file is unchanged before it is listed
+const dataFileSettle = 5 * time.Second
+
+type dataFile struct {
+	name    string
+	modTime time.Time
+}
+
+// listDataFiles returns the paths relative to the category directory of the settled and archived files matching keep, newest first.
+// Files are in <monitor>/<date>/ directories, or in the category directory before the per-monitor layout.
+func (h *Http) listDataFiles(category string, keep func(name string) bool) []string {
+	categoryDir := filepath.Join(filepath.Clean(h.manage.GetDataDirectory()), category)
+	settled := time.Now().Add(-dataFileSettle)
+	files := make([]dataFile, 0)
+	filepath.WalkDir(categoryDir, func(fullPath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !keep(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(settled) {
+			return nil
+		}
+		rel, err := filepath.Rel(categoryDir, fullPath)
+		if err != nil {
+			return nil
+		}
+		files = append(files, dataFile{name: filepath.ToSlash(rel), modTime: info.ModTime()})
+		return nil
+	})
+	local := make(map[string]bool, len(files))
+	for _, cur := range files {
+		local[cur.name] = true
+	}
+	for _, entry := range h.manage.GetArchivedFiles(category) {
+		if !local[entry.Path] && keep(path.Base(entry.Path)) {
+			files = append(files, dataFile{name: entry.Path, modTime: entry.ModTime})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	result := make([]string, 0, len(files))
+	for _, cur := range files {
+		result = append(result, cur.name)
+	}
+	return result
+}
+
+// sortDataFiles sorts paths from several servers by the time in their filenames, newest first
+func sortDataFiles(data []string) {
+	sort.SliceStable(data, func(i, j int) bool {
+		return dir.DescendingTimeName{path.Base(data[i]), path.Base(data[j])}.Less(0, 1)
+	})
+}
+
+func isPortable(name string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(name, filepath.Ext(name)), "Portable")
+}
+
+// dataFileParam returns the requested path relative to the category directory
+func dataFileParam(c *fiber.Ctx, category string) string {
+	return strings.TrimPrefix(c.Path(), "/"+category+"/files/")
+}
+
+// resolveDataFile returns the path of the requested file and whether it was found.
+// Filenames from before the per-monitor layout are found in the monitor and daily directories.
+func (h *Http) resolveDataFile(category string, rel string) (string, bool) {
+	categoryDir := filepath.Join(filepath.Clean(h.manage.GetDataDirectory()), category)
+	cleanRel := filepath.Clean(string(filepath.Separator) + filepath.FromSlash(rel))
+	fullPath := filepath.Join(categoryDir, cleanRel)
+	if info, err := os.Stat(fullPath); err == nil && !info.IsDir() {
+		return fullPath, true
+	}
+	base := filepath.Base(cleanRel)
+	if base == string(filepath.Separator) || strings.ContainsAny(base, `*?[\`) {
+		return "", false
+	}
+	patterns := []string{
+		filepath.Join(categoryDir, base),
+		filepath.Join(categoryDir, "*", base),
+		filepath.Join(categoryDir, "*", "*", base),
+	}
+	for _, pattern := range patterns {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return matches[0], true
+		}
+	}
+	return "", false
+}
+
+// serveDataFile serves a local file at its requested path or where it moved, or else from the archive or the linked servers.
+// Sealed files are decrypted as they are served.
+func (h *Http) serveDataFile(c *fiber.Ctx, category string,
+	getLinkFile func(l *linkClient, filename string, numRetries int) (bool, []byte)) error {
+	rel := dataFileParam(c, category)
+	if fullPath, found := h.resolveDataFile(category, rel); found {
+		if seal.IsSealedFile(fullPath) {
+			return h.serveLocalFile(c, fullPath)
+		}
+		requested := filepath.Join(filepath.Clean(h.manage.GetDataDirectory()), category, filepath.FromSlash(rel))
+		if filepath.Clean(requested) == fullPath {
+			return c.Next()
+		}
+		return c.SendFile(fullPath)
+	}
+	if served, err := h.serveArchivedFile(c, category, rel); served {
+		return err
+	}
+	if getLinkFile != nil {
+		for _, cur := range h.linkClients {
+			found, linkResult := getLinkFile(cur, rel, h.linkRetry)
+			if found {
+				return c.Send(linkResult)
+			}
+		}
+	}
+	return c.Next()
+}
+
+// serveLocalFile serves a local file that needs decrypting
+func (h *Http) serveLocalFile(c *fiber.Ctx, fullPath string) error {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return h.serveContent(c, fullPath, f, info.Size(), f)
+}
+
+// serveArchivedFile serves an archived file and returns false when not archived
+func (h *Http) serveArchivedFile(c *fiber.Ctx, category string, rel string) (bool, error) {
+	r, entry, err := h.manage.OpenArchivedFile(category, rel, 0)
+	if errors.Is(err, archive.ErrNotArchived) {
+		return false, nil
+	}
+	if err != nil {
+		log.Warnln("Could not read archived file", category, rel, err)
+		return true, c.SendStatus(fiber.StatusBadGateway)
+	}
+	archived := &archivedReaderAt{
+		open: func(offset int64) (io.ReadCloser, error) {
+			r, _, err := h.manage.OpenArchivedFile(entry.Category, entry.Path, offset)
+			return r, err
+		},
+		r: r,
+	}
+	return true, h.serveContent(c, entry.Path, archived, entry.Size, archived)
+}
+
+// serveContent serves size bytes of r, decrypted when sealed, with a single byte range, and then closes closer
+func (h *Http) serveContent(c *fiber.Ctx, name string, r io.ReaderAt, size int64, closer io.Closer) error {
+	start := make([]byte, len(seal.Magic))
+	if n, _ := r.ReadAt(start, 0); seal.IsSealed(start[:n]) {
+		key := h.manage.GetEncryptionKey()
+		if key == nil {
+			closer.Close()
+			log.Warnln("Could not decrypt without an encryption key", name)
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		sealed, err := key.NewReader(r, size)
+		if err != nil {
+			closer.Close()
+			log.Warnln("Could not decrypt", name, err)
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		r = sealed
+		size = sealed.Size()
+	}
+	c.Type(strings.TrimPrefix(path.Ext(name), "."))
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	first, last, ranged := parseByteRange(c.Get(fiber.HeaderRange))
+	if !ranged {
+		return c.SendStream(readCloser{io.NewSectionReader(r, 0, size), closer}, int(size))
+	}
+	if last < 0 || last >= size {
+		last = size - 1
+	}
+	if first >= size || first > last {
+		closer.Close()
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+	length := last - first + 1
+	c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", first, last, size))
+	c.Status(fiber.StatusPartialContent)
+	return c.SendStream(readCloser{io.NewSectionReader(r, first, length), closer}, int(length))
+}
+
+// readCloser reads from a reader and closes the closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// archivedReaderAt reads an archived file at any offset, opening it again when a read does not follow the last
+type archivedReaderAt struct {
+	open func(offset int64) (io.ReadCloser, error)
+	r    io.ReadCloser
+	pos  int64
+}
+
+func (a *archivedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if a.r == nil || off != a.pos {
+		a.Close()
+		r, err := a.open(off)
+		if err != nil {
+			return 0, err
+		}
+		a.r = r
+		a.pos = off
+	}
+	n, err := io.ReadFull(a.r, p)
+	a.pos += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (a *archivedReaderAt) Close() error {
+	if a.r == nil {
+		return nil
+	}
+	err := a.r.Close()
+	a.r = nil
+	return err
+}
+
+// parseByteRange returns the start and end, where end is -1 when open, of a single range "bytes=start-end" or "bytes=start-"
+func parseByteRange(header string) (start int64, end int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, -1, false
+	}
+	first, last, found := strings.Cut(spec, "-")
+	if !found || first == "" {
+		return 0, -1, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, -1, false
+	}
+	end = -1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, -1, false
+		}
+	}
+	return start, end, true
+}
@@ -50,7 +50,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filename",
+                        "description": "Path from the list, or a filename",
                         "name": "name",
                         "in": "path",
                         "required": true
@@ -125,6 +125,71 @@ const docTemplate = `{
                 }
             }
         },
+        "/arm": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Arm detection, alerting, and recording for all monitors. The arm state persists across restarts.",
+                "tags": [
+                    "Arm"
+                ],
+                "summary": "Arm all monitors",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/arm/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Arm detection, alerting, and recording for a specific monitor. The arm state persists across restarts.",
+                "tags": [
+                    "Arm"
+                ],
+                "summary": "Arm monitor",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/continuous/files/{name}": {
             "get": {
                 "security": [
@@ -140,7 +205,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filename",
+                        "description": "Path from the list, or a filename",
                         "name": "name",
                         "in": "path",
                         "required": true
@@ -184,68 +249,54 @@ const docTemplate = `{
                 }
             }
         },
-        "/heartbeat": {
-            "get": {
+        "/disarm": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Check if the Scout server is responsive.",
+                "description": "Disarm detection, alerting, and recording for all monitors while live view keeps working. The arm state persists across restarts.",
                 "tags": [
-                    "System"
+                    "Arm"
+                ],
+                "summary": "Disarm all monitors",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Keep continuous recording while disarmed",
+                        "name": "continuous",
+                        "in": "query"
+                    }
                 ],
-                "summary": "System Heartbeat",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "string"
                         }
-                    }
-                }
-            }
-        },
-        "/info/list": {
-            "get": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Get a list of all available monitor names, including those from linked Scout instances.",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Info"
-                ],
-                "summary": "List Monitors",
-                "responses": {
-                    "200": {
-                        "description": "List of monitor names",
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/http.nameListResp"
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/info/{name}": {
-            "get": {
+        "/disarm/{name}": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get detailed information (FPS, etc.) for a specific monitor by name.",
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Disarm detection, alerting, and recording for a specific monitor while live view keeps working. The arm state persists across restarts.",
                 "tags": [
-                    "Info"
+                    "Arm"
                 ],
-                "summary": "Get Monitor Info",
+                "summary": "Disarm monitor",
                 "parameters": [
                     {
                         "type": "string",
@@ -253,17 +304,23 @@ const docTemplate = `{
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Keep continuous recording while disarmed",
+                        "name": "continuous",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Monitor information",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/http.monInfoResp"
+                            "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
@@ -271,48 +328,80 @@ const docTemplate = `{
                 }
             }
         },
-        "/live/{name}": {
+        "/events": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Real-time video stream via websocket.",
+                "description": "Get stored events for local monitors, newest first, filtered by monitor, label, zone, start time range, and min confidence.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "Monitor"
+                    "Events"
                 ],
-                "summary": "Live monitor websocket",
+                "summary": "List events",
                 "parameters": [
                     {
                         "type": "string",
                         "description": "Monitor Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "name": "monitor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Label (e.g. person, car, face)",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Zone Name, matching no events until zones can be configured",
+                        "name": "zone",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Events starting at or after this time (RFC3339)",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Events starting at or before this time (RFC3339)",
+                        "name": "end",
+                        "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Width",
-                        "name": "width",
+                        "description": "Min confidence percent of the label, or any label",
+                        "name": "minConfidence",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "JPEG Quality",
-                        "name": "quality",
+                        "description": "Number of matched events to skip",
+                        "name": "offset",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Auth Token",
-                        "name": "token",
+                        "type": "integer",
+                        "description": "Max events returned (default 50, max 500)",
+                        "name": "limit",
                         "in": "query"
                     }
                 ],
                 "responses": {
-                    "101": {
-                        "description": "Switching Protocols",
+                    "200": {
+                        "description": "Events page",
+                        "schema": {
+                            "$ref": "#/definitions/http.eventListResp"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
@@ -320,63 +409,39 @@ const docTemplate = `{
                 }
             }
         },
-        "/login": {
-            "post": {
-                "description": "Authenticate user and return a JWT token or two-factor options.",
-                "consumes": [
-                    "application/x-www-form-urlencoded"
+        "/events/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Get a stored event with the snapshot and recording clip urls.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Events"
                 ],
-                "summary": "Login to the system",
+                "summary": "Get event",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Username",
-                        "name": "a",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Password",
-                        "name": "b",
-                        "in": "formData",
+                        "description": "Event ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Two-factor option index",
-                        "name": "y",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Two-factor secret",
-                        "name": "z",
-                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success (returns JWT token 'c', timeout 't', or options 'o')",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
+                        "description": "Event",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.eventResp"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
@@ -384,98 +449,1070 @@ const docTemplate = `{
                 }
             }
         },
-        "/memory": {
+        "/heartbeat": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get current memory usage statistics for the application and system.",
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Check if the Scout server is responsive.",
                 "tags": [
                     "System"
                 ],
-                "summary": "Get Memory Usage",
+                "summary": "System Heartbeat",
                 "responses": {
                     "200": {
-                        "description": "Memory usage info in MB",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/recordings/files/{name}": {
+        "/hls/{name}/index.m3u8": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Retrieve a specific motion recording file.",
+                "description": "Rolling HLS playlist of a monitor. The stream starts on the first request and stops when no client requests it for the idle time. Each player counts as a live stream session. A player whose session was terminated is refused for 5 minutes.",
+                "produces": [
+                    "application/vnd.apple.mpegurl"
+                ],
                 "tags": [
-                    "Recordings"
+                    "Monitor"
                 ],
-                "summary": "Get recording file",
+                "summary": "Live monitor HLS playlist",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filename",
+                        "description": "Monitor Name",
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Auth Token",
+                        "name": "token",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Recording file",
+                        "description": "HLS playlist",
                         "schema": {
-                            "type": "file"
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Session terminated",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Stream not ready",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/recordings/list": {
+        "/hls/{name}/{segment}": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get a list of motion recordings, sorted descending by time.",
+                "description": "MPEG-TS segment listed in the HLS playlist of a monitor. Players whose session ended must request the playlist again.",
                 "produces": [
-                    "application/json"
+                    "video/mp2t"
                 ],
                 "tags": [
-                    "Recordings"
+                    "Monitor"
                 ],
-                "summary": "List recordings",
-                "responses": {
-                    "200": {
-                        "description": "List of recording filenames",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "string"
-                            }
-                        }
-                    }
-                }
+                "summary": "Live monitor HLS segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Segment Filename",
+                        "name": "segment",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Auth Token",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "TS segment",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/info/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a list of all available monitor names, including those from linked Scout instances.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Info"
+                ],
+                "summary": "List Monitors",
+                "responses": {
+                    "200": {
+                        "description": "List of monitor names",
+                        "schema": {
+                            "$ref": "#/definitions/http.nameListResp"
+                        }
+                    }
+                }
+            }
+        },
+        "/info/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get detailed information (FPS, arm state, stage and sink latency, queue depth, drops, adaptive analysis rate) for a specific monitor by name.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Info"
+                ],
+                "summary": "Get Monitor Info",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Monitor information",
+                        "schema": {
+                            "$ref": "#/definitions/http.monInfoResp"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/live/mosaic": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Real-time grid of the latest frame of each monitor via websocket, sending a binary JPEG per frame like protocol 1 of the live monitor websocket.\nStale or disconnected monitors show a placeholder tile.",
+                "tags": [
+                    "Monitor"
+                ],
+                "summary": "Live mosaic websocket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated monitor names (default all)",
+                        "name": "monitors",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Columns x rows, such as 3x3 (default fits the monitors)",
+                        "name": "layout",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Mosaic width (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "JPEG Quality",
+                        "name": "quality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Frames per second (default 5)",
+                        "name": "fps",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw the live overlay layers (default true)",
+                        "name": "overlay",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Gzip each frame for older clients (default true)",
+                        "name": "gzip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Auth Token",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/live/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Real-time video stream via websocket. Protocol 1 sends a binary JPEG per frame.\nProtocol 2 also sends a JSON frame message with the detections before each JPEG, a JSON state message on open and after each client message,\nand accepts JSON settings, pause, and resume messages from the client.",
+                "tags": [
+                    "Monitor"
+                ],
+                "summary": "Live monitor websocket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "JPEG Quality",
+                        "name": "quality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw the live overlay layers (default true)",
+                        "name": "overlay",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Gzip each frame for older clients (default true for protocol 1)",
+                        "name": "gzip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "FPS cap (protocol 2)",
+                        "name": "fps",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Protocol version (default 1)",
+                        "name": "protocol",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Auth Token",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Authenticate user and return a JWT token or two-factor options.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Login to the system",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "a",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password",
+                        "name": "b",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Two-factor option index",
+                        "name": "y",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Two-factor secret",
+                        "name": "z",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success (returns JWT token 'c', timeout 't', or options 'o')",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/memory": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get current memory usage statistics for the application and system.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "Get Memory Usage",
+                "responses": {
+                    "200": {
+                        "description": "Memory usage info in MB",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/mjpeg/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Real-time video stream as multipart/x-mixed-replace MJPEG. Slow clients only receive the newest frame.",
+                "produces": [
+                    "multipart/x-mixed-replace"
+                ],
+                "tags": [
+                    "Monitor"
+                ],
+                "summary": "Live monitor MJPEG",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "JPEG Quality",
+                        "name": "quality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw the live overlay layers (default true)",
+                        "name": "overlay",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Auth Token",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "MJPEG stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/mode": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the active mode and the modes configured in manage.yaml.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Mode"
+                ],
+                "summary": "Get mode",
+                "responses": {
+                    "200": {
+                        "description": "Active and configured modes",
+                        "schema": {
+                            "$ref": "#/definitions/manage.ModeInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/mode/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Switch all monitors to the detect, alert, record, and notify policies of the named mode. Every switch is recorded in the modes audit log.",
+                "tags": [
+                    "Mode"
+                ],
+                "summary": "Switch mode",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Mode Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/protect": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the files kept from pruning by category, as paths from the category list.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Storage"
+                ],
+                "summary": "List protected files",
+                "responses": {
+                    "200": {
+                        "description": "Protected paths by category",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/protect/{category}/{path}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Keep a file from being pruned by age or space. Protecting a recording keeps its sidecars and protecting any image of an alert set keeps the set. The protection persists across restarts.",
+                "tags": [
+                    "Storage"
+                ],
+                "summary": "Protect file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "continuous, recordings, events, or alerts",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Path from the list, or a filename",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Let a protected file be pruned again by age or space.",
+                "tags": [
+                    "Storage"
+                ],
+                "summary": "Unprotect file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "continuous, recordings, events, or alerts",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Protected path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/recordings/files/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retrieve a specific motion recording file.",
+                "tags": [
+                    "Recordings"
+                ],
+                "summary": "Get recording file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path from the list, or a filename",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recording file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/recordings/list": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a list of motion recordings, sorted descending by time. With summary the detection summary of each recording's sidecar is included.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Recordings"
+                ],
+                "summary": "List recordings",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include detection summaries",
+                        "name": "summary",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of recording filenames, or recordingSummaryResp objects with summary",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/live": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the active live websocket, mosaic, MJPEG, WebRTC, and HLS streams, oldest first. Admins see all streams and other users see their own.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "List live streams",
+                "responses": {
+                    "200": {
+                        "description": "Active streams",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.streamSessionResp"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/live/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "End an active live stream. Requires an admin user.",
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Terminate live stream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stream ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Terminated",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/snapshot/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a JPEG of the most recent frame of a monitor, including monitors of linked Scout instances.",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "Monitor"
+                ],
+                "summary": "Monitor snapshot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "JPEG Quality",
+                        "name": "quality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw the live overlay layers",
+                        "name": "highlight",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Auth Token",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JPEG image",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "No frame yet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/webrtc/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Answer a WebRTC offer with a H.264 video track of a monitor. Clients should fall back to the live websocket when this fails or the connection is not established.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Monitor"
+                ],
+                "summary": "Live monitor WebRTC",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Monitor Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw the live overlay layers (default true)",
+                        "name": "overlay",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Session description offer",
+                        "name": "offer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Session description answer",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found or WebRTC disabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Negotiation failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
             }
         }
     },
     "definitions": {
+        "http.eventListResp": {
+            "type": "object",
+            "properties": {
+                "Events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.eventResp"
+                    }
+                },
+                "Limit": {
+                    "type": "integer"
+                },
+                "Offset": {
+                    "type": "integer"
+                },
+                "Total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.eventResp": {
+            "type": "object",
+            "properties": {
+                "EndTime": {
+                    "type": "string"
+                },
+                "ID": {
+                    "type": "string"
+                },
+                "Labels": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/monitor.EventLabel"
+                    }
+                },
+                "Monitor": {
+                    "type": "string"
+                },
+                "Recording": {
+                    "type": "string"
+                },
+                "Snapshot": {
+                    "type": "string"
+                },
+                "StartTime": {
+                    "type": "string"
+                },
+                "State": {
+                    "type": "string"
+                },
+                "Zones": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "http.monInfoResp": {
             "type": "object",
             "properties": {
+                "Adaptive": {
+                    "$ref": "#/definitions/monitor.AdaptiveStats"
+                },
+                "Armed": {
+                    "type": "boolean"
+                },
+                "ContinuousWhenDisarmed": {
+                    "type": "boolean"
+                },
                 "Name": {
                     "type": "string"
                 },
@@ -484,6 +1521,18 @@ const docTemplate = `{
                 },
                 "ReaderOutFps": {
                     "type": "integer"
+                },
+                "Sinks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/monitor.ProcessStats"
+                    }
+                },
+                "Stages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/monitor.ProcessStats"
+                    }
                 }
             }
         },
@@ -497,6 +1546,120 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "http.streamSessionResp": {
+            "type": "object",
+            "properties": {
+                "BytesSent": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "ClientIP": {
+                    "type": "string"
+                },
+                "ID": {
+                    "type": "string"
+                },
+                "Kind": {
+                    "type": "string"
+                },
+                "Monitor": {
+                    "type": "string"
+                },
+                "Start": {
+                    "type": "string"
+                },
+                "User": {
+                    "type": "string"
+                }
+            }
+        },
+        "manage.ModeInfo": {
+            "type": "object",
+            "properties": {
+                "Active": {
+                    "type": "string"
+                },
+                "Modes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "monitor.AdaptiveStats": {
+            "type": "object",
+            "properties": {
+                "LastChange": {
+                    "type": "string"
+                },
+                "LastReason": {
+                    "type": "string"
+                },
+                "MaxFps": {
+                    "type": "integer"
+                },
+                "MinFps": {
+                    "type": "integer"
+                },
+                "TargetFps": {
+                    "type": "integer"
+                }
+            }
+        },
+        "monitor.EventLabel": {
+            "type": "object",
+            "properties": {
+                "Label": {
+                    "type": "string"
+                },
+                "MaxConfidence": {
+                    "type": "integer"
+                }
+            }
+        },
+        "monitor.ProcessStats": {
+            "type": "object",
+            "properties": {
+                "DroppedTotal": {
+                    "type": "integer"
+                },
+                "InTotal": {
+                    "type": "integer"
+                },
+                "LatencyMaxMs": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "LatencyP50Ms": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "LatencyP90Ms": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "LatencyP99Ms": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "MaxQueueDepth": {
+                    "type": "integer"
+                },
+                "Name": {
+                    "type": "string"
+                },
+                "OutTotal": {
+                    "type": "integer"
+                },
+                "QueueDepth": {
+                    "type": "integer"
+                },
+                "SkippedTotal": {
+                    "type": "integer"
+                }
+            }
         }
     },
     "securityDefinitions": {
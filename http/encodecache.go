@@ -0,0 +1,98 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jonoton/go-gzip"
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/monitor"
+)
+
+// encodeCacheExpire is how long an unused encoding is kept
+const encodeCacheExpire = 10 * time.Second
+
+type encodeKey struct {
+	monitorName string
+	width       int
+	quality     int
+	overlay     bool
+}
+
+// encodedFrame holds the newest frame encoded for one key
+type encodedFrame struct {
+	mu       sync.Mutex
+	created  time.Time
+	jpeg     []byte
+	zipped   []byte
+	lastUsed time.Time
+}
+
+// encodeCache encodes each live frame once per width, quality, and overlay and shares the bytes with all viewers
+type encodeCache struct {
+	mu     sync.Mutex
+	frames map[encodeKey]*encodedFrame
+}
+
+func newEncodeCache() *encodeCache {
+	e := &encodeCache{
+		frames: make(map[encodeKey]*encodedFrame),
+	}
+	return e
+}
+
+// entry returns the cached frame of the key and removes expired entries
+func (e *encodeCache) entry(key encodeKey) *encodedFrame {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for curKey, cur := range e.frames {
+		cur.mu.Lock()
+		expired := now.Sub(cur.lastUsed) > encodeCacheExpire
+		cur.mu.Unlock()
+		if expired && curKey != key {
+			delete(e.frames, curKey)
+		}
+	}
+	cur, found := e.frames[key]
+	if !found {
+		cur = &encodedFrame{}
+		e.frames[key] = cur
+	}
+	return cur
+}
+
+// Encode returns the live JPEG of the image or nil when empty and cleans up the image.
+// Viewers of the same frame and key wait for the first encode and share its bytes, which must not be modified.
+func (e *encodeCache) Encode(key encodeKey, img *videosource.ProcessedImage, overlay *monitor.Overlay) []byte {
+	jpeg, _ := e.encode(key, img, overlay, false)
+	return jpeg
+}
+
+// EncodeGzip returns the gzipped live JPEG of the image or nil when empty and cleans up the image
+func (e *encodeCache) EncodeGzip(key encodeKey, img *videosource.ProcessedImage, overlay *monitor.Overlay) []byte {
+	_, zipped := e.encode(key, img, overlay, true)
+	return zipped
+}
+
+func (e *encodeCache) encode(key encodeKey, img *videosource.ProcessedImage, overlay *monitor.Overlay,
+	needZipped bool) (jpeg []byte, zipped []byte) {
+	defer img.Cleanup()
+	if !img.Original.IsFilled() {
+		return nil, nil
+	}
+	created := img.Original.CreatedTime()
+	cur := e.entry(key)
+	cur.mu.Lock()
+	defer cur.mu.Unlock()
+	cur.lastUsed = time.Now()
+	if !cur.created.Equal(created) || cur.jpeg == nil {
+		cur.created = created
+		cur.jpeg = encodeLive(img.Ref(), overlay, key.width, key.quality, key.overlay)
+		cur.zipped = nil
+	}
+	if needZipped && cur.zipped == nil && cur.jpeg != nil {
+		cur.zipped = gzip.Encode(cur.jpeg, nil)
+	}
+	return cur.jpeg, cur.zipped
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/jonoton/scout/eventdb"
+	"github.com/jonoton/scout/monitor"
+)
+
+type eventResp struct {
+	ID        string
+	Monitor   string
+	State     string
+	StartTime time.Time
+	EndTime   time.Time
+	Labels    []monitor.EventLabel
+	Zones     []string
+	Snapshot  string
+	Recording string
+}
+
+type eventListResp struct {
+	Total  int
+	Offset int
+	Limit  int
+	Events []eventResp
+}
+
+func (h *Http) newEventResp(event monitor.Event) eventResp {
+	return eventResp{
+		ID:        event.ID,
+		Monitor:   event.Monitor,
+		State:     event.State,
+		StartTime: event.StartTime,
+		EndTime:   event.EndTime,
+		Labels:    event.Labels,
+		Zones:     event.Zones,
+		Snapshot:  h.dataFileURL(event.SnapshotPath),
+		Recording: h.dataFileURL(event.RecordingPath),
+	}
+}
+
+// dataFileURL returns the files url for a path in the data directory or empty if not served.
+// A file moved since the path was stored, such as a recording filed by date or archived after the event, gets the url of where it is now.
+func (h *Http) dataFileURL(fullPath string) string {
+	if fullPath == "" {
+		return ""
+	}
+	dataDir := filepath.Clean(h.manage.GetDataDirectory())
+	rel, err := filepath.Rel(dataDir, filepath.Clean(fullPath))
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 2 || parts[0] == ".." {
+		return ""
+	}
+	category := parts[0]
+	fileRel := strings.Join(parts[1:], "/")
+	if current, found := h.resolveDataFile(category, fileRel); found {
+		if currentRel, err := filepath.Rel(filepath.Join(dataDir, category), current); err == nil {
+			fileRel = filepath.ToSlash(currentRel)
+		}
+	} else if entry := h.manage.FindArchivedFile(category, fileRel); entry != nil {
+		fileRel = entry.Path
+	}
+	return "/" + category + "/files/" + fileRel
+}
+
+// eventsFilesHandler serves event files at their stored path or where they moved
+func (h *Http) eventsFilesHandler(c *fiber.Ctx) error {
+	return h.serveDataFile(c, monitor.CategoryEvents, nil)
+}
+
+// eventsHandler returns the stored events matching the filters
+// @Summary List events
+// @Description Get stored events for local monitors, newest first, filtered by monitor, label, zone, start time range, and min confidence.
+// @Tags Events
+// @Produce json
+// @Security ApiKeyAuth
+// @Param monitor query string false "Monitor Name"
+// @Param label query string false "Label (e.g. person, car, face)"
+// @Param zone query string false "Zone Name, matching no events until zones can be configured"
+// @Param start query string false "Events starting at or after this time (RFC3339)"
+// @Param end query string false "Events starting at or before this time (RFC3339)"
+// @Param minConfidence query int false "Min confidence percent of the label, or any label"
+// @Param offset query int false "Number of matched events to skip"
+// @Param limit query int false "Max events returned (default 50, max 500)"
+// @Success 200 {object} eventListResp "Events page"
+// @Failure 400 {string} string "Bad Request"
+// @Router /events [get]
+func (h *Http) eventsHandler(c *fiber.Ctx) error {
+	query := eventdb.Query{
+		Monitor: c.Query("monitor"),
+		Label:   c.Query("label"),
+		Zone:    c.Query("zone"),
+	}
+	var err error
+	if start := c.Query("start"); start != "" {
+		if query.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid start")
+		}
+	}
+	if end := c.Query("end"); end != "" {
+		if query.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid end")
+		}
+	}
+	if query.MinConfidence, err = queryInt(c, "minConfidence", 0); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid minConfidence")
+	}
+	if query.Offset, err = queryInt(c, "offset", 0); err != nil || query.Offset < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid offset")
+	}
+	if query.Limit, err = queryInt(c, "limit", eventdb.DefaultLimit); err != nil || query.Limit <= 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid limit")
+	}
+	if query.Limit > eventdb.MaxLimit {
+		query.Limit = eventdb.MaxLimit
+	}
+	events, total := h.manage.QueryEvents(query)
+	data := eventListResp{
+		Total:  total,
+		Offset: query.Offset,
+		Limit:  query.Limit,
+		Events: make([]eventResp, 0),
+	}
+	for _, event := range events {
+		data.Events = append(data.Events, h.newEventResp(event))
+	}
+	return c.JSON(data)
+}
+
+// eventHandler returns a stored event
+// @Summary Get event
+// @Description Get a stored event with the snapshot and recording clip urls.
+// @Tags Events
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Event ID"
+// @Success 200 {object} eventResp "Event"
+// @Failure 404 {string} string "Not Found"
+// @Router /events/{id} [get]
+func (h *Http) eventHandler(c *fiber.Ctx) error {
+	event := h.manage.GetEvent(c.Params("id"))
+	if event == nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.JSON(h.newEventResp(*event))
+}
+
+func queryInt(c *fiber.Ctx, key string, defaultValue int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,629 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/monitor"
+)
+
+const (
+	hlsPlaylistName = "index.m3u8"
+	streamKindHLS   = "hls"
+	// players of a terminated session are refused for the cooldown so they do not rejoin right away
+	hlsTerminateCooldown = 5 * time.Minute
+)
+
+var hlsSegmentRegex = regexp.MustCompile(`^segment\d+\.ts$`)
+
+// HLS Errors
+var (
+	errHlsStopped    = errors.New("stream stopped")
+	errHlsTerminated = errors.New("stream terminated")
+)
+
+// newHlsSettings returns the config with defaults for unset values
+func newHlsSettings(conf *HlsConfig) HlsConfig {
+	s := HlsConfig{
+		SegmentSec: 2,
+		ListSize:   5,
+		Fps:        10,
+		Width:      640,
+		Codec:      "avc1",
+		Overlay:    false,
+		IdleSec:    30,
+	}
+	if conf != nil {
+		if conf.SegmentSec > 0 {
+			s.SegmentSec = conf.SegmentSec
+		}
+		if conf.ListSize > 0 {
+			s.ListSize = conf.ListSize
+		}
+		if conf.Fps > 0 {
+			s.Fps = conf.Fps
+		}
+		if conf.Width > 0 {
+			s.Width = conf.Width
+		}
+		if len(conf.Codec) == 4 {
+			s.Codec = conf.Codec
+		}
+		s.Overlay = conf.Overlay
+		if conf.IdleSec > 0 {
+			s.IdleSec = conf.IdleSec
+		}
+	}
+	return s
+}
+
+type hlsSegment struct {
+	name     string
+	duration float64
+}
+
+// hlsClient is the stream session of one player of a shared HLS stream
+type hlsClient struct {
+	session    *streamSession
+	lastAccess time.Time
+}
+
+// hlsStream encodes the frames of a monitor into a rolling list of TS segments
+type hlsStream struct {
+	name      string
+	dir       string
+	settings  HlsConfig
+	streams   *streamRegistry
+	terminate func(key string)
+	mu        sync.Mutex
+	segments  []hlsSegment
+	sequence  int
+	clients   map[string]*hlsClient
+	closed    bool
+	ready     chan struct{}
+	readyOnce sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// newHlsStream creates a stream where terminate is called with the key of each player whose session is terminated
+func newHlsStream(name string, dir string, settings HlsConfig, streams *streamRegistry, terminate func(key string)) *hlsStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &hlsStream{
+		name:      name,
+		dir:       dir,
+		settings:  settings,
+		streams:   streams,
+		terminate: terminate,
+		segments:  make([]hlsSegment, 0),
+		sequence:  0,
+		clients:   make(map[string]*hlsClient),
+		ready:     make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	return s
+}
+
+// join returns the session of the player, registering a new one within the stream caps
+func (s *hlsStream) join(key string, user string, clientIP string) (*streamSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errHlsStopped
+	}
+	if client, found := s.clients[key]; found {
+		client.lastAccess = time.Now()
+		return client.session, nil
+	}
+	session, err := s.streams.Open(streamKindHLS, user, s.name, clientIP, func() {
+		s.leave(key)
+		s.terminate(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.clients[key] = &hlsClient{session: session, lastAccess: time.Now()}
+	return session, nil
+}
+
+// session returns the session of a joined player or nil when not joined
+func (s *hlsStream) session(key string) *streamSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client, found := s.clients[key]
+	if !found {
+		return nil
+	}
+	client.lastAccess = time.Now()
+	return client.session
+}
+
+// leave ends the session of the player
+func (s *hlsStream) leave(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client, found := s.clients[key]; found {
+		s.streams.Close(client.session)
+		delete(s.clients, key)
+	}
+}
+
+// idle ends the sessions of players without requests for the idle time and returns true when none are left
+func (s *hlsStream) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, client := range s.clients {
+		if time.Since(client.lastAccess) > time.Duration(s.settings.IdleSec)*time.Second {
+			s.streams.Close(client.session)
+			delete(s.clients, key)
+		}
+	}
+	return len(s.clients) == 0
+}
+
+// closeClients ends the sessions of all players and refuses new ones
+func (s *hlsStream) closeClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for key, client := range s.clients {
+		s.streams.Close(client.session)
+		delete(s.clients, key)
+	}
+}
+
+// waitReady returns true once the first segment is written
+func (s *hlsStream) waitReady(timeout time.Duration) bool {
+	select {
+	case <-s.ready:
+		return true
+	case <-s.ctx.Done():
+		return false
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// playlist returns the rolling playlist with the query string appended to each segment.
+// Each segment is encoded by its own writer with timestamps starting over, so each is marked as a discontinuity.
+func (s *hlsStream) playlist(queryString string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	listed := s.segments
+	if len(listed) > s.settings.ListSize {
+		listed = listed[len(listed)-s.settings.ListSize:]
+	}
+	targetDuration := s.settings.SegmentSec
+	for _, cur := range listed {
+		targetDuration = max(targetDuration, int(math.Ceil(cur.duration)))
+	}
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", targetDuration)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", s.sequence-len(listed))
+	// one discontinuity left the list with each segment
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", s.sequence-len(listed))
+	for _, cur := range listed {
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY\n#EXTINF:%.3f,\n%s\n", cur.duration, hlsSegmentURI(cur.name, queryString))
+	}
+	return b.String()
+}
+
+func (s *hlsStream) segmentPath(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// addSegment lists the finished segment and removes segments no longer listed.
+// One segment more than the list size is kept on disk for clients with an older playlist.
+func (s *hlsStream) addSegment(name string, duration float64) {
+	s.mu.Lock()
+	s.segments = append(s.segments, hlsSegment{name: name, duration: duration})
+	s.sequence++
+	for len(s.segments) > s.settings.ListSize+1 {
+		os.Remove(s.segmentPath(s.segments[0].name))
+		s.segments = s.segments[1:]
+	}
+	s.mu.Unlock()
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+}
+
+// run encodes the newest frame at the configured rate until idle or the subscription ends
+func (s *hlsStream) run(source *latestSource, tracker *monitor.ProcessTracker, overlay *monitor.Overlay) {
+	log.Infoln("HLS started", s.name)
+	var current *videosource.ProcessedImage
+	var writer *gocv.VideoWriter
+	var writerName string
+	var writerSize image.Point
+	frames := 0
+	finishSegment := func() {
+		if writer == nil {
+			return
+		}
+		writer.Close()
+		writer = nil
+		if frames > 0 {
+			s.addSegment(writerName, float64(frames)/float64(s.settings.Fps))
+		} else {
+			os.Remove(s.segmentPath(writerName))
+		}
+		frames = 0
+	}
+	defer func() {
+		if current != nil {
+			current.Cleanup()
+		}
+		if writer != nil {
+			writer.Close()
+		}
+		source.Close()
+		s.closeClients()
+		os.RemoveAll(s.dir)
+		log.Infoln("HLS stopped", s.name)
+	}()
+
+	frameTick := time.NewTicker(time.Second / time.Duration(s.settings.Fps))
+	defer frameTick.Stop()
+	idleTick := time.NewTicker(time.Second)
+	defer idleTick.Stop()
+	ringBufferChan := source.Chan()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-source.Done():
+			return
+		case <-idleTick.C:
+			if s.idle() {
+				return
+			}
+		case img, ok := <-ringBufferChan:
+			if !ok {
+				return
+			}
+			source.Taken()
+			if current != nil {
+				current.Cleanup()
+			}
+			current = img
+		case <-frameTick.C:
+			if current == nil || !current.Original.IsFilled() {
+				continue
+			}
+			start := time.Now()
+			frame := renderVideoFrame(current, overlay, s.settings.Overlay, s.settings.Width)
+			size := image.Pt(frame.Width(), frame.Height())
+			if writer != nil && size != writerSize {
+				finishSegment()
+			}
+			if writer == nil {
+				writerName = fmt.Sprintf("segment%d.ts", s.sequence)
+				var err error
+				writer, err = gocv.VideoWriterFile(s.segmentPath(writerName), s.settings.Codec,
+					float64(s.settings.Fps), size.X, size.Y, true)
+				if err != nil || !writer.IsOpened() {
+					log.Errorln("HLS could not open segment for", s.name, err)
+					if writer != nil {
+						writer.Close()
+					}
+					frame.Cleanup()
+					return
+				}
+				writerSize = size
+			}
+			writer.Write(frame.SharedMat.Mat)
+			frame.Cleanup()
+			frames++
+			tracker.Out(start)
+			if frames >= s.settings.Fps*s.settings.SegmentSec {
+				finishSegment()
+			}
+		}
+	}
+}
+
+// renderVideoFrame returns the frame scaled to the width with even dimensions for video encoders
+func renderVideoFrame(img *videosource.ProcessedImage, overlay *monitor.Overlay, highlight bool, width int) videosource.Image {
+	var selected *videosource.Image
+	if highlight {
+		selected = overlay.Live(img)
+	} else {
+		selected = img.Original.Ref()
+	}
+	scaled := selected.ScaleToWidth(width)
+	selected.Cleanup()
+	evenRect := image.Rect(0, 0, scaled.Width()&^1, scaled.Height()&^1)
+	if evenRect.Dx() == scaled.Width() && evenRect.Dy() == scaled.Height() {
+		return scaled
+	}
+	cropped := scaled.GetRegion(evenRect)
+	scaled.Cleanup()
+	return cropped
+}
+
+// Stop the stream
+func (s *hlsStream) Stop() {
+	s.cancel()
+}
+
+func hlsSegmentURI(name string, queryString string) string {
+	if queryString == "" {
+		return name
+	}
+	return name + "?" + queryString
+}
+
+// hlsRewritePlaylist appends the query string to each segment of the playlist
+func hlsRewritePlaylist(playlist string, queryString string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, cur := range lines {
+		if cur != "" && !strings.HasPrefix(cur, "#") {
+			lines[i] = hlsSegmentURI(cur, queryString)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runLink ends the sessions of idle players of a linked monitor until none are left or the stream is stopped
+func (s *hlsStream) runLink() {
+	defer s.closeClients()
+	idleTick := time.NewTicker(time.Second)
+	defer idleTick.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-idleTick.C:
+			if s.idle() {
+				return
+			}
+		}
+	}
+}
+
+// runningHlsStream returns the running stream of the monitor or nil, where hlsMu is held
+func (h *Http) runningHlsStream(monitorName string) *hlsStream {
+	if stream, found := h.hlsStreams[monitorName]; found {
+		select {
+		case <-stream.ctx.Done():
+			delete(h.hlsStreams, monitorName)
+		default:
+			return stream
+		}
+	}
+	return nil
+}
+
+// hlsSettings returns the configured HLS settings with defaults for unset values
+func (h *Http) hlsSettings() HlsConfig {
+	var conf *HlsConfig
+	if h.httpConfig != nil {
+		conf = h.httpConfig.Hls
+	}
+	return newHlsSettings(conf)
+}
+
+// getHlsLinkStream returns the stream tracking the players of a linked monitor, starting one when needed.
+// Its playlist and segments are fetched from the linked server.
+func (h *Http) getHlsLinkStream(monitorName string) *hlsStream {
+	h.hlsMu.Lock()
+	defer h.hlsMu.Unlock()
+	if stream := h.runningHlsStream(monitorName); stream != nil {
+		return stream
+	}
+	stream := newHlsStream(monitorName, "", h.hlsSettings(), h.streams, func(key string) {
+		h.hlsTerminate(monitorName, key)
+	})
+	go func() {
+		defer stream.Stop()
+		stream.runLink()
+	}()
+	h.hlsStreams[monitorName] = stream
+	return stream
+}
+
+// getHlsStream returns the running stream of the monitor, starting one when create is set
+func (h *Http) getHlsStream(monitorName string, create bool) *hlsStream {
+	h.hlsMu.Lock()
+	defer h.hlsMu.Unlock()
+	if stream := h.runningHlsStream(monitorName); stream != nil {
+		return stream
+	}
+	if !create {
+		return nil
+	}
+	imagesSub := h.manage.Subscribe(monitorName, 500, 1)
+	if imagesSub == nil {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "scout-hls-")
+	if err != nil {
+		log.Errorln("HLS could not create directory for", monitorName, err)
+		imagesSub.Unsubscribe()
+		return nil
+	}
+	liveTracker := h.manage.GetMonitorLiveTracker(monitorName, 500)
+	if liveTracker == nil {
+		liveTracker = monitor.NewProcessTracker("live")
+	}
+	overlay := h.manage.GetMonitorOverlay(monitorName, 500)
+	if overlay == nil {
+		overlay = monitor.NewOverlay(monitorName, nil)
+	}
+	stream := newHlsStream(monitorName, dir, h.hlsSettings(), h.streams, func(key string) {
+		h.hlsTerminate(monitorName, key)
+	})
+	source := newLatestSource(stream.ctx, imagesSub, liveTracker)
+	go func() {
+		defer stream.Stop()
+		stream.run(source, liveTracker, overlay)
+	}()
+	h.hlsStreams[monitorName] = stream
+	return stream
+}
+
+// stopHlsStreams stops all running streams
+func (h *Http) stopHlsStreams() {
+	h.hlsMu.Lock()
+	defer h.hlsMu.Unlock()
+	for name, stream := range h.hlsStreams {
+		stream.Stop()
+		delete(h.hlsStreams, name)
+	}
+}
+
+// hlsTerminate refuses the player of a terminated session for the cooldown
+func (h *Http) hlsTerminate(monitorName string, key string) {
+	h.hlsMu.Lock()
+	defer h.hlsMu.Unlock()
+	h.hlsTerminated[monitorName+"|"+key] = time.Now()
+}
+
+// hlsRefused returns true while the player of a terminated session is refused
+func (h *Http) hlsRefused(monitorName string, key string) bool {
+	h.hlsMu.Lock()
+	defer h.hlsMu.Unlock()
+	for cur, terminated := range h.hlsTerminated {
+		if time.Since(terminated) >= hlsTerminateCooldown {
+			delete(h.hlsTerminated, cur)
+		}
+	}
+	_, found := h.hlsTerminated[monitorName+"|"+key]
+	return found
+}
+
+// hlsLinkClient returns the link client of a linked monitor or nil for a monitor of this server
+func (h *Http) hlsLinkClient(monitorName string) *linkClient {
+	for _, cur := range h.linkClients {
+		for _, lmonName := range cur.monitorNames {
+			if lmonName == monitorName {
+				return cur
+			}
+		}
+	}
+	return nil
+}
+
+// hlsClientKey identifies the player of the request by its user, IP, and token
+func (h *Http) hlsClientKey(c *fiber.Ctx) string {
+	return h.requestUser(c) + "|" + c.IP() + "|" + c.Query("token")
+}
+
+// hlsPlaylistHandler returns the HLS playlist of a monitor
+// @Summary Live monitor HLS playlist
+// @Description Rolling HLS playlist of a monitor. The stream starts on the first request and stops when no client requests it for the idle time. Each player counts as a live stream session. A player whose session was terminated is refused for 5 minutes.
+// @Tags Monitor
+// @Produce application/vnd.apple.mpegurl
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Param token query string false "Auth Token"
+// @Success 200 {string} string "HLS playlist"
+// @Failure 403 {string} string "Session terminated"
+// @Failure 404 {string} string "Not Found"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 503 {string} string "Stream not ready"
+// @Router /hls/{name}/index.m3u8 [get]
+func (h *Http) hlsPlaylistHandler(c *fiber.Ctx) error {
+	monitorName := c.Params("name")
+	queryString := string(c.Request().URI().QueryString())
+	clientKey := h.hlsClientKey(c)
+	if h.hlsRefused(monitorName, clientKey) {
+		return c.Status(fiber.StatusForbidden).SendString(errHlsTerminated.Error())
+	}
+	link := h.hlsLinkClient(monitorName)
+	var stream *hlsStream
+	if link != nil {
+		stream = h.getHlsLinkStream(monitorName)
+	} else {
+		stream = h.getHlsStream(monitorName, true)
+	}
+	if stream == nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	session, err := stream.join(clientKey, h.requestUser(c), c.IP())
+	if errors.Is(err, errHlsStopped) {
+		return c.SendStatus(fiber.StatusServiceUnavailable)
+	} else if err != nil {
+		log.Warnln("HLS rejected", monitorName, err)
+		return c.Status(fiber.StatusTooManyRequests).SendString(err.Error())
+	}
+	var playlist string
+	if link != nil {
+		found, linkResult := link.getHlsFile(monitorName, hlsPlaylistName, h.linkRetry)
+		if !found {
+			return c.SendStatus(fiber.StatusServiceUnavailable)
+		}
+		playlist = hlsRewritePlaylist(string(linkResult), queryString)
+	} else {
+		if !stream.waitReady(time.Duration(stream.settings.SegmentSec*3+5) * time.Second) {
+			return c.SendStatus(fiber.StatusServiceUnavailable)
+		}
+		playlist = stream.playlist(queryString)
+	}
+	session.AddSent(len(playlist))
+	c.Set(fiber.HeaderContentType, "application/vnd.apple.mpegurl")
+	c.Set(fiber.HeaderCacheControl, "no-cache, no-store")
+	return c.SendString(playlist)
+}
+
+// hlsSegmentHandler returns a HLS segment of a monitor
+// @Summary Live monitor HLS segment
+// @Description MPEG-TS segment listed in the HLS playlist of a monitor. Players whose session ended must request the playlist again.
+// @Tags Monitor
+// @Produce video/mp2t
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Param segment path string true "Segment Filename"
+// @Param token query string false "Auth Token"
+// @Success 200 {file} file "TS segment"
+// @Failure 404 {string} string "Not Found"
+// @Router /hls/{name}/{segment} [get]
+func (h *Http) hlsSegmentHandler(c *fiber.Ctx) error {
+	monitorName := c.Params("name")
+	segmentName := c.Params("segment")
+	if !hlsSegmentRegex.MatchString(segmentName) {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	stream := h.getHlsStream(monitorName, false)
+	if stream == nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	session := stream.session(h.hlsClientKey(c))
+	if session == nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	var data []byte
+	if link := h.hlsLinkClient(monitorName); link != nil {
+		found, linkResult := link.getHlsFile(monitorName, segmentName, h.linkRetry)
+		if !found {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		data = linkResult
+	} else {
+		var err error
+		data, err = os.ReadFile(stream.segmentPath(segmentName))
+		if err != nil {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+	}
+	session.AddSent(len(data))
+	c.Set(fiber.HeaderContentType, "video/mp2t")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.Send(data)
+}
@@ -14,10 +14,8 @@ import (
 	"fmt"
 	"html/template"
 	"log"
-	"os"
 	"path/filepath"
-	"sort"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,10 +24,11 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/google/uuid"
-	"github.com/jonoton/go-dir"
 	"github.com/jonoton/go-memory"
 	"github.com/jonoton/go-runtime"
 	"github.com/jonoton/scout/manage"
+	"github.com/jonoton/scout/monitor"
+	"github.com/jonoton/scout/rtc"
 	logrus "github.com/sirupsen/logrus"
 	"github.com/valyala/bytebufferpool"
 
@@ -52,6 +51,12 @@ type Http struct {
 	twoFactorCheck      map[string]twoFactorAttempt
 	twoFactorMu         sync.Mutex
 	twoFactorTimeoutSec int
+	encodeCache         *encodeCache
+	streams             *streamRegistry
+	rtc                 *rtc.Rtc
+	hlsStreams          map[string]*hlsStream
+	hlsTerminated       map[string]time.Time
+	hlsMu               sync.Mutex
 	secTick             *time.Ticker
 	done                chan bool
 }
@@ -76,6 +81,10 @@ func NewHttp(manage *manage.Manage) *Http {
 		loginSigningKey:     uuid.New().String(),
 		twoFactorCheck:      make(map[string]twoFactorAttempt),
 		twoFactorTimeoutSec: 60,
+		encodeCache:         newEncodeCache(),
+		streams:             newStreamRegistry(0, 0),
+		hlsStreams:          make(map[string]*hlsStream),
+		hlsTerminated:       make(map[string]time.Time),
 		secTick:             time.NewTicker(time.Second),
 		done:                make(chan bool),
 	}
@@ -120,6 +129,12 @@ func (h *Http) setup() {
 	if h.httpConfig != nil && h.httpConfig.TwoFactorTimeoutSec > 0 {
 		h.twoFactorTimeoutSec = h.httpConfig.TwoFactorTimeoutSec
 	}
+	if h.httpConfig != nil {
+		h.streams = newStreamRegistry(h.httpConfig.MaxStreams, h.httpConfig.MaxStreamsPerUser)
+	}
+	if h.httpConfig != nil && h.httpConfig.WebRTC != nil && h.httpConfig.WebRTC.Enabled {
+		h.rtc = rtc.NewRtc(h.httpConfig.WebRTC)
+	}
 
 	limitPerSecond := 100
 	if h.httpConfig != nil && h.httpConfig.LimitPerSecond > 0 {
@@ -132,7 +147,13 @@ func (h *Http) setup() {
 
 	h.fiber.Use(limiter.New(cfg))
 
-	h.fiber.Use(compress.New(compress.Config{Level: compress.LevelDefault}))
+	h.fiber.Use(compress.New(compress.Config{
+		Next: func(c *fiber.Ctx) bool {
+			// streams are flushed per frame
+			return strings.HasPrefix(c.Path(), "/mjpeg/") || strings.HasPrefix(c.Path(), "/hls/")
+		},
+		Level: compress.LevelDefault,
+	}))
 
 	h.fiber.Static("/", runtime.GetRuntimeDirectory("http")+"/public")
 
@@ -175,6 +196,8 @@ func (h *Http) setup() {
 		c.Locals("width", width)
 		quality := c.Query("quality")
 		c.Locals("jpegQuality", quality)
+		c.Locals("overlay", c.Query("overlay"))
+		c.Locals("gzip", c.Query("gzip"))
 		token := c.Query("token")
 		if token != "" {
 			c.Request().Header.Add("Authorization", "Bearer "+token)
@@ -182,15 +205,19 @@ func (h *Http) setup() {
 		return c.Next()
 	})
 
+	h.fiber.Use("/snapshot/:name", queryTokenHandler)
+	h.fiber.Use("/mjpeg/:name", queryTokenHandler)
+	h.fiber.Use("/hls/:name", queryTokenHandler)
+
 	if h.loginNeeded {
 		h.fiber.Use(h.loginMiddleware())
 	}
 
 	h.fiber.Use("/live/:name", func(c *fiber.Ctx) error {
+		c.Locals("user", h.requestUser(c))
+		c.Locals("clientIP", c.IP())
 		localsMonName := c.Locals("monitorName")
-		localsWidth := c.Locals("width")
-		localsJpegQuality := c.Locals("jpegQuality")
-		if localsMonName == nil || localsWidth == nil || localsJpegQuality == nil {
+		if localsMonName == nil {
 			return c.Next()
 		}
 		monitorName := localsMonName.(string)
@@ -203,23 +230,28 @@ func (h *Http) setup() {
 		for _, cur := range h.linkClients {
 			for _, lmonName := range cur.monitorNames {
 				if lmonName == monitorName {
-					width, err := strconv.Atoi(localsWidth.(string))
-					if err != nil {
-						width = 0
-					}
-					jpegQuality, err := strconv.Atoi(localsJpegQuality.(string))
-					if err != nil {
-						jpegQuality = 0
-					}
-					return cur.forwardWebsocket(monitorName, width, jpegQuality)(c)
+					return cur.forwardWebsocket(monitorName, linkQueryString(c), h.streams)(c)
 				}
 			}
 		}
 		return c.Next()
 	})
 
+	h.fiber.Get("/live/mosaic", h.liveMosaic())
 	h.fiber.Get("/live/:name", h.liveMonitor())
 
+	h.fiber.Get("/snapshot/:name", h.snapshotHandler)
+
+	h.fiber.Get("/mjpeg/:name", h.mjpegHandler)
+
+	h.fiber.Post("/webrtc/:name", h.webrtcHandler)
+
+	h.fiber.Get("/hls/:name/"+hlsPlaylistName, h.hlsPlaylistHandler)
+	h.fiber.Get("/hls/:name/:segment", h.hlsSegmentHandler)
+
+	h.fiber.Get("/sessions/live", h.sessionsLiveHandler)
+	h.fiber.Delete("/sessions/live/:id", h.sessionsLiveTerminateHandler)
+
 	h.fiber.Get("/heartbeat", h.heartbeatHandler)
 
 	h.fiber.Use("/info/list", cache.New(cache.Config{
@@ -242,7 +274,7 @@ func (h *Http) setup() {
 	}))
 	h.fiber.Get("/alerts/list", h.alertsListHandler)
 
-	h.fiber.Use("/alerts/files/:name", h.alertsFilesHandler)
+	h.fiber.Use("/alerts/files", h.alertsFilesHandler)
 
 	h.fiber.Static("/alerts/files",
 		filepath.Clean(h.manage.GetDataDirectory()+"/alerts"),
@@ -255,10 +287,13 @@ func (h *Http) setup() {
 
 	h.fiber.Use("/recordings/list", cache.New(cache.Config{
 		Expiration: 2 * time.Second,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.Path() + "?summary=" + c.Query("summary")
+		},
 	}))
 	h.fiber.Get("/recordings/list", h.recordingsListHandler)
 
-	h.fiber.Use("/recordings/files/:name", h.recordingsFilesHandler)
+	h.fiber.Use("/recordings/files", h.recordingsFilesHandler)
 
 	h.fiber.Static("/recordings/files",
 		filepath.Clean(h.manage.GetDataDirectory()+"/recordings"),
@@ -274,7 +309,7 @@ func (h *Http) setup() {
 	}))
 	h.fiber.Get("/continuous/list", h.continuousListHandler)
 
-	h.fiber.Use("/continuous/files/:name", h.continuousFilesHandler)
+	h.fiber.Use("/continuous/files", h.continuousFilesHandler)
 
 	h.fiber.Static("/continuous/files",
 		filepath.Clean(h.manage.GetDataDirectory()+"/continuous"),
@@ -285,6 +320,33 @@ func (h *Http) setup() {
 		},
 	)
 
+	h.fiber.Post("/arm", h.armHandler)
+	h.fiber.Post("/arm/:name", h.armNameHandler)
+	h.fiber.Post("/disarm", h.disarmHandler)
+	h.fiber.Post("/disarm/:name", h.disarmNameHandler)
+
+	h.fiber.Get("/mode", h.modeHandler)
+	h.fiber.Post("/mode/:name", h.modeNameHandler)
+
+	h.fiber.Get("/protect", h.protectListHandler)
+	h.fiber.Post("/protect/:category/*", h.protectHandler)
+	h.fiber.Delete("/protect/:category/*", h.unprotectHandler)
+
+	h.fiber.Get("/events", h.eventsHandler)
+
+	h.fiber.Use("/events/files", h.eventsFilesHandler)
+
+	h.fiber.Static("/events/files",
+		filepath.Clean(h.manage.GetDataDirectory()+"/events"),
+		fiber.Static{
+			Compress:  true,
+			ByteRange: true,
+			Browse:    false,
+		},
+	)
+
+	h.fiber.Get("/events/:id", h.eventHandler)
+
 	h.fiber.Use("/memory", cache.New(cache.Config{
 		Expiration: 2 * time.Second,
 	}))
@@ -340,7 +402,7 @@ func (h *Http) infoListHandler(c *fiber.Ctx) error {
 
 // infoNameHandler returns information for a specific monitor
 // @Summary Get Monitor Info
-// @Description Get detailed information (FPS, etc.) for a specific monitor by name.
+// @Description Get detailed information (FPS, arm state, stage and sink latency, queue depth, drops, adaptive analysis rate) for a specific monitor by name.
 // @Tags Info
 // @Produce json
 // @Security ApiKeyAuth
@@ -367,6 +429,17 @@ func (h *Http) infoNameHandler(c *fiber.Ctx) error {
 	}
 	data.ReaderInFps = frameStatsCombo.In.AcceptedPerSecond
 	data.ReaderOutFps = frameStatsCombo.Out.AcceptedPerSecond
+	pipelineStats := h.manage.GetMonitorPipelineStats(monitorName, 1000)
+	if pipelineStats != nil {
+		data.Stages = pipelineStats.Stages
+		data.Sinks = pipelineStats.Sinks
+		data.Adaptive = pipelineStats.Adaptive
+	}
+	armState := h.manage.GetMonitorArmState(monitorName, 1000)
+	if armState != nil {
+		data.Armed = armState.Armed
+		data.ContinuousWhenDisarmed = armState.ContinuousWhenDisarmed
+	}
 	return c.JSON(data)
 }
 
@@ -415,13 +488,9 @@ func (h *Http) alertsLatestHandler(c *fiber.Ctx) error {
 // @Success 200 {array} string "List of alert filenames"
 // @Router /alerts/list [get]
 func (h *Http) alertsListHandler(c *fiber.Ctx) error {
-	data := make([]string, 0)
-	files, _ := dir.Expired(filepath.Clean(h.manage.GetDataDirectory()+"/alerts"),
-		dir.RegexEndsWith(".jpg"), time.Now(), time.Duration(5)*time.Second)
-	sort.Sort(dir.DescendingTime(files))
-	for _, fileInfo := range files {
-		data = append(data, fileInfo.Name())
-	}
+	data := h.listDataFiles(monitor.CategoryAlerts, func(name string) bool {
+		return strings.HasSuffix(name, ".jpg")
+	})
 	needSort := false
 	for _, cur := range h.linkClients {
 		linkResult := cur.getAlertsList(h.linkRetry)
@@ -431,7 +500,7 @@ func (h *Http) alertsListHandler(c *fiber.Ctx) error {
 		}
 	}
 	if needSort {
-		sort.Sort(dir.DescendingTimeName(data))
+		sortDataFiles(data)
 	}
 	return c.JSON(data)
 }
@@ -441,39 +510,28 @@ func (h *Http) alertsListHandler(c *fiber.Ctx) error {
 // @Description Retrieve a specific alert JPG file.
 // @Tags Alerts
 // @Security ApiKeyAuth
-// @Param name path string true "Filename"
+// @Param name path string true "Path from the list, or a filename"
 // @Success 200 {file} file "Alert image"
 // @Router /alerts/files/{name} [get]
 func (h *Http) alertsFilesHandler(c *fiber.Ctx) error {
-	paramFilename := c.Params("name")
-	filename := filepath.Clean(h.manage.GetDataDirectory() + "/alerts/" + paramFilename)
-	if fileExists(filename) {
-		return c.Next()
-	}
-	for _, cur := range h.linkClients {
-		found, linkResult := cur.getAlertsFile(paramFilename, h.linkRetry)
-		if found {
-			return c.Send(linkResult)
-		}
-	}
-	return c.Next()
+	return h.serveDataFile(c, monitor.CategoryAlerts, (*linkClient).getAlertsFile)
 }
 
 // recordingsListHandler returns a list of recording filenames
 // @Summary List recordings
-// @Description Get a list of motion recordings, sorted descending by time.
+// @Description Get a list of motion recordings, sorted descending by time. With summary the detection summary of each recording's sidecar is included.
 // @Tags Recordings
 // @Produce json
 // @Security ApiKeyAuth
-// @Success 200 {array} string "List of recording filenames"
+// @Param summary query bool false "Include detection summaries"
+// @Success 200 {array} string "List of recording filenames, or recordingSummaryResp objects with summary"
 // @Router /recordings/list [get]
 func (h *Http) recordingsListHandler(c *fiber.Ctx) error {
-	data := make([]string, 0)
-	files, _ := dir.Expired(filepath.Clean(h.manage.GetDataDirectory()+"/recordings"),
-		dir.RegexEndsWithBeforeExt("Portable"), time.Now(), time.Duration(5)*time.Second)
-	sort.Sort(dir.DescendingTime(files))
-	for _, fileInfo := range files {
-		data = append(data, fileInfo.Name())
+	data := h.listDataFiles(monitor.CategoryRecordings, func(name string) bool {
+		return isPortable(name) && !monitor.IsRecordingSidecar(name)
+	})
+	if c.QueryBool("summary") {
+		return h.recordingsSummary(c, data)
 	}
 	needSort := false
 	for _, cur := range h.linkClients {
@@ -484,7 +542,7 @@ func (h *Http) recordingsListHandler(c *fiber.Ctx) error {
 		}
 	}
 	if needSort {
-		sort.Sort(dir.DescendingTimeName(data))
+		sortDataFiles(data)
 	}
 	return c.JSON(data)
 }
@@ -494,22 +552,11 @@ func (h *Http) recordingsListHandler(c *fiber.Ctx) error {
 // @Description Retrieve a specific motion recording file.
 // @Tags Recordings
 // @Security ApiKeyAuth
-// @Param name path string true "Filename"
+// @Param name path string true "Path from the list, or a filename"
 // @Success 200 {file} file "Recording file"
 // @Router /recordings/files/{name} [get]
 func (h *Http) recordingsFilesHandler(c *fiber.Ctx) error {
-	paramFilename := c.Params("name")
-	filename := filepath.Clean(h.manage.GetDataDirectory() + "/recordings/" + paramFilename)
-	if fileExists(filename) {
-		return c.Next()
-	}
-	for _, cur := range h.linkClients {
-		found, linkResult := cur.getRecordingsFile(paramFilename, h.linkRetry)
-		if found {
-			return c.Send(linkResult)
-		}
-	}
-	return c.Next()
+	return h.serveDataFile(c, monitor.CategoryRecordings, (*linkClient).getRecordingsFile)
 }
 
 // continuousListHandler returns a list of continuous recording filenames
@@ -521,13 +568,7 @@ func (h *Http) recordingsFilesHandler(c *fiber.Ctx) error {
 // @Success 200 {array} string "List of continuous filenames"
 // @Router /continuous/list [get]
 func (h *Http) continuousListHandler(c *fiber.Ctx) error {
-	data := make([]string, 0)
-	files, _ := dir.Expired(filepath.Clean(h.manage.GetDataDirectory()+"/continuous"),
-		dir.RegexEndsWithBeforeExt("Portable"), time.Now(), time.Duration(5)*time.Second)
-	sort.Sort(dir.DescendingTime(files))
-	for _, fileInfo := range files {
-		data = append(data, fileInfo.Name())
-	}
+	data := h.listDataFiles(monitor.CategoryContinuous, isPortable)
 	needSort := false
 	for _, cur := range h.linkClients {
 		linkResult := cur.getContinuousList(h.linkRetry)
@@ -537,7 +578,7 @@ func (h *Http) continuousListHandler(c *fiber.Ctx) error {
 		}
 	}
 	if needSort {
-		sort.Sort(dir.DescendingTimeName(data))
+		sortDataFiles(data)
 	}
 	return c.JSON(data)
 }
@@ -547,22 +588,11 @@ func (h *Http) continuousListHandler(c *fiber.Ctx) error {
 // @Description Retrieve a specific continuous recording file.
 // @Tags Continuous
 // @Security ApiKeyAuth
-// @Param name path string true "Filename"
+// @Param name path string true "Path from the list, or a filename"
 // @Success 200 {file} file "Continuous recording file"
 // @Router /continuous/files/{name} [get]
 func (h *Http) continuousFilesHandler(c *fiber.Ctx) error {
-	paramFilename := c.Params("name")
-	filename := filepath.Clean(h.manage.GetDataDirectory() + "/continuous/" + paramFilename)
-	if fileExists(filename) {
-		return c.Next()
-	}
-	for _, cur := range h.linkClients {
-		found, linkResult := cur.getContinuousFile(paramFilename, h.linkRetry)
-		if found {
-			return c.Send(linkResult)
-		}
-	}
-	return c.Next()
+	return h.serveDataFile(c, monitor.CategoryContinuous, (*linkClient).getContinuousFile)
 }
 
 // memoryHandler returns memory usage information
@@ -602,13 +632,17 @@ func (h *Http) Listen() {
 	}()
 }
 
-func getFormattedKitchenTimestamp(t time.Time) string {
-	return t.Format("03:04:05 PM 01-02-2006")
+// linkQueryString returns the request query without the auth token for forwarding to a linked server
+func linkQueryString(c *fiber.Ctx) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+	c.Context().QueryArgs().CopyTo(args)
+	args.Del("token")
+	return args.String()
 }
 
-func fileExists(filename string) bool {
-	_, err := os.Stat(filename)
-	return !os.IsNotExist(err)
+func getFormattedKitchenTimestamp(t time.Time) string {
+	return t.Format("03:04:05 PM 01-02-2006")
 }
 
 func (h *Http) stopFiber() {
@@ -631,6 +665,7 @@ func (h *Http) Stop() {
 	defer close(h.done)
 	h.secTick.Stop()
 	h.stopFiber()
+	h.stopHlsStreams()
 }
 
 func (h *Http) Wait() {
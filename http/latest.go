@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonoton/go-pubsubmutex"
+	"github.com/jonoton/go-ringbuffer"
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/monitor"
+)
+
+// latestSource keeps only the newest image of a monitor subscription for a slow consumer
+type latestSource struct {
+	ringBuffer *ringbuffer.RingBuffer[*videosource.ProcessedImage]
+	tracker    *monitor.ProcessTracker
+	pending    int32
+	ctx        context.Context
+}
+
+// newLatestSource reads the subscription until ctx is done or no images arrive for 4 seconds
+func newLatestSource(ctx context.Context, imagesSub *pubsubmutex.Subscriber[*videosource.ProcessedImage],
+	tracker *monitor.ProcessTracker) *latestSource {
+	sourceCtx, sourceCancel := context.WithCancel(context.Background())
+	s := &latestSource{
+		ringBuffer: ringbuffer.New[*videosource.ProcessedImage](1),
+		tracker:    tracker,
+		pending:    0,
+		ctx:        sourceCtx,
+	}
+	go s.run(ctx, sourceCancel, imagesSub)
+	return s
+}
+
+func (s *latestSource) run(ctx context.Context, sourceCancel context.CancelFunc,
+	imagesSub *pubsubmutex.Subscriber[*videosource.ProcessedImage]) {
+	defer sourceCancel()
+	timeoutTick := time.NewTicker(time.Second * 4)
+	rx := 0
+	var unsubOnce sync.Once
+	unsubFunc := func() {
+		imagesSub.Unsubscribe()
+	}
+SourceLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			unsubOnce.Do(unsubFunc)
+		case msg, ok := <-imagesSub.Ch:
+			if !ok {
+				if msg.Data != nil {
+					img := msg.Data
+					img.Cleanup()
+				}
+				break SourceLoop
+			}
+			if msg.Data == nil {
+				continue
+			}
+			img := msg.Data
+			rx++
+			if atomic.AddInt32(&s.pending, 1) > 1 {
+				// ring buffer replaces the unsent image
+				atomic.AddInt32(&s.pending, -1)
+				s.tracker.Drop(1)
+			}
+			s.ringBuffer.Add(img)
+		case <-timeoutTick.C:
+			if rx == 0 {
+				unsubOnce.Do(unsubFunc)
+				break SourceLoop
+			}
+			rx = 0
+		}
+	}
+	timeoutTick.Stop()
+}
+
+// Chan returns the newest images
+func (s *latestSource) Chan() chan *videosource.ProcessedImage {
+	return s.ringBuffer.GetChan()
+}
+
+// Done is closed when the subscription ends
+func (s *latestSource) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Taken records an image taken from Chan
+func (s *latestSource) Taken() {
+	s.tracker.In(int(atomic.AddInt32(&s.pending, -1)))
+}
+
+// Remaining returns the unsent images
+func (s *latestSource) Remaining() []*videosource.ProcessedImage {
+	return s.ringBuffer.GetAll()
+}
+
+// Close stops the buffer and cleans up the unsent images
+func (s *latestSource) Close() {
+	s.ringBuffer.Stop()
+	for img := range s.ringBuffer.GetChan() {
+		img.Cleanup()
+	}
+}
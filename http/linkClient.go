@@ -1,7 +1,9 @@
 package http
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	gorillaWebsocket "github.com/gorilla/websocket"
 	"github.com/jonoton/go-websockets"
+	"github.com/jonoton/scout/monitor"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -125,9 +128,14 @@ func (l *linkClient) getMonList(numRetries int) []string {
 }
 
 type monInfoResp struct {
-	Name         string
-	ReaderInFps  int
-	ReaderOutFps int
+	Name                   string
+	ReaderInFps            int
+	ReaderOutFps           int
+	Armed                  bool
+	ContinuousWhenDisarmed bool
+	Stages                 []monitor.ProcessStats
+	Sinks                  []monitor.ProcessStats
+	Adaptive               *monitor.AdaptiveStats
 }
 
 func (l *linkClient) getMonInfo(name string, numRetries int) (found bool, result monInfoResp) {
@@ -159,6 +167,36 @@ func (l *linkClient) getMonInfo(name string, numRetries int) (found bool, result
 	return
 }
 
+func (l *linkClient) getSnapshot(name string, queryString string, numRetries int) (found bool, result []byte) {
+	l.checkNeedLogin()
+	var monName string
+	for _, cur := range l.monitorNames {
+		if cur == name {
+			found = true
+			monName = l.trimName(cur)
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	found = false
+	agent := fiber.Get(l.url + "/snapshot/" + url.PathEscape(monName) + "?" + queryString).InsecureSkipVerify()
+	l.checkAddAuth(agent)
+	if err := agent.Parse(); err == nil {
+		code, body, _ := agent.Bytes()
+		l.checkClearLogin(code)
+		if code == fiber.StatusOK {
+			result = body
+			found = true
+		} else if numRetries > 0 {
+			numRetries--
+			return l.getSnapshot(name, queryString, numRetries)
+		}
+	}
+	return
+}
+
 func (l *linkClient) getAlertsLatest(numRetries int) map[string]map[string]string {
 	l.checkNeedLogin()
 	result := make(map[string]map[string]string)
@@ -293,25 +331,95 @@ func (l *linkClient) getContinuousFile(filename string, numRetries int) (found b
 	return
 }
 
-func (l *linkClient) forwardWebsocket(monName string, width int, jpegQuality int) func(*fiber.Ctx) error {
+func (l *linkClient) getHlsFile(monName string, filename string, numRetries int) (found bool, result []byte) {
 	l.checkNeedLogin()
-	sockMonName := l.trimName(monName)
-	rawUrl := l.url + "/live/" + sockMonName
-	queryArgs := make([]string, 0)
-	if width > 0 {
-		queryArgs = append(queryArgs, fmt.Sprintf("width=%d", width))
+	result = make([]byte, 0)
+	agent := fiber.Get(l.url + "/hls/" + url.PathEscape(l.trimName(monName)) + "/" + filename).InsecureSkipVerify()
+	l.checkAddAuth(agent)
+	if err := agent.Parse(); err == nil {
+		code, body, _ := agent.Bytes()
+		l.checkClearLogin(code)
+		if code == fiber.StatusOK {
+			result = body
+			found = true
+		} else if numRetries > 0 {
+			numRetries--
+			return l.getHlsFile(monName, filename, numRetries)
+		}
+	}
+	return
+}
+
+// forwardMJPEG relays the MJPEG stream of the linked monitor as a stream session of this server
+func (l *linkClient) forwardMJPEG(c *fiber.Ctx, monName string, width int, jpegQuality int, highlight bool,
+	streams *streamRegistry, user string) error {
+	// terminating the session aborts the request to the linked server
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	stream, err := streams.Open(streamKindMJPEG, user, monName, c.IP(), streamCancel)
+	if err != nil {
+		streamCancel()
+		log.Warnln("MJPEG rejected", monName, err)
+		return c.Status(fiber.StatusTooManyRequests).SendString(err.Error())
 	}
-	if jpegQuality > 0 {
-		queryArgs = append(queryArgs, fmt.Sprintf("quality=%d", jpegQuality))
+	closeStream := func() {
+		streams.Close(stream)
+		streamCancel()
 	}
-	for index, curArg := range queryArgs {
-		if index == 0 {
-			rawUrl = rawUrl + "?"
-		}
-		if index > 0 {
-			rawUrl = rawUrl + "&"
+	l.checkNeedLogin()
+	rawUrl := fmt.Sprintf("%s/mjpeg/%s?width=%d&quality=%d&overlay=%t", l.url, url.PathEscape(l.trimName(monName)),
+		width, jpegQuality, highlight)
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		closeStream()
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	if len(l.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		closeStream()
+		log.Warnln("Link MJPEG connect error", monName)
+		return c.SendStatus(fiber.StatusBadGateway)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		closeStream()
+		l.checkClearLogin(resp.StatusCode)
+		return c.SendStatus(resp.StatusCode)
+	}
+	c.Set(fiber.HeaderContentType, resp.Header.Get(fiber.HeaderContentType))
+	c.Set(fiber.HeaderCacheControl, "no-cache, no-store")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer closeStream()
+		defer resp.Body.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				if _, writeErr := w.Write(buf[:n]); writeErr != nil || w.Flush() != nil {
+					return
+				}
+				stream.AddSent(n)
+			}
+			if err != nil {
+				return
+			}
 		}
-		rawUrl = rawUrl + curArg
+	})
+	return nil
+}
+
+// forwardWebsocket relays the live websocket of the linked monitor as a stream session of this server
+func (l *linkClient) forwardWebsocket(monName string, queryString string, streams *streamRegistry) func(*fiber.Ctx) error {
+	l.checkNeedLogin()
+	sockMonName := l.trimName(monName)
+	rawUrl := l.url + "/live/" + sockMonName
+	if queryString != "" {
+		rawUrl = rawUrl + "?" + queryString
 	}
 	u, _ := url.Parse(rawUrl)
 	if u.Scheme == "https" {
@@ -320,6 +428,13 @@ func (l *linkClient) forwardWebsocket(monName string, width int, jpegQuality int
 		u.Scheme = "ws"
 	}
 	return websocket.New(func(c *websocket.Conn) {
+		socketCtx, socketCancel := context.WithCancel(context.Background())
+		stream := streams.OpenWebsocket(c, streamKindLive, monName, socketCancel)
+		if stream == nil {
+			socketCancel()
+			return
+		}
+		defer streams.Close(stream)
 		dialer := gorillaWebsocket.DefaultDialer
 		headers := http.Header{}
 		if len(l.token) > 0 {
@@ -328,11 +443,11 @@ func (l *linkClient) forwardWebsocket(monName string, width int, jpegQuality int
 		connBackend, _, err := dialer.Dial(u.String(), headers)
 		if err != nil {
 			log.Warnln("Link Websocket connect error", u.Scheme, monName)
+			socketCancel()
 			return
 		}
 		uuid := uuid.New().String()
 		log.Infoln("Link Websocket opened", u.Scheme, uuid)
-		socketCtx, socketCancel := context.WithCancel(context.Background())
 		receive := func(msgType int, data []byte) {
 			connBackend.WriteMessage(msgType, data)
 		}
@@ -354,6 +469,7 @@ func (l *linkClient) forwardWebsocket(monName string, width int, jpegQuality int
 				if err != nil {
 					break SendLoop
 				}
+				stream.AddSent(len(msg))
 			}
 		}
 		cleanup := func() {
@@ -2,28 +2,33 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
-	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	fiber "github.com/gofiber/fiber/v2"
 	websocket "github.com/gofiber/websocket/v2"
-	"github.com/jonoton/go-gzip"
-	"github.com/jonoton/go-ringbuffer"
 	"github.com/jonoton/go-videosource"
 	"github.com/jonoton/go-websockets"
+	"github.com/jonoton/scout/monitor"
 )
 
 // liveMonitor handles real-time video streaming via websocket
 // @Summary Live monitor websocket
-// @Description Real-time video stream via websocket.
+// @Description Real-time video stream via websocket. Protocol 1 sends a binary JPEG per frame.
+// @Description Protocol 2 also sends a JSON frame message with the detections before each JPEG, a JSON state message on open and after each client message,
+// @Description and accepts JSON settings, pause, and resume messages from the client.
 // @Tags Monitor
 // @Security ApiKeyAuth
 // @Param name path string true "Monitor Name"
 // @Param width query int false "Width"
 // @Param quality query int false "JPEG Quality"
+// @Param overlay query bool false "Draw the live overlay layers (default true)"
+// @Param gzip query bool false "Gzip each frame for older clients (default true for protocol 1)"
+// @Param fps query int false "FPS cap (protocol 2)"
+// @Param protocol query int false "Protocol version (default 1)"
 // @Param token query string false "Auth Token"
 // @Success 101 {string} string "Switching Protocols"
 // @Router /live/{name} [get]
@@ -55,75 +60,80 @@ func (h *Http) liveMonitor() func(*fiber.Ctx) error {
 		if err != nil {
 			jpegQuality = 60
 		}
+		fps, err := strconv.Atoi(c.Query("fps"))
+		if err != nil || fps < 0 {
+			fps = 0
+		}
+		protocol, err := strconv.Atoi(c.Query("protocol"))
+		if err != nil || protocol != liveProtocolVersion {
+			protocol = liveProtocolLegacy
+		}
+		zipped := parseBoolLocal(c.Locals("gzip"), protocol == liveProtocolLegacy)
+		session := newLiveSession(liveSettings{
+			Width:   width,
+			Quality: jpegQuality,
+			Fps:     fps,
+			Overlay: parseBoolLocal(c.Locals("overlay"), true),
+			Paused:  false,
+		})
+
+		socketCtx, socketCancel := context.WithCancel(context.Background())
+		stream := h.streams.OpenWebsocket(c, streamKindLive, monitorName, socketCancel)
+		if stream == nil {
+			socketCancel()
+			return
+		}
+		defer h.streams.Close(stream)
 
 		imagesSub := h.manage.Subscribe(monitorName, 500, 1)
 		if imagesSub == nil {
 			log.Errorln("Failed to subscribe to monitor", monitorName)
+			socketCancel()
 			return
 		}
 
+		liveTracker := h.manage.GetMonitorLiveTracker(monitorName, 500)
+		if liveTracker == nil {
+			liveTracker = monitor.NewProcessTracker("live")
+		}
+		overlay := h.manage.GetMonitorOverlay(monitorName, 500)
+		if overlay == nil {
+			overlay = monitor.NewOverlay(monitorName, nil)
+		}
+
 		websocketName := monitorName + "-" + imagesSub.ID
 		log.Infoln("Websocket opened", websocketName)
-		socketCtx, socketCancel := context.WithCancel(context.Background())
-		sourceCtx, sourceCancel := context.WithCancel(context.Background())
-
-		ringBuffer := ringbuffer.New[*videosource.ProcessedImage](1)
-		ringBufferChan := ringBuffer.GetChan()
-
-		go func() {
-			defer sourceCancel()
-			timeoutTick := time.NewTicker(time.Second * 4)
-			rx := 0
-			var unsubOnce sync.Once
-			unsubFunc := func() {
-				imagesSub.Unsubscribe()
-			}
-		SourceLoop:
-			for {
-				select {
-				case <-socketCtx.Done():
-					unsubOnce.Do(unsubFunc)
-				case msg, ok := <-imagesSub.Ch:
-					if !ok {
-						if msg.Data != nil {
-							img := msg.Data
-							img.Cleanup()
-						}
-						break SourceLoop
-					}
-					if msg.Data == nil {
-						continue
-					}
-					img := msg.Data
-					rx++
-					ringBuffer.Add(img)
-				case <-timeoutTick.C:
-					if rx == 0 {
-						unsubOnce.Do(unsubFunc)
-						break SourceLoop
-					}
-					rx = 0
-				}
-			}
-			timeoutTick.Stop()
-		}()
+		source := newLatestSource(socketCtx, imagesSub, liveTracker)
+		ringBufferChan := source.Chan()
 
 		receive := func(msgType int, data []byte) {
-			// Nothing
+			if protocol == liveProtocolLegacy || msgType != websocket.TextMessage {
+				return
+			}
+			if !session.Apply(data) {
+				log.Warnln("Websocket unknown message", websocketName)
+			}
 		}
 		send := func(ctx context.Context, c *websocket.Conn) {
+			if protocol != liveProtocolLegacy && writeLiveJSON(c, stream, newLiveStateMsg(session.Settings())) != nil {
+				return
+			}
 		SendLoop:
 			for {
 				select {
 				case <-ctx.Done():
 					break SendLoop
-				case <-sourceCtx.Done():
-					remainingImgs := ringBuffer.GetAll()
+				case <-session.Changed():
+					if writeLiveJSON(c, stream, newLiveStateMsg(session.Settings())) != nil {
+						break SendLoop
+					}
+				case <-source.Done():
+					remainingImgs := source.Remaining()
 					needCleanup := false
 					for _, img := range remainingImgs {
 						if needCleanup {
 							img.Cleanup()
-						} else if !writeOut(c, img, width, jpegQuality) {
+						} else if !h.writeOut(c, stream, img, overlay, monitorName, session, protocol, zipped) {
 							// bad write so cleanup the remaining
 							needCleanup = true
 						}
@@ -131,9 +141,12 @@ func (h *Http) liveMonitor() func(*fiber.Ctx) error {
 					c.Close()
 					break SendLoop
 				case img, ok := <-ringBufferChan:
-					if !writeOut(c, img, width, jpegQuality) {
+					source.Taken()
+					start := time.Now()
+					if !h.writeOut(c, stream, img, overlay, monitorName, session, protocol, zipped) {
 						break SendLoop
 					}
+					liveTracker.Out(start)
 					if !ok {
 						img.Cleanup()
 						break SendLoop
@@ -142,10 +155,7 @@ func (h *Http) liveMonitor() func(*fiber.Ctx) error {
 			}
 		}
 		cleanup := func() {
-			ringBuffer.Stop()
-			for img := range ringBufferChan {
-				img.Cleanup()
-			}
+			source.Close()
 
 			log.Infoln("Websocket closed", websocketName)
 		}
@@ -154,18 +164,81 @@ func (h *Http) liveMonitor() func(*fiber.Ctx) error {
 	})
 }
 
-func writeOut(c *websocket.Conn, img *videosource.ProcessedImage, width int, jpegQuality int) (ok bool) {
-	if !img.Original.IsFilled() {
+// writeOut sends the frame with the session settings, skipping it when paused or over the FPS cap
+func (h *Http) writeOut(c *websocket.Conn, stream *streamSession, img *videosource.ProcessedImage, overlay *monitor.Overlay,
+	monitorName string, session *liveSession, protocol int, zipped bool) (ok bool) {
+	if img == nil {
+		return true
+	}
+	if !img.Original.IsFilled() || !session.AllowFrame(time.Now()) {
 		img.Cleanup()
 		return true
 	}
-	highlighted := img.HighlightedAll()
+	settings := session.Settings()
+	key := encodeKey{
+		monitorName: monitorName,
+		width:       settings.Width,
+		quality:     settings.Quality,
+		overlay:     settings.Overlay,
+	}
+	if protocol != liveProtocolLegacy {
+		if err := writeLiveJSON(c, stream, newLiveFrameMsg(img)); err != nil {
+			img.Cleanup()
+			return false
+		}
+	}
+	var imgArray []byte
+	if zipped {
+		imgArray = h.encodeCache.EncodeGzip(key, img, overlay)
+	} else {
+		imgArray = h.encodeCache.Encode(key, img, overlay)
+	}
+	if imgArray == nil {
+		return true
+	}
+	err := c.WriteMessage(websocket.BinaryMessage, imgArray)
+	stream.AddSent(len(imgArray))
+	return err == nil
+}
+
+// writeLiveJSON sends the message as JSON text
+func writeLiveJSON(c *websocket.Conn, stream *streamSession, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	stream.AddSent(len(data))
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+// encodeLive returns the live JPEG of the image or nil when empty and cleans up the image
+func encodeLive(img *videosource.ProcessedImage, overlay *monitor.Overlay, width int, jpegQuality int, highlight bool) []byte {
+	defer img.Cleanup()
+	if !img.Original.IsFilled() {
+		return nil
+	}
+	var highlighted *videosource.Image
+	if highlight {
+		highlighted = overlay.Live(img)
+	} else {
+		highlighted = img.Original.Ref()
+	}
 	selectedImage := highlighted.ScaleToWidth(width)
 	highlighted.Cleanup()
 	imgArray := selectedImage.EncodedQuality(jpegQuality)
 	selectedImage.Cleanup()
-	img.Cleanup()
-	zipped := gzip.Encode(imgArray, nil)
-	err := c.WriteMessage(websocket.BinaryMessage, zipped)
-	return err == nil
+	return imgArray
+}
+
+// parseBoolLocal returns the bool of the string local or the default when unset or invalid
+func parseBoolLocal(local interface{}, defaultValue bool) bool {
+	s, ok := local.(string)
+	if !ok {
+		return defaultValue
+	}
+	result, err := strconv.ParseBool(s)
+	if err != nil {
+		return defaultValue
+	}
+	return result
 }
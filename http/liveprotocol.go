@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/monitor"
+)
+
+// Live Protocol Constants
+const (
+	liveProtocolLegacy  = 1
+	liveProtocolVersion = 2
+
+	liveMsgState    = "state"
+	liveMsgFrame    = "frame"
+	liveMsgSettings = "settings"
+	liveMsgPause    = "pause"
+	liveMsgResume   = "resume"
+)
+
+// liveSettings are the stream settings of a live websocket
+type liveSettings struct {
+	Width   int
+	Quality int
+	Fps     int
+	Overlay bool
+	Paused  bool
+}
+
+// liveStateMsg is sent on open and after each client message
+type liveStateMsg struct {
+	Type    string
+	Version int
+	liveSettings
+}
+
+// liveDetection is a detection in original frame pixels
+type liveDetection struct {
+	Label      string
+	Percentage int
+	Rect       image.Rectangle
+}
+
+// liveFrameMsg is sent before each JPEG frame
+type liveFrameMsg struct {
+	Type    string
+	Time    time.Time
+	Width   int
+	Height  int
+	Motions []image.Rectangle
+	Objects []liveDetection
+	Faces   []liveDetection
+}
+
+// liveControlMsg is sent by the client, where unset fields keep their value
+type liveControlMsg struct {
+	Type    string
+	Width   *int
+	Quality *int
+	Fps     *int
+	Overlay *bool
+}
+
+// liveSession holds the settings of a live websocket changed by client messages
+type liveSession struct {
+	mu       sync.Mutex
+	settings liveSettings
+	lastSent time.Time
+	changed  chan struct{}
+}
+
+func newLiveSession(settings liveSettings) *liveSession {
+	s := &liveSession{
+		settings: settings,
+		changed:  make(chan struct{}, 1),
+	}
+	return s
+}
+
+// Settings returns the current settings
+func (s *liveSession) Settings() liveSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// Changed signals when the state should be sent
+func (s *liveSession) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Apply the client message and return false when it is not understood
+func (s *liveSession) Apply(data []byte) bool {
+	var msg liveControlMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return false
+	}
+	s.mu.Lock()
+	switch msg.Type {
+	case liveMsgSettings:
+		if msg.Width != nil && *msg.Width >= 0 {
+			s.settings.Width = *msg.Width
+		}
+		if msg.Quality != nil && *msg.Quality > 0 && *msg.Quality <= 100 {
+			s.settings.Quality = *msg.Quality
+		}
+		if msg.Fps != nil && *msg.Fps >= 0 {
+			s.settings.Fps = *msg.Fps
+		}
+		if msg.Overlay != nil {
+			s.settings.Overlay = *msg.Overlay
+		}
+	case liveMsgPause:
+		s.settings.Paused = true
+	case liveMsgResume:
+		s.settings.Paused = false
+	default:
+		s.mu.Unlock()
+		return false
+	}
+	s.mu.Unlock()
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// AllowFrame returns true when a frame can be sent now, keeping within the FPS cap
+func (s *liveSession) AllowFrame(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.settings.Paused {
+		return false
+	}
+	if s.settings.Fps > 0 && now.Sub(s.lastSent) < time.Second/time.Duration(s.settings.Fps) {
+		return false
+	}
+	s.lastSent = now
+	return true
+}
+
+func newLiveStateMsg(settings liveSettings) liveStateMsg {
+	return liveStateMsg{
+		Type:         liveMsgState,
+		Version:      liveProtocolVersion,
+		liveSettings: settings,
+	}
+}
+
+func newLiveFrameMsg(img *videosource.ProcessedImage) liveFrameMsg {
+	m := liveFrameMsg{
+		Type:    liveMsgFrame,
+		Time:    img.Original.CreatedTime(),
+		Width:   img.Original.Width(),
+		Height:  img.Original.Height(),
+		Motions: make([]image.Rectangle, 0, len(img.Motions)),
+		Objects: make([]liveDetection, 0, len(img.Objects)),
+		Faces:   make([]liveDetection, 0, len(img.Faces)),
+	}
+	for _, cur := range img.Motions {
+		m.Motions = append(m.Motions, cur.Rect)
+	}
+	for _, cur := range img.Objects {
+		m.Objects = append(m.Objects, liveDetection{Label: cur.Description, Percentage: cur.Percentage, Rect: cur.Rect})
+	}
+	for _, cur := range img.Faces {
+		m.Faces = append(m.Faces, liveDetection{Label: monitor.EventLabelFace, Percentage: cur.Percentage, Rect: cur.Rect})
+	}
+	return m
+}
@@ -286,3 +286,33 @@ func (h *Http) loginMiddleware() func(*fiber.Ctx) error {
 		},
 	})
 }
+
+// queryTokenHandler accepts the auth token as a query parameter for clients that cannot set headers
+func queryTokenHandler(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token != "" {
+		c.Request().Header.Add("Authorization", "Bearer "+token)
+	}
+	return c.Next()
+}
+
+// requestUser returns the user of the request's login token or empty when not logged in
+func (h *Http) requestUser(c *fiber.Ctx) string {
+	auth := c.Get(fiber.HeaderAuthorization)
+	tokenString := strings.TrimPrefix(auth, "Bearer ")
+	if tokenString == "" || tokenString == auth {
+		return ""
+	}
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return []byte(h.loginSigningKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return ""
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if user, ok := claims["user"].(string); ok {
+			return user
+		}
+	}
+	return ""
+}
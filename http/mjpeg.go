@@ -0,0 +1,116 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+const mjpegBoundary = "scoutframe"
+
+// mjpegHandler streams a monitor as MJPEG
+// @Summary Live monitor MJPEG
+// @Description Real-time video stream as multipart/x-mixed-replace MJPEG. Slow clients only receive the newest frame.
+// @Tags Monitor
+// @Produce multipart/x-mixed-replace
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Param width query int false "Width"
+// @Param quality query int false "JPEG Quality"
+// @Param overlay query bool false "Draw the live overlay layers (default true)"
+// @Param token query string false "Auth Token"
+// @Success 200 {string} string "MJPEG stream"
+// @Failure 404 {string} string "Not Found"
+// @Router /mjpeg/{name} [get]
+func (h *Http) mjpegHandler(c *fiber.Ctx) error {
+	monitorName := c.Params("name")
+	width := c.QueryInt("width", 0)
+	jpegQuality := c.QueryInt("quality", 60)
+	highlight := c.QueryBool("overlay", true)
+	for _, cur := range h.linkClients {
+		for _, lmonName := range cur.monitorNames {
+			if lmonName == monitorName {
+				return cur.forwardMJPEG(c, monitorName, width, jpegQuality, highlight, h.streams, h.requestUser(c))
+			}
+		}
+	}
+
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	stream, err := h.streams.Open(streamKindMJPEG, h.requestUser(c), monitorName, c.IP(), streamCancel)
+	if err != nil {
+		streamCancel()
+		log.Warnln("MJPEG rejected", monitorName, err)
+		return c.Status(fiber.StatusTooManyRequests).SendString(err.Error())
+	}
+	imagesSub := h.manage.Subscribe(monitorName, 500, 1)
+	if imagesSub == nil {
+		h.streams.Close(stream)
+		streamCancel()
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	liveTracker := h.manage.GetMonitorLiveTracker(monitorName, 500)
+	if liveTracker == nil {
+		liveTracker = monitor.NewProcessTracker("live")
+	}
+	overlay := h.manage.GetMonitorOverlay(monitorName, 500)
+	if overlay == nil {
+		overlay = monitor.NewOverlay(monitorName, nil)
+	}
+	key := encodeKey{
+		monitorName: monitorName,
+		width:       width,
+		quality:     jpegQuality,
+		overlay:     highlight,
+	}
+
+	c.Set(fiber.HeaderContentType, "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	c.Set(fiber.HeaderCacheControl, "no-cache, no-store")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		streamName := monitorName + "-" + imagesSub.ID
+		log.Infoln("MJPEG opened", streamName)
+		source := newLatestSource(streamCtx, imagesSub, liveTracker)
+		defer func() {
+			h.streams.Close(stream)
+			streamCancel()
+			source.Close()
+			log.Infoln("MJPEG closed", streamName)
+		}()
+		ringBufferChan := source.Chan()
+		for {
+			select {
+			case <-source.Done():
+				return
+			case img, ok := <-ringBufferChan:
+				if !ok {
+					return
+				}
+				source.Taken()
+				start := time.Now()
+				imgArray := h.encodeCache.Encode(key, img, overlay)
+				if !writeMJPEGFrame(w, imgArray) {
+					return
+				}
+				stream.AddSent(len(imgArray))
+				liveTracker.Out(start)
+			}
+		}
+	})
+	return nil
+}
+
+// writeMJPEGFrame writes the JPEG as a multipart frame and returns false when the client is gone
+func writeMJPEGFrame(w *bufio.Writer, imgArray []byte) bool {
+	if imgArray == nil {
+		return true
+	}
+	fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(imgArray))
+	w.Write(imgArray)
+	w.WriteString("\r\n")
+	return w.Flush() == nil
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// modeHandler returns the active mode
+// @Summary Get mode
+// @Description Get the active mode and the modes configured in manage.yaml.
+// @Tags Mode
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} manage.ModeInfo "Active and configured modes"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /mode [get]
+func (h *Http) modeHandler(c *fiber.Ctx) error {
+	modeInfo := h.manage.GetMode(1000)
+	if modeInfo == nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	return c.JSON(modeInfo)
+}
+
+// modeNameHandler switches the active mode
+// @Summary Switch mode
+// @Description Switch all monitors to the detect, alert, record, and notify policies of the named mode. Every switch is recorded in the modes audit log.
+// @Tags Mode
+// @Security ApiKeyAuth
+// @Param name path string true "Mode Name"
+// @Success 200 {string} string "OK"
+// @Failure 404 {string} string "Not Found"
+// @Router /mode/{name} [post]
+func (h *Http) modeNameHandler(c *fiber.Ctx) error {
+	source := []string{"http", h.requestUser(c), c.IP()}
+	if !h.manage.SetMode(c.Params("name"), source, 2000) {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,336 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	websocket "github.com/gofiber/websocket/v2"
+	"github.com/jonoton/go-gzip"
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/go-websockets"
+	"github.com/jonoton/scout/monitor"
+	log "github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+)
+
+// Mosaic Constants
+const (
+	mosaicMaxTiles = 36
+	mosaicStaleSec = 5
+)
+
+// mosaicTile holds the newest frame of one monitor in the mosaic
+type mosaicTile struct {
+	name     string
+	overlay  *monitor.Overlay
+	mu       sync.Mutex
+	img      *videosource.ProcessedImage
+	rendered bool
+	updated  time.Time
+}
+
+func newMosaicTile(name string) *mosaicTile {
+	t := &mosaicTile{
+		name:     name,
+		overlay:  nil,
+		img:      nil,
+		rendered: false,
+	}
+	return t
+}
+
+// set replaces the frame, where rendered frames already have the overlay drawn
+func (t *mosaicTile) set(img *videosource.ProcessedImage, rendered bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.img != nil {
+		t.img.Cleanup()
+	}
+	t.img = img
+	t.rendered = rendered
+	t.updated = time.Now()
+}
+
+// frame returns the tile image or nil when stale
+func (t *mosaicTile) frame(highlight bool) *videosource.Image {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.img == nil || !t.img.Original.IsFilled() || time.Since(t.updated) > mosaicStaleSec*time.Second {
+		return nil
+	}
+	if highlight && !t.rendered && t.overlay != nil {
+		return t.overlay.Live(t.img)
+	}
+	return t.img.Original.Ref()
+}
+
+func (t *mosaicTile) cleanup() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.img != nil {
+		t.img.Cleanup()
+		t.img = nil
+	}
+}
+
+// mosaicLayout returns the columns and rows of the layout, such as 3x3, or a square grid fitting count
+func mosaicLayout(layout string, count int) (cols int, rows int) {
+	if _, err := fmt.Sscanf(strings.ToLower(layout), "%dx%d", &cols, &rows); err == nil &&
+		cols > 0 && rows > 0 && cols*rows <= mosaicMaxTiles {
+		return
+	}
+	cols = int(math.Ceil(math.Sqrt(float64(max(count, 1)))))
+	rows = int(math.Ceil(float64(max(count, 1)) / float64(cols)))
+	return
+}
+
+// liveMosaic streams the monitors composited into one grid via websocket
+// @Summary Live mosaic websocket
+// @Description Real-time grid of the latest frame of each monitor via websocket, sending a binary JPEG per frame like protocol 1 of the live monitor websocket.
+// @Description Stale or disconnected monitors show a placeholder tile.
+// @Tags Monitor
+// @Security ApiKeyAuth
+// @Param monitors query string false "Comma separated monitor names (default all)"
+// @Param layout query string false "Columns x rows, such as 3x3 (default fits the monitors)"
+// @Param width query int false "Mosaic width (default 1280)"
+// @Param quality query int false "JPEG Quality"
+// @Param fps query int false "Frames per second (default 5)"
+// @Param overlay query bool false "Draw the live overlay layers (default true)"
+// @Param gzip query bool false "Gzip each frame for older clients (default true)"
+// @Param token query string false "Auth Token"
+// @Success 101 {string} string "Switching Protocols"
+// @Router /live/mosaic [get]
+func (h *Http) liveMosaic() func(*fiber.Ctx) error {
+	return websocket.New(func(c *websocket.Conn) {
+		monitorNames := make([]string, 0)
+		for _, cur := range strings.Split(c.Query("monitors"), ",") {
+			if cur = strings.TrimSpace(cur); cur != "" {
+				monitorNames = append(monitorNames, cur)
+			}
+		}
+		if len(monitorNames) == 0 {
+			monitorNames = h.manage.GetMonitorNames(2000)
+			for _, cur := range h.linkClients {
+				monitorNames = append(monitorNames, cur.getMonList(h.linkRetry)...)
+			}
+		}
+		cols, rows := mosaicLayout(c.Query("layout"), len(monitorNames))
+		if len(monitorNames) > cols*rows {
+			monitorNames = monitorNames[:cols*rows]
+		}
+		width := queryIntConn(c, "width", 1280)
+		if width <= 0 {
+			width = 1280
+		}
+		tileWidth := max(width/cols, 16) &^ 1
+		tileHeight := (tileWidth * 9 / 16) &^ 1
+		jpegQuality := queryIntConn(c, "quality", 60)
+		fps := queryIntConn(c, "fps", 5)
+		if fps <= 0 {
+			fps = 5
+		}
+		highlight := parseBoolLocal(c.Query("overlay"), true)
+		zipped := parseBoolLocal(c.Query("gzip"), true)
+
+		socketCtx, socketCancel := context.WithCancel(context.Background())
+		stream := h.streams.OpenWebsocket(c, streamKindMosaic, strings.Join(monitorNames, ","), socketCancel)
+		if stream == nil {
+			socketCancel()
+			return
+		}
+		defer h.streams.Close(stream)
+
+		mosaicName := fmt.Sprintf("mosaic-%dx%d-%d", cols, rows, time.Now().UnixNano())
+		log.Infoln("Websocket opened", mosaicName)
+		tilesWg := &sync.WaitGroup{}
+		tiles := make([]*mosaicTile, 0, len(monitorNames))
+		for _, name := range monitorNames {
+			tile := newMosaicTile(name)
+			tiles = append(tiles, tile)
+			h.startMosaicTile(socketCtx, tilesWg, tile, tileWidth, jpegQuality, highlight, fps)
+		}
+
+		send := func(ctx context.Context, c *websocket.Conn) {
+			frameTick := time.NewTicker(time.Second / time.Duration(fps))
+			defer frameTick.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-frameTick.C:
+					imgArray := composeMosaic(tiles, cols, rows, tileWidth, tileHeight, highlight, jpegQuality)
+					if imgArray == nil {
+						continue
+					}
+					if zipped {
+						imgArray = gzip.Encode(imgArray, nil)
+					}
+					if c.WriteMessage(websocket.BinaryMessage, imgArray) != nil {
+						return
+					}
+					stream.AddSent(len(imgArray))
+				}
+			}
+		}
+		cleanup := func() {
+			tilesWg.Wait()
+			for _, tile := range tiles {
+				tile.cleanup()
+			}
+			log.Infoln("Websocket closed", mosaicName)
+		}
+
+		websockets.Run(socketCtx, socketCancel, c, nil, send, cleanup)
+	})
+}
+
+// startMosaicTile keeps the tile updated from the local monitor or by polling the snapshot of a linked monitor
+func (h *Http) startMosaicTile(ctx context.Context, wg *sync.WaitGroup, tile *mosaicTile,
+	tileWidth int, jpegQuality int, highlight bool, fps int) {
+	for _, cur := range h.linkClients {
+		for _, lmonName := range cur.monitorNames {
+			if lmonName == tile.name {
+				wg.Add(1)
+				go func(lc *linkClient) {
+					defer wg.Done()
+					h.pollMosaicTile(ctx, lc, tile, tileWidth, jpegQuality, highlight, fps)
+				}(cur)
+				return
+			}
+		}
+	}
+	tile.overlay = h.manage.GetMonitorOverlay(tile.name, 500)
+	if tile.overlay == nil {
+		tile.overlay = monitor.NewOverlay(tile.name, nil)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			h.followMosaicTile(ctx, tile)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+				// resubscribe after the monitor stopped or timed out
+			}
+		}
+	}()
+}
+
+// followMosaicTile updates the tile from the local monitor until the subscription ends
+func (h *Http) followMosaicTile(ctx context.Context, tile *mosaicTile) {
+	imagesSub := h.manage.Subscribe(tile.name, 500, 1)
+	if imagesSub == nil {
+		return
+	}
+	liveTracker := h.manage.GetMonitorLiveTracker(tile.name, 500)
+	if liveTracker == nil {
+		liveTracker = monitor.NewProcessTracker("live")
+	}
+	source := newLatestSource(ctx, imagesSub, liveTracker)
+	defer source.Close()
+	ringBufferChan := source.Chan()
+	for {
+		select {
+		case <-source.Done():
+			return
+		case img, ok := <-ringBufferChan:
+			if !ok {
+				return
+			}
+			source.Taken()
+			tile.set(img, false)
+			liveTracker.Out(time.Now())
+		}
+	}
+}
+
+func (h *Http) pollMosaicTile(ctx context.Context, lc *linkClient, tile *mosaicTile,
+	tileWidth int, jpegQuality int, highlight bool, fps int) {
+	queryString := fmt.Sprintf("width=%d&quality=%d&highlight=%t", tileWidth, jpegQuality, highlight)
+	pollTick := time.NewTicker(time.Second / time.Duration(fps))
+	defer pollTick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTick.C:
+			found, data := lc.getSnapshot(tile.name, queryString, 0)
+			if !found {
+				continue
+			}
+			mat, err := gocv.IMDecode(data, gocv.IMReadColor)
+			if err != nil || mat.Empty() {
+				mat.Close()
+				continue
+			}
+			tile.set(videosource.NewProcessedImage(*videosource.NewImage(mat)), true)
+		}
+	}
+}
+
+// composeMosaic returns the JPEG of the tiles drawn in the grid
+func composeMosaic(tiles []*mosaicTile, cols int, rows int, tileWidth int, tileHeight int,
+	highlight bool, jpegQuality int) []byte {
+	canvas := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(0, 0, 0, 0), rows*tileHeight, cols*tileWidth, gocv.MatTypeCV8UC3)
+	for i, tile := range tiles {
+		rect := image.Rect(0, 0, tileWidth, tileHeight).Add(image.Pt((i%cols)*tileWidth, (i/cols)*tileHeight))
+		region := canvas.Region(rect)
+		frame := tile.frame(highlight)
+		if frame != nil {
+			drawMosaicFrame(&region, frame.SharedMat.Mat)
+			frame.Cleanup()
+		} else {
+			drawMosaicPlaceholder(&region, tile.name)
+		}
+		region.Close()
+	}
+	img := videosource.NewImage(canvas)
+	defer img.Cleanup()
+	return img.EncodedQuality(jpegQuality)
+}
+
+// drawMosaicFrame fits the frame within the tile keeping the aspect ratio
+func drawMosaicFrame(tile *gocv.Mat, frame gocv.Mat) {
+	if frame.Cols() == 0 || frame.Rows() == 0 {
+		return
+	}
+	scale := math.Min(float64(tile.Cols())/float64(frame.Cols()), float64(tile.Rows())/float64(frame.Rows()))
+	size := image.Pt(max(int(float64(frame.Cols())*scale), 1), max(int(float64(frame.Rows())*scale), 1))
+	resized := gocv.NewMat()
+	defer resized.Close()
+	gocv.Resize(frame, &resized, size, 0, 0, gocv.InterpolationArea)
+	offset := image.Pt((tile.Cols()-size.X)/2, (tile.Rows()-size.Y)/2)
+	target := tile.Region(image.Rectangle{Min: offset, Max: offset.Add(size)})
+	defer target.Close()
+	resized.CopyTo(&target)
+}
+
+// drawMosaicPlaceholder draws a dark tile with the monitor name
+func drawMosaicPlaceholder(tile *gocv.Mat, name string) {
+	tile.SetTo(gocv.NewScalar(40, 40, 40, 0))
+	fontScale := math.Max(0.4, float64(tile.Rows())/360*0.7)
+	thickness := int(math.Max(1, math.Round(fontScale*2)))
+	text := name + " offline"
+	textSize := gocv.GetTextSize(text, gocv.FontHersheySimplex, fontScale, thickness)
+	pt := image.Pt(max((tile.Cols()-textSize.X)/2, 0), (tile.Rows()+textSize.Y)/2)
+	gocv.PutText(tile, text, pt, gocv.FontHersheySimplex, fontScale, color.RGBA{200, 200, 200, 255}, thickness)
+}
+
+// queryIntConn returns the int query parameter of the websocket or the default when unset or invalid
+func queryIntConn(c *websocket.Conn, key string, defaultValue int) int {
+	result, err := strconv.Atoi(c.Query(key))
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+	"path/filepath"
+
+	fiber "github.com/gofiber/fiber/v2"
+
+	"github.com/jonoton/scout/manage"
+)
+
+// protectListHandler returns the protected files
+// @Summary List protected files
+// @Description Get the files kept from pruning by category, as paths from the category list.
+// @Tags Storage
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string][]string "Protected paths by category"
+// @Router /protect [get]
+func (h *Http) protectListHandler(c *fiber.Ctx) error {
+	return c.JSON(h.manage.GetProtectedFiles())
+}
+
+// protectHandler keeps a file from being pruned
+// @Summary Protect file
+// @Description Keep a file from being pruned by age or space. Protecting a recording keeps its sidecars and protecting any image of an alert set keeps the set. The protection persists across restarts.
+// @Tags Storage
+// @Security ApiKeyAuth
+// @Param category path string true "continuous, recordings, events, or alerts"
+// @Param path path string true "Path from the list, or a filename"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Router /protect/{category}/{path} [post]
+func (h *Http) protectHandler(c *fiber.Ctx) error {
+	return h.setProtected(c, true)
+}
+
+// unprotectHandler lets a file be pruned again
+// @Summary Unprotect file
+// @Description Let a protected file be pruned again by age or space.
+// @Tags Storage
+// @Security ApiKeyAuth
+// @Param category path string true "continuous, recordings, events, or alerts"
+// @Param path path string true "Protected path"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Router /protect/{category}/{path} [delete]
+func (h *Http) unprotectHandler(c *fiber.Ctx) error {
+	return h.setProtected(c, false)
+}
+
+func (h *Http) setProtected(c *fiber.Ctx, protect bool) error {
+	category := c.Params("category")
+	rel := c.Params("*")
+	if fullPath, found := h.resolveDataFile(category, rel); found {
+		categoryDir := filepath.Join(filepath.Clean(h.manage.GetDataDirectory()), category)
+		if resolved, err := filepath.Rel(categoryDir, fullPath); err == nil {
+			rel = filepath.ToSlash(resolved)
+		}
+	} else if entry := h.manage.FindArchivedFile(category, rel); entry != nil {
+		rel = entry.Path
+	}
+	err := h.manage.ProtectFile(category, rel, protect)
+	switch {
+	case errors.Is(err, manage.ErrUnknownCategory):
+		return c.SendStatus(fiber.StatusBadRequest)
+	case err != nil:
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -1,6 +1,7 @@
 let hostname = $(location).attr('host');
 let connected = false;
 let webSockets = [];
+let peerConnections = [];
 
 $(document).ready(function () {
    $.ajaxSetup({
@@ -94,20 +95,99 @@ function initMonitors(monitorNames) {
                      </div>
                   </div>
                   <img id="${monitorName}" class="d-none h-100 mw-100">
+                  <video id="${monitorName}-video" class="d-none h-100 mw-100" autoplay muted playsinline></video>
                </div>
             </div>
       `;
       $('#monitor-container').append(html);
-      let ws = createWebSocket(monitorName);
-      webSockets.push(ws);
+      createStream(monitorName);
       getMonitorInfo(monitorName);
    });
 }
 
+function createStream(monitorName) {
+   let fallback = function () {
+      let ws = createWebSocket(monitorName);
+      webSockets.push(ws);
+   };
+   if (!("RTCPeerConnection" in window)) {
+      fallback();
+      return;
+   }
+   createPeerConnection(monitorName, fallback);
+}
+
+function createPeerConnection(monitorName, fallback) {
+   let pc = new RTCPeerConnection();
+   let failed = false;
+   let fail = function () {
+      // ignore after cleanup closed it
+      if (failed || !peerConnections.includes(pc)) {
+         return;
+      }
+      failed = true;
+      pc.close();
+      peerConnections = peerConnections.filter(function (cur) { return cur !== pc; });
+      $(`#${monitorName}-video`).addClass('d-none');
+      if (connected) {
+         fallback();
+      }
+   };
+   peerConnections.push(pc);
+   pc.addTransceiver('video', { direction: 'recvonly' });
+   pc.ontrack = function (evt) {
+      let stream = evt.streams.length > 0 ? evt.streams[0] : new MediaStream([evt.track]);
+      requestAnimationFrame(function () {
+         let monDiv = $(`#${monitorName}-div`);
+         let monVideo = $(`#${monitorName}-video`);
+         monDiv.addClass('d-none');
+         monVideo.removeClass('d-none');
+         monVideo[0].srcObject = stream;
+      });
+   };
+   pc.onconnectionstatechange = function () {
+      if (pc.connectionState == 'failed' || pc.connectionState == 'disconnected') {
+         fail();
+      }
+   };
+   setTimeout(function () {
+      if (pc.connectionState != 'connected') {
+         fail();
+      }
+   }, 10000);
+   $(`#${monitorName}-div`).removeClass('d-none');
+   pc.createOffer().then(function (offer) {
+      return pc.setLocalDescription(offer);
+   }).then(function () {
+      return new Promise(function (resolve) {
+         if (pc.iceGatheringState == 'complete') {
+            resolve();
+            return;
+         }
+         pc.onicegatheringstatechange = function () {
+            if (pc.iceGatheringState == 'complete') {
+               resolve();
+            }
+         };
+         setTimeout(resolve, 3000);
+      });
+   }).then(function () {
+      return $.ajax({
+         type: "POST",
+         url: `webrtc/${monitorName}`,
+         contentType: "application/json",
+         data: JSON.stringify(pc.localDescription),
+         dataType: "json"
+      });
+   }).then(function (answer) {
+      return pc.setRemoteDescription(answer);
+   }).catch(fail);
+}
+
 function createWebSocket(monitorName) {
    let jwt = Cookies.get("token");
    let wsPre = ('https:' == document.location.protocol ? 'wss' : 'ws');
-   let ws = new WebSocket(`${wsPre}://${hostname}/live/${monitorName}?quality=50&token=${jwt}`);
+   let ws = new WebSocket(`${wsPre}://${hostname}/live/${monitorName}?quality=50&gzip=false&token=${jwt}`);
    ws.onopen = function () {
       requestAnimationFrame(function () {
          let monDiv = $(`#${monitorName}-div`);
@@ -119,18 +199,13 @@ function createWebSocket(monitorName) {
    ws.onmessage = function (evt) {
       let blobData = evt.data;
       blobbase64tostring(blobData, function (b64) {
-         let binData = base64stringtobinaryuint8array(b64);
-         let decoded = pako.inflate(binData);
-         let decodedBlob = new Blob([decoded]);
-         blobbase64tostring(decodedBlob, function (b64Decoded) {
-            let srcValue = `data:image/jpg;base64,${b64Decoded}`;
-            requestAnimationFrame(function () {
-               let monDiv = $(`#${monitorName}-div`);
-               let monImg = $(`#${monitorName}`);
-               monDiv.addClass('d-none');
-               monImg.removeClass('d-none');
-               monImg.attr('src', `${srcValue}`);
-            });
+         let srcValue = `data:image/jpg;base64,${b64}`;
+         requestAnimationFrame(function () {
+            let monDiv = $(`#${monitorName}-div`);
+            let monImg = $(`#${monitorName}`);
+            monDiv.addClass('d-none');
+            monImg.removeClass('d-none');
+            monImg.attr('src', `${srcValue}`);
          });
       });
    };
@@ -152,6 +227,10 @@ function cleanup() {
 }
 
 function cleanupWebSockets() {
+   for (let i = 0; i < peerConnections.length; i++) {
+      peerConnections[i].close();
+   }
+   peerConnections = [];
    for (let i = 0; i < webSockets.length; i++) {
       let ws = webSockets[i];
       if (ws.readyState == WebSocket.OPEN) {
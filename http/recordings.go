@@ -0,0 +1,79 @@
+package http
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+
+	"github.com/jonoton/go-dir"
+	"github.com/jonoton/scout/monitor"
+)
+
+type recordingSummaryResp struct {
+	Name     string
+	Monitor  string
+	Start    string
+	End      string
+	Labels   []monitor.TimelineLabel
+	Sidecar  string
+	Subtitle string
+}
+
+// recordingsSummary responds with the recordings and the summaries of their sidecars
+func (h *Http) recordingsSummary(c *fiber.Ctx, names []string) error {
+	recordDir := filepath.Clean(h.manage.GetDataDirectory() + "/recordings")
+	key := h.manage.GetEncryptionKey()
+	data := make([]recordingSummaryResp, 0, len(names))
+	for _, name := range names {
+		cur := recordingSummaryResp{
+			Name:   name,
+			Labels: make([]monitor.TimelineLabel, 0),
+		}
+		sidecar, err := monitor.ReadRecordingSidecar(key, filepath.Join(recordDir, name))
+		if err == nil {
+			cur.Monitor = sidecar.Monitor
+			cur.Start = sidecar.Start.Format(time.RFC3339)
+			cur.End = sidecar.End.Format(time.RFC3339)
+			cur.Labels = sidecar.Labels
+			cur.Sidecar = monitor.RecordingSidecarPath(name)
+			cur.Subtitle = monitor.RecordingSubtitlePath(name)
+		}
+		data = append(data, cur)
+	}
+	needSort := false
+	for _, cur := range h.linkClients {
+		linkResult := cur.getRecordingsSummary(h.linkRetry)
+		if len(linkResult) > 0 {
+			data = append(data, linkResult...)
+			needSort = true
+		}
+	}
+	if needSort {
+		sort.Slice(data, func(i, j int) bool {
+			return dir.DescendingTimeName{path.Base(data[i].Name), path.Base(data[j].Name)}.Less(0, 1)
+		})
+	}
+	return c.JSON(data)
+}
+
+func (l *linkClient) getRecordingsSummary(numRetries int) []recordingSummaryResp {
+	l.checkNeedLogin()
+	result := make([]recordingSummaryResp, 0)
+	agent := fiber.Get(l.url + "/recordings/list?summary=true").InsecureSkipVerify()
+	l.checkAddAuth(agent)
+	if err := agent.Parse(); err == nil {
+		code, body, _ := agent.Bytes()
+		l.checkClearLogin(code)
+		if code == fiber.StatusOK {
+			json.Unmarshal(body, &result)
+		} else if numRetries > 0 {
+			numRetries--
+			return l.getRecordingsSummary(numRetries)
+		}
+	}
+	return result
+}
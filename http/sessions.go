@@ -0,0 +1,215 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	websocket "github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Stream Kinds
+const (
+	streamKindLive   = "live"
+	streamKindMosaic = "mosaic"
+	streamKindMJPEG  = "mjpeg"
+)
+
+// Stream Errors
+var (
+	errStreamsMax        = errors.New("too many streams")
+	errStreamsMaxPerUser = errors.New("too many streams for user")
+)
+
+// streamSession is an active live stream of one viewer
+type streamSession struct {
+	id        string
+	kind      string
+	user      string
+	monitor   string
+	clientIP  string
+	start     time.Time
+	bytesSent int64
+	cancel    context.CancelFunc
+}
+
+// AddSent records bytes sent to the viewer
+func (s *streamSession) AddSent(n int) {
+	atomic.AddInt64(&s.bytesSent, int64(n))
+}
+
+type streamSessionResp struct {
+	ID        string
+	Kind      string
+	User      string
+	Monitor   string
+	ClientIP  string
+	Start     string
+	BytesSent int64
+}
+
+// streamRegistry tracks the active live streams and enforces the stream caps, where zero is no cap
+type streamRegistry struct {
+	mu         sync.Mutex
+	sessions   map[string]*streamSession
+	max        int
+	maxPerUser int
+}
+
+func newStreamRegistry(max int, maxPerUser int) *streamRegistry {
+	r := &streamRegistry{
+		sessions:   make(map[string]*streamSession),
+		max:        max,
+		maxPerUser: maxPerUser,
+	}
+	return r
+}
+
+// Open registers a stream, where cancel ends it when terminated
+func (r *streamRegistry) Open(kind string, user string, monitor string, clientIP string,
+	cancel context.CancelFunc) (*streamSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.max > 0 && len(r.sessions) >= r.max {
+		return nil, errStreamsMax
+	}
+	if r.maxPerUser > 0 {
+		userCount := 0
+		for _, cur := range r.sessions {
+			if cur.user == user {
+				userCount++
+			}
+		}
+		if userCount >= r.maxPerUser {
+			return nil, errStreamsMaxPerUser
+		}
+	}
+	s := &streamSession{
+		id:       uuid.New().String(),
+		kind:     kind,
+		user:     user,
+		monitor:  monitor,
+		clientIP: clientIP,
+		start:    time.Now(),
+		cancel:   cancel,
+	}
+	r.sessions[s.id] = s
+	return s, nil
+}
+
+// OpenWebsocket registers the websocket stream or closes the websocket with the reason when over the stream caps
+func (r *streamRegistry) OpenWebsocket(c *websocket.Conn, kind string, monitorName string, cancel context.CancelFunc) *streamSession {
+	user, _ := c.Locals("user").(string)
+	clientIP, _ := c.Locals("clientIP").(string)
+	stream, err := r.Open(kind, user, monitorName, clientIP, cancel)
+	if err != nil {
+		log.Warnln("Websocket rejected", kind, monitorName, user, err)
+		m := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error())
+		c.WriteMessage(websocket.CloseMessage, m)
+		return nil
+	}
+	return stream
+}
+
+// Close removes the stream
+func (r *streamRegistry) Close(s *streamSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, s.id)
+}
+
+// Terminate ends the stream and returns false when not found
+func (r *streamRegistry) Terminate(id string) bool {
+	r.mu.Lock()
+	s, found := r.sessions[id]
+	r.mu.Unlock()
+	if !found {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+// List returns the streams of the user oldest first, or all streams when user is empty
+func (r *streamRegistry) List(user string) []streamSessionResp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	selected := make([]*streamSession, 0, len(r.sessions))
+	for _, cur := range r.sessions {
+		if user == "" || cur.user == user {
+			selected = append(selected, cur)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].start.Before(selected[j].start)
+	})
+	result := make([]streamSessionResp, 0, len(selected))
+	for _, cur := range selected {
+		result = append(result, streamSessionResp{
+			ID:        cur.id,
+			Kind:      cur.kind,
+			User:      cur.user,
+			Monitor:   cur.monitor,
+			ClientIP:  cur.clientIP,
+			Start:     cur.start.Format(time.RFC3339),
+			BytesSent: atomic.LoadInt64(&cur.bytesSent),
+		})
+	}
+	return result
+}
+
+// isAdmin returns true when the request's user is an admin or login is disabled
+func (h *Http) isAdmin(c *fiber.Ctx) bool {
+	if !h.loginNeeded {
+		return true
+	}
+	user := h.requestUser(c)
+	for _, cur := range h.httpConfig.Users {
+		if cur.User == user {
+			return cur.Admin
+		}
+	}
+	return false
+}
+
+// sessionsLiveHandler lists the active live streams
+// @Summary List live streams
+// @Description Get the active live websocket, mosaic, MJPEG, WebRTC, and HLS streams, oldest first. Admins see all streams and other users see their own.
+// @Tags Sessions
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} streamSessionResp "Active streams"
+// @Router /sessions/live [get]
+func (h *Http) sessionsLiveHandler(c *fiber.Ctx) error {
+	user := ""
+	if !h.isAdmin(c) {
+		user = h.requestUser(c)
+	}
+	return c.JSON(h.streams.List(user))
+}
+
+// sessionsLiveTerminateHandler ends a live stream
+// @Summary Terminate live stream
+// @Description End an active live stream. Requires an admin user.
+// @Tags Sessions
+// @Security ApiKeyAuth
+// @Param id path string true "Stream ID"
+// @Success 204 {string} string "Terminated"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Router /sessions/live/{id} [delete]
+func (h *Http) sessionsLiveTerminateHandler(c *fiber.Ctx) error {
+	if !h.isAdmin(c) {
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+	if !h.streams.Terminate(c.Params("id")) {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/jonoton/scout/monitor"
+)
+
+// snapshotHandler returns the latest frame of a monitor
+// @Summary Monitor snapshot
+// @Description Get a JPEG of the most recent frame of a monitor, including monitors of linked Scout instances.
+// @Tags Monitor
+// @Produce jpeg
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Param width query int false "Width"
+// @Param quality query int false "JPEG Quality"
+// @Param highlight query bool false "Draw the live overlay layers"
+// @Param token query string false "Auth Token"
+// @Success 200 {file} file "JPEG image"
+// @Failure 404 {string} string "Not Found"
+// @Failure 503 {string} string "No frame yet"
+// @Router /snapshot/{name} [get]
+func (h *Http) snapshotHandler(c *fiber.Ctx) error {
+	monitorName := c.Params("name")
+	// local monitors first, as a linked server may have a monitor of the same name
+	latest := h.manage.GetMonitorLatestFrame(monitorName, 1000)
+	if latest == nil {
+		for _, cur := range h.linkClients {
+			found, linkResult := cur.getSnapshot(monitorName, linkQueryString(c), h.linkRetry)
+			if found {
+				c.Set(fiber.HeaderContentType, "image/jpeg")
+				c.Set(fiber.HeaderCacheControl, "no-store")
+				return c.Send(linkResult)
+			}
+		}
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	img := latest.Get()
+	if img == nil {
+		return c.SendStatus(fiber.StatusServiceUnavailable)
+	}
+	defer img.Cleanup()
+	if !img.Original.IsFilled() {
+		return c.SendStatus(fiber.StatusServiceUnavailable)
+	}
+	selected := img.Original.Ref()
+	if c.QueryBool("highlight") {
+		overlay := h.manage.GetMonitorOverlay(monitorName, 1000)
+		if overlay == nil {
+			overlay = monitor.NewOverlay(monitorName, nil)
+		}
+		selected.Cleanup()
+		selected = overlay.Live(img)
+	}
+	scaled := selected.ScaleToWidth(c.QueryInt("width", 0))
+	selected.Cleanup()
+	imgArray := scaled.EncodedQuality(c.QueryInt("quality", 90))
+	scaled.Cleanup()
+	c.Set(fiber.HeaderContentType, "image/jpeg")
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderLastModified, img.Original.CreatedTime().UTC().Format(http.TimeFormat))
+	return c.Send(imgArray)
+}
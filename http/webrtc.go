@@ -0,0 +1,174 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/pion/webrtc/v4"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/monitor"
+	"github.com/jonoton/scout/rtc"
+)
+
+const (
+	streamKindWebRTC      = "webrtc"
+	webrtcConnectTimeout  = 15 * time.Second
+	webrtcFallbackMessage = "use the live websocket"
+)
+
+// webrtcHandler answers a WebRTC offer with a H.264 stream of the monitor
+// @Summary Live monitor WebRTC
+// @Description Answer a WebRTC offer with a H.264 video track of a monitor. Clients should fall back to the live websocket when this fails or the connection is not established.
+// @Tags Monitor
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Monitor Name"
+// @Param width query int false "Width"
+// @Param overlay query bool false "Draw the live overlay layers (default true)"
+// @Param offer body object true "Session description offer"
+// @Success 200 {object} object "Session description answer"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found or WebRTC disabled"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Negotiation failed"
+// @Router /webrtc/{name} [post]
+func (h *Http) webrtcHandler(c *fiber.Ctx) error {
+	monitorName := c.Params("name")
+	if h.rtc == nil {
+		return c.Status(fiber.StatusNotFound).SendString(webrtcFallbackMessage)
+	}
+	for _, cur := range h.linkClients {
+		for _, lmonName := range cur.monitorNames {
+			if lmonName == monitorName {
+				return c.Status(fiber.StatusNotFound).SendString(webrtcFallbackMessage)
+			}
+		}
+	}
+	var offer webrtc.SessionDescription
+	if err := c.BodyParser(&offer); err != nil || offer.Type != webrtc.SDPTypeOffer {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	width := c.QueryInt("width", h.rtc.Width)
+	highlight := c.QueryBool("overlay", true)
+
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	stream, err := h.streams.Open(streamKindWebRTC, h.requestUser(c), monitorName, c.IP(), streamCancel)
+	if err != nil {
+		streamCancel()
+		return c.Status(fiber.StatusTooManyRequests).SendString(err.Error())
+	}
+	imagesSub := h.manage.Subscribe(monitorName, 500, 1)
+	if imagesSub == nil {
+		h.streams.Close(stream)
+		streamCancel()
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	liveTracker := h.manage.GetMonitorLiveTracker(monitorName, 500)
+	if liveTracker == nil {
+		liveTracker = monitor.NewProcessTracker("live")
+	}
+	overlay := h.manage.GetMonitorOverlay(monitorName, 500)
+	if overlay == nil {
+		overlay = monitor.NewOverlay(monitorName, nil)
+	}
+	source := newLatestSource(streamCtx, imagesSub, liveTracker)
+	peer, err := h.rtc.Answer(offer)
+	if err != nil {
+		log.Warnln("WebRTC negotiation failed", monitorName, err)
+		streamCancel()
+		source.Close()
+		h.streams.Close(stream)
+		return c.Status(fiber.StatusInternalServerError).SendString(webrtcFallbackMessage)
+	}
+	go func() {
+		defer func() {
+			peer.Close()
+			streamCancel()
+			source.Close()
+			h.streams.Close(stream)
+		}()
+		h.runWebRTC(streamCtx, monitorName, stream, peer, source, liveTracker, overlay, highlight, width)
+	}()
+	return c.JSON(peer.LocalDescription())
+}
+
+// runWebRTC sends the newest frame at the configured rate until the peer or the subscription ends
+func (h *Http) runWebRTC(ctx context.Context, monitorName string, stream *streamSession, peer *rtc.Peer,
+	source *latestSource, tracker *monitor.ProcessTracker, overlay *monitor.Overlay, highlight bool, width int) {
+	select {
+	case <-peer.Connected():
+	case <-peer.Done():
+		return
+	case <-ctx.Done():
+		return
+	case <-time.After(webrtcConnectTimeout):
+		log.Warnln("WebRTC connect timeout", monitorName)
+		return
+	}
+	encoder, err := rtc.NewH264Encoder(h.rtc.Fps, h.rtc.Codec)
+	if err != nil {
+		log.Errorln("WebRTC could not create encoder", monitorName, err)
+		return
+	}
+	samplesWg := &sync.WaitGroup{}
+	samplesWg.Add(1)
+	go func() {
+		defer samplesWg.Done()
+		for sample := range encoder.Samples() {
+			if peer.WriteSample(sample) == nil {
+				stream.AddSent(len(sample.Data))
+			}
+		}
+	}()
+	var current *videosource.ProcessedImage
+	defer func() {
+		if current != nil {
+			current.Cleanup()
+		}
+		encoder.Close()
+		samplesWg.Wait()
+		log.Infoln("WebRTC closed", monitorName, stream.id)
+	}()
+	log.Infoln("WebRTC opened", monitorName, stream.id)
+
+	frameTick := time.NewTicker(time.Second / time.Duration(h.rtc.Fps))
+	defer frameTick.Stop()
+	ringBufferChan := source.Chan()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-peer.Done():
+			return
+		case <-source.Done():
+			return
+		case img, ok := <-ringBufferChan:
+			if !ok {
+				return
+			}
+			source.Taken()
+			if current != nil {
+				current.Cleanup()
+			}
+			current = img
+		case <-frameTick.C:
+			if current == nil || !current.Original.IsFilled() {
+				continue
+			}
+			start := time.Now()
+			frame := renderVideoFrame(current, overlay, highlight, width)
+			err := encoder.Write(frame.SharedMat.Mat)
+			frame.Cleanup()
+			if err != nil {
+				log.Errorln("WebRTC encode failed", monitorName, err)
+				return
+			}
+			tracker.Out(start)
+		}
+	}
+}
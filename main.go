@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jonoton/go-runtime"
 	"github.com/jonoton/scout/http"
+	"github.com/jonoton/scout/manage"
+	"github.com/jonoton/scout/seal"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -37,18 +41,80 @@ func handleArgs() bool {
 			}
 			return true
 		}
+		if os.Args[1] == "--migrate-data" {
+			cfgPath := runtime.GetRuntimeDirectory(".config") + manage.ConfigFilename
+			conf := manage.NewConfig(cfgPath)
+			if conf == nil {
+				log.Fatalf("Required config file %s not found.", cfgPath)
+			}
+			moved, err := manage.MigrateData(conf)
+			if err != nil {
+				log.Fatalf("Failed to migrate data after moving %d files: %v", moved, err)
+			}
+			log.Infof("Migrated %d files", moved)
+			return true
+		}
+		if os.Args[1] == "--generate-key" && len(os.Args) == 3 {
+			if err := seal.GenerateKeyFile(os.Args[2]); err != nil {
+				log.Fatalf("Failed to generate key file: %v", err)
+			}
+			log.Infof("Generated key file %s", os.Args[2])
+			return true
+		}
+		if os.Args[1] == "--decrypt" && len(os.Args) >= 4 && len(os.Args) <= 6 {
+			key, err := decryptKey(os.Args[4:])
+			if err != nil {
+				log.Fatalf("Failed to load the encryption key: %v", err)
+			}
+			written, err := key.DecryptPath(os.Args[2], os.Args[3])
+			if err != nil {
+				log.Fatalf("Failed to decrypt after writing %d files: %v", written, err)
+			}
+			log.Infof("Decrypted %d files to %s", written, os.Args[3])
+			return true
+		}
 		if os.Args[1] == "--version" {
 			if Version != "" {
 				fmt.Println(Version)
 			}
 			return true
 		}
-		log.Printf("How to run:\n\t%s\n\t%s --version\n\t%s --secure-http-passwords\n", os.Args[0], os.Args[0], os.Args[0])
+		log.Printf("How to run:\n\t%s\n\t%s --version\n\t%s --secure-http-passwords\n\t%s --migrate-data\n"+
+			"\t%s --generate-key <key file>\n"+
+			"\t%s --decrypt <file or directory> <output> [--key-file <key file> | --passphrase]\n",
+			os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 		return true
 	}
 	return false
 }
 
+// decryptKey returns the key from the --key-file or --passphrase option, or else from the manage config
+func decryptKey(options []string) (*seal.Key, error) {
+	if len(options) == 2 && options[0] == "--key-file" {
+		return seal.ReadKeyFile(options[1])
+	}
+	if len(options) == 1 && options[0] == "--passphrase" {
+		fmt.Print("Passphrase: ")
+		passphrase, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && passphrase == "" {
+			return nil, err
+		}
+		return seal.NewPassphraseKey(strings.TrimRight(passphrase, "\r\n"))
+	}
+	if len(options) > 0 {
+		return nil, fmt.Errorf("unknown option %s", strings.Join(options, " "))
+	}
+	cfgPath := runtime.GetRuntimeDirectory(".config") + manage.ConfigFilename
+	conf := manage.NewConfig(cfgPath)
+	if conf == nil {
+		return nil, fmt.Errorf("required config file %s not found", cfgPath)
+	}
+	if conf.Encryption == nil {
+		return nil, fmt.Errorf("no encryption configured in %s", cfgPath)
+	}
+	return seal.LoadKey(conf.Encryption)
+}
+
 func printBanner() {
 	banner := `
   ⢀⣠⣴⣶⣴⣤⡀⠀⠀⠀⠀⠀⠀⠀⢀⠀⠀⠀⠀⠀
@@ -0,0 +1,32 @@
+package manage
+
+import (
+	"io"
+
+	"github.com/jonoton/scout/archive"
+)
+
+// GetArchivedFiles returns the archived files of the category
+func (m *Manage) GetArchivedFiles(category string) []archive.Entry {
+	if m.archiver == nil {
+		return make([]archive.Entry, 0)
+	}
+	return m.archiver.List(category)
+}
+
+// FindArchivedFile returns the archived file at the slash path relative to the category directory,
+// or with its filename for paths from before the per-monitor layout, or nil when not archived
+func (m *Manage) FindArchivedFile(category string, rel string) *archive.Entry {
+	if m.archiver == nil {
+		return nil
+	}
+	return m.archiver.Find(category, rel)
+}
+
+// OpenArchivedFile reads the archived file at the slash path relative to the category directory from offset
+func (m *Manage) OpenArchivedFile(category string, rel string, offset int64) (io.ReadCloser, *archive.Entry, error) {
+	if m.archiver == nil {
+		return nil, nil, archive.ErrNotArchived
+	}
+	return m.archiver.Open(category, rel, offset)
+}
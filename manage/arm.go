@@ -0,0 +1,90 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+// ArmStateFilename is the file in the data directory persisting the monitor arm states
+var ArmStateFilename = "arm.yaml"
+
+type armRequest struct {
+	monitorName string
+	armState    monitor.ArmState
+}
+
+func (m *Manage) armStatePath() string {
+	if m.manageConf.Data == "" {
+		return ""
+	}
+	return filepath.Join(m.manageConf.Data, ArmStateFilename)
+}
+
+func loadArmStates(armStatePath string) map[string]monitor.ArmState {
+	result := make(map[string]monitor.ArmState)
+	if armStatePath == "" {
+		return result
+	}
+	yamlFile, err := os.ReadFile(armStatePath)
+	if err != nil {
+		return result
+	}
+	if err = yaml.Unmarshal(yamlFile, &result); err != nil {
+		log.Errorf("Could not read arm states %s: %v", armStatePath, err)
+	}
+	return result
+}
+
+func saveArmStates(armStatePath string, armStates map[string]monitor.ArmState) {
+	if armStatePath == "" {
+		return
+	}
+	data, err := yaml.Marshal(armStates)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	tmpPath := armStatePath + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil {
+		log.Errorf("Could not save arm states %s: %v", armStatePath, err)
+		return
+	}
+	if err = os.Rename(tmpPath, armStatePath); err != nil {
+		log.Errorf("Could not save arm states %s: %v", armStatePath, err)
+	}
+}
+
+func (m *Manage) getArmState(monitorName string) monitor.ArmState {
+	if armState, found := m.armStates[monitorName]; found {
+		return armState
+	}
+	return monitor.ArmState{Armed: true}
+}
+
+// setArmState applies the arm state to the monitor or all monitors when the name is empty
+func (m *Manage) setArmState(req armRequest) (found bool) {
+	for _, conf := range m.manageConf.Monitors {
+		if req.monitorName != "" && conf.Name != req.monitorName {
+			continue
+		}
+		found = true
+		m.armStates[conf.Name] = req.armState
+		if mon, running := m.mons[conf.Name]; running {
+			mon.SetArmState(req.armState)
+		}
+		if req.armState.Armed {
+			log.Infoln("Armed monitor", conf.Name)
+		} else {
+			log.Infoln("Disarmed monitor", conf.Name)
+		}
+	}
+	if found {
+		saveArmStates(m.armStatePath(), m.armStates)
+	}
+	return
+}
@@ -7,6 +7,9 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/jonoton/scout/archive"
+	"github.com/jonoton/scout/monitor"
+	"github.com/jonoton/scout/seal"
 	"gopkg.in/yaml.v2"
 )
 
@@ -20,10 +23,30 @@ type mon struct {
 	ConfigPath string `yaml:"config"`
 }
 
+type mode struct {
+	Name     string                    `yaml:"name"`
+	Default  monitor.Policy            `yaml:"default"`
+	Monitors map[string]monitor.Policy `yaml:"monitors,omitempty"`
+}
+
+// StorageConfig contains the global retention of the data directory
+type StorageConfig struct {
+	MaxGB            float64        `yaml:"maxGB,omitempty"`
+	MinFreePercent   float64        `yaml:"minFreePercent,omitempty"`
+	IntervalMinutes  int            `yaml:"intervalMinutes,omitempty"`
+	PruneOrder       []string       `yaml:"pruneOrder,omitempty"`
+	MinRetentionDays map[string]int `yaml:"minRetentionDays,omitempty"`
+}
+
 // Config contains the parameters for Manage
 type Config struct {
-	Data     string `yaml:"data,omitempty"`
-	Monitors []mon  `yaml:"monitors"`
+	Data        string          `yaml:"data,omitempty"`
+	Monitors    []mon           `yaml:"monitors"`
+	Modes       []mode          `yaml:"modes,omitempty"`
+	DefaultMode string          `yaml:"defaultMode,omitempty"`
+	Storage     *StorageConfig  `yaml:"storage,omitempty"`
+	Archive     *archive.Config `yaml:"archive,omitempty"`
+	Encryption  *seal.Config    `yaml:"encryption,omitempty"`
 }
 
 // NewConfig creates a new Config
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package manage
+
+import "syscall"
+
+// diskUsage returns the total and available bytes of the file system of path
+func diskUsage(path string) (total uint64, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err = syscall.Statfs(path, &st); err != nil {
+		return
+	}
+	total = st.Blocks * uint64(st.Bsize)
+	free = st.Bavail * uint64(st.Bsize)
+	return
+}
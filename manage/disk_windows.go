@@ -0,0 +1,11 @@
+//go:build windows
+
+package manage
+
+import "errors"
+
+// diskUsage is not supported on windows, so the free disk floor is not enforced
+func diskUsage(path string) (total uint64, free uint64, err error) {
+	err = errors.New("free disk space not supported on windows")
+	return
+}
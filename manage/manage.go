@@ -3,14 +3,15 @@
 package manage
 
 import (
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"sync"
 	"time"
 
 	pubsubmutex "github.com/jonoton/go-pubsubmutex"
-	"github.com/jonoton/scout/face"
-	"github.com/jonoton/scout/motion"
-	"github.com/jonoton/scout/tensor"
 
 	"github.com/jonoton/go-watcher"
 	log "github.com/sirupsen/logrus"
@@ -18,7 +19,10 @@ import (
 	"github.com/jonoton/go-notify"
 	"github.com/jonoton/go-runtime"
 	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/archive"
+	"github.com/jonoton/scout/eventdb"
 	"github.com/jonoton/scout/monitor"
+	"github.com/jonoton/scout/seal"
 )
 
 const topicAddMon = "topic-add-mon"
@@ -28,16 +32,41 @@ const topicGetMonitorNames = "topic-get-monitor-names"
 const topicCurrentMonitorNames = "topic-current-monitor-names"
 const topicGetMonitorFrameStats = "topic-get-monitor-frame-stats"
 const topicCurrentMonitorFrameStats = "topic-current-monitor-frame-stats"
+const topicGetMonitorPipelineStats = "topic-get-monitor-pipeline-stats"
+const topicCurrentMonitorPipelineStats = "topic-current-monitor-pipeline-stats"
+const topicGetMonitorLiveTracker = "topic-get-monitor-live-tracker"
+const topicCurrentMonitorLiveTracker = "topic-current-monitor-live-tracker"
+const topicGetMonitorLatestFrame = "topic-get-monitor-latest-frame"
+const topicCurrentMonitorLatestFrame = "topic-current-monitor-latest-frame"
+const topicGetMonitorOverlay = "topic-get-monitor-overlay"
+const topicCurrentMonitorOverlay = "topic-current-monitor-overlay"
 const topicGetMonitorAlertTimes = "topic-get-monitor-alert-times"
 const topicCurrentMonitorAlertTimes = "topic-current-monitor-alert-times"
+const topicMonitorEvents = "topic-monitor-events"
+const topicSetMonitorArmState = "topic-set-monitor-arm-state"
+const topicCurrentMonitorArmStateSet = "topic-current-monitor-arm-state-set"
+const topicGetMonitorArmState = "topic-get-monitor-arm-state"
+const topicCurrentMonitorArmState = "topic-current-monitor-arm-state"
+const topicSetMode = "topic-set-mode"
+const topicCurrentModeSet = "topic-current-mode-set"
+const topicGetMode = "topic-get-mode"
+const topicCurrentMode = "topic-current-mode"
 
 // Manage contains all the monitors and manages them
 type Manage struct {
 	mons             monitor.Map
+	sharedReaders    map[string]*sharedSource
 	manageConf       Config
 	notifySenderConf *notify.SenderConfig
 	Notifier         *notify.Notify
 	wtr              *watcher.Watcher
+	eventDB          *eventdb.EventDB
+	storage          *storage
+	archiver         *archive.Archiver
+	key              *seal.Key
+	armStates        map[string]monitor.ArmState
+	activeMode       string
+	modeLogger       *stdlog.Logger
 	pubsub           pubsubmutex.PubSub
 	cancel           chan bool
 	cancelOnce       sync.Once
@@ -54,10 +83,18 @@ func NewManage() *Manage {
 
 	m := &Manage{
 		mons:             make(monitor.Map),
+		sharedReaders:    make(map[string]*sharedSource),
 		manageConf:       *conf,
 		notifySenderConf: notify.NewSenderConfig(runtime.GetRuntimeDirectory(".config") + notify.SenderConfigFilename),
 		Notifier:         nil,
 		wtr:              watcher.New(500 * time.Millisecond),
+		eventDB:          nil,
+		storage:          nil,
+		archiver:         nil,
+		key:              nil,
+		armStates:        nil,
+		activeMode:       "",
+		modeLogger:       newModeLogger(),
 		pubsub:           *pubsubmutex.NewPubSub(),
 		cancel:           make(chan bool),
 		done:             make(chan bool),
@@ -68,6 +105,32 @@ func NewManage() *Manage {
 			m.notifySenderConf.User,
 			m.notifySenderConf.Password)
 	}
+	key, err := seal.LoadKey(m.manageConf.Encryption)
+	if err != nil {
+		log.Fatalf("Could not load the encryption key: %v", err)
+	}
+	m.key = key
+	if m.manageConf.Data != "" {
+		os.MkdirAll(m.manageConf.Data, os.ModePerm)
+		m.eventDB = eventdb.NewEventDB(filepath.Join(m.manageConf.Data, eventdb.Filename))
+		m.storage = newStorage(m.manageConf.Data, m.manageConf.Storage)
+		m.storage.events = m.eventDB
+		m.storage.key = m.key
+		if m.manageConf.Archive != nil {
+			archiver, err := archive.NewArchiver(m.manageConf.Data, m.manageConf.Archive, m.storage.protectedGroup)
+			if err != nil {
+				log.Errorln("Could not create archive", err)
+			} else {
+				m.archiver = archiver
+				m.storage.archived = archiver.Archived
+				m.storage.findArchived = func(category string, rel string) bool {
+					return archiver.Find(category, rel) != nil
+				}
+			}
+		}
+	}
+	m.armStates = loadArmStates(m.armStatePath())
+	m.activeMode = m.loadActiveMode()
 	pubsubmutex.RegisterTopic[*monitor.Monitor](&m.pubsub, topicAddMon)
 	pubsubmutex.RegisterTopic[*monitor.Monitor](&m.pubsub, topicRemoveMon)
 	pubsubmutex.RegisterTopic[subscribeMonitor](&m.pubsub, topicGetMonitorSubscribe)
@@ -75,8 +138,25 @@ func NewManage() *Manage {
 	pubsubmutex.RegisterTopic[[]string](&m.pubsub, topicCurrentMonitorNames)
 	pubsubmutex.RegisterTopic[string](&m.pubsub, topicGetMonitorFrameStats)
 	pubsubmutex.RegisterTopic[*videosource.FrameStatsCombo](&m.pubsub, topicCurrentMonitorFrameStats)
+	pubsubmutex.RegisterTopic[string](&m.pubsub, topicGetMonitorPipelineStats)
+	pubsubmutex.RegisterTopic[*monitor.PipelineStats](&m.pubsub, topicCurrentMonitorPipelineStats)
+	pubsubmutex.RegisterTopic[string](&m.pubsub, topicGetMonitorLiveTracker)
+	pubsubmutex.RegisterTopic[*monitor.ProcessTracker](&m.pubsub, topicCurrentMonitorLiveTracker)
+	pubsubmutex.RegisterTopic[string](&m.pubsub, topicGetMonitorLatestFrame)
+	pubsubmutex.RegisterTopic[*monitor.LatestFrame](&m.pubsub, topicCurrentMonitorLatestFrame)
+	pubsubmutex.RegisterTopic[string](&m.pubsub, topicGetMonitorOverlay)
+	pubsubmutex.RegisterTopic[*monitor.Overlay](&m.pubsub, topicCurrentMonitorOverlay)
 	pubsubmutex.RegisterTopic[any](&m.pubsub, topicGetMonitorAlertTimes)
 	pubsubmutex.RegisterTopic[map[string]monitor.AlertTimes](&m.pubsub, topicCurrentMonitorAlertTimes)
+	pubsubmutex.RegisterTopic[monitor.Event](&m.pubsub, topicMonitorEvents)
+	pubsubmutex.RegisterTopic[armRequest](&m.pubsub, topicSetMonitorArmState)
+	pubsubmutex.RegisterTopic[bool](&m.pubsub, topicCurrentMonitorArmStateSet)
+	pubsubmutex.RegisterTopic[string](&m.pubsub, topicGetMonitorArmState)
+	pubsubmutex.RegisterTopic[*monitor.ArmState](&m.pubsub, topicCurrentMonitorArmState)
+	pubsubmutex.RegisterTopic[modeRequest](&m.pubsub, topicSetMode)
+	pubsubmutex.RegisterTopic[bool](&m.pubsub, topicCurrentModeSet)
+	pubsubmutex.RegisterTopic[any](&m.pubsub, topicGetMode)
+	pubsubmutex.RegisterTopic[ModeInfo](&m.pubsub, topicCurrentMode)
 
 	return m
 }
@@ -88,12 +168,74 @@ func (m *Manage) AddMonitor(mon *monitor.Monitor) {
 func (m *Manage) addMonitor(mon *monitor.Monitor) {
 	log.Infoln("Add monitor", mon.Name)
 	m.mons[mon.Name] = mon
+	if m.storage != nil {
+		m.storage.SetRetentions(mon.Name, mon.Retentions())
+	}
 	for _, pathName := range mon.ConfigPaths {
 		m.wtr.Watch(pathName)
 	}
+	if eventSub := mon.SubscribeEvents(10); eventSub != nil {
+		go m.forwardEvents(eventSub)
+	}
 	mon.Start()
 }
 
+// SubscribeEvents returns a subscriber for the event changes of all monitors
+func (m *Manage) SubscribeEvents(bufferSize int) (result *pubsubmutex.Subscriber[monitor.Event]) {
+	r, err := pubsubmutex.Subscribe[monitor.Event](&m.pubsub, topicMonitorEvents, m.pubsub.GetUniqueSubscriberID(), bufferSize)
+	if err == nil && r != nil {
+		result = r
+	}
+	return
+}
+
+// QueryEvents returns a page of stored events newest first and the total number matched
+func (m *Manage) QueryEvents(query eventdb.Query) (result []monitor.Event, total int) {
+	if m.eventDB == nil {
+		return make([]monitor.Event, 0), 0
+	}
+	return m.eventDB.Query(query)
+}
+
+// GetEvent returns the stored event or nil when not found
+func (m *Manage) GetEvent(id string) *monitor.Event {
+	if m.eventDB == nil {
+		return nil
+	}
+	return m.eventDB.Get(id)
+}
+
+func (m *Manage) storeEvents(eventSub *pubsubmutex.Subscriber[monitor.Event], stop chan bool, done chan bool) {
+	defer close(done)
+	for {
+		select {
+		case msg, ok := <-eventSub.Ch:
+			if !ok {
+				return
+			}
+			m.storeEvent(msg.Data)
+		case <-stop:
+			for len(eventSub.Ch) > 0 {
+				msg := <-eventSub.Ch
+				m.storeEvent(msg.Data)
+			}
+			return
+		}
+	}
+}
+
+func (m *Manage) storeEvent(event monitor.Event) {
+	if err := m.eventDB.Put(event); err != nil {
+		log.Errorf("Could not store event %s: %v", event.ID, err)
+	}
+}
+
+func (m *Manage) forwardEvents(eventSub *pubsubmutex.Subscriber[monitor.Event]) {
+	for msg := range eventSub.Ch {
+		pubsubmutex.Publish(&m.pubsub, pubsubmutex.Message[monitor.Event]{Topic: topicMonitorEvents, Data: msg.Data})
+	}
+}
+
 // GetMonitorNames returns a list of monitor names
 func (m *Manage) GetMonitorNames(timeoutMs int) (result []string) {
 	r, ok := pubsubmutex.SendReceive[any, []string](&m.pubsub,
@@ -135,6 +277,159 @@ func (m *Manage) pubMonitorFrameStats(monitorName string) {
 	}
 }
 
+// GetMonitorPipelineStats returns the monitor's pipeline stage and sink stats
+func (m *Manage) GetMonitorPipelineStats(monitorName string, timeoutMs int) (result *monitor.PipelineStats) {
+	r, ok := pubsubmutex.SendReceive[string, *monitor.PipelineStats](&m.pubsub,
+		topicGetMonitorPipelineStats, topicCurrentMonitorPipelineStats,
+		monitorName, timeoutMs)
+	if ok && r != nil {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubMonitorPipelineStats(monitorName string) {
+	var stats *monitor.PipelineStats
+	if mon, found := m.mons[monitorName]; found {
+		stats = mon.GetPipelineStats()
+	}
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[*monitor.PipelineStats]{Topic: topicCurrentMonitorPipelineStats, Data: stats})
+}
+
+// GetMonitorLiveTracker returns the tracker used by the monitor's live subscribers
+func (m *Manage) GetMonitorLiveTracker(monitorName string, timeoutMs int) (result *monitor.ProcessTracker) {
+	r, ok := pubsubmutex.SendReceive[string, *monitor.ProcessTracker](&m.pubsub,
+		topicGetMonitorLiveTracker, topicCurrentMonitorLiveTracker,
+		monitorName, timeoutMs)
+	if ok && r != nil {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubMonitorLiveTracker(monitorName string) {
+	var tracker *monitor.ProcessTracker
+	if mon, found := m.mons[monitorName]; found {
+		tracker = mon.GetLiveTracker()
+	}
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[*monitor.ProcessTracker]{Topic: topicCurrentMonitorLiveTracker, Data: tracker})
+}
+
+// GetMonitorLatestFrame returns the holder of the monitor's most recent processed image
+func (m *Manage) GetMonitorLatestFrame(monitorName string, timeoutMs int) (result *monitor.LatestFrame) {
+	r, ok := pubsubmutex.SendReceive[string, *monitor.LatestFrame](&m.pubsub,
+		topicGetMonitorLatestFrame, topicCurrentMonitorLatestFrame,
+		monitorName, timeoutMs)
+	if ok && r != nil {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubMonitorLatestFrame(monitorName string) {
+	var latest *monitor.LatestFrame
+	if mon, found := m.mons[monitorName]; found {
+		latest = mon.GetLatestFrame()
+	}
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[*monitor.LatestFrame]{Topic: topicCurrentMonitorLatestFrame, Data: latest})
+}
+
+// GetMonitorOverlay returns the overlay used by the monitor's outputs
+func (m *Manage) GetMonitorOverlay(monitorName string, timeoutMs int) (result *monitor.Overlay) {
+	r, ok := pubsubmutex.SendReceive[string, *monitor.Overlay](&m.pubsub,
+		topicGetMonitorOverlay, topicCurrentMonitorOverlay,
+		monitorName, timeoutMs)
+	if ok && r != nil {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubMonitorOverlay(monitorName string) {
+	var overlay *monitor.Overlay
+	if mon, found := m.mons[monitorName]; found {
+		overlay = mon.GetOverlay()
+	}
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[*monitor.Overlay]{Topic: topicCurrentMonitorOverlay, Data: overlay})
+}
+
+// SetMonitorArmState arms or disarms the monitor, or all monitors when the name is empty.
+// Returns false when the monitor is not found.
+func (m *Manage) SetMonitorArmState(monitorName string, armState monitor.ArmState, timeoutMs int) (result bool) {
+	r, ok := pubsubmutex.SendReceive[armRequest, bool](&m.pubsub,
+		topicSetMonitorArmState, topicCurrentMonitorArmStateSet,
+		armRequest{monitorName: monitorName, armState: armState}, timeoutMs)
+	if ok {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubMonitorArmStateSet(req armRequest) {
+	found := m.setArmState(req)
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[bool]{Topic: topicCurrentMonitorArmStateSet, Data: found})
+}
+
+// GetMonitorArmState returns the monitor's arm state
+func (m *Manage) GetMonitorArmState(monitorName string, timeoutMs int) (result *monitor.ArmState) {
+	r, ok := pubsubmutex.SendReceive[string, *monitor.ArmState](&m.pubsub,
+		topicGetMonitorArmState, topicCurrentMonitorArmState,
+		monitorName, timeoutMs)
+	if ok && r != nil {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubMonitorArmState(monitorName string) {
+	var armState *monitor.ArmState
+	if found, _ := m.getMonitorConf(monitorName); found {
+		cur := m.getArmState(monitorName)
+		armState = &cur
+	}
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[*monitor.ArmState]{Topic: topicCurrentMonitorArmState, Data: armState})
+}
+
+// SetMode switches all monitors to the named mode policies.
+// The source fields describe who switched for the audit log. Returns false when the mode is not found.
+func (m *Manage) SetMode(name string, source []string, timeoutMs int) (result bool) {
+	r, ok := pubsubmutex.SendReceive[modeRequest, bool](&m.pubsub,
+		topicSetMode, topicCurrentModeSet,
+		modeRequest{name: name, source: source}, timeoutMs)
+	if ok {
+		result = r
+	}
+	return
+}
+
+func (m *Manage) pubModeSet(req modeRequest) {
+	found := m.setMode(req)
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[bool]{Topic: topicCurrentModeSet, Data: found})
+}
+
+// GetMode returns the active mode and the configured modes
+func (m *Manage) GetMode(timeoutMs int) (result *ModeInfo) {
+	r, ok := pubsubmutex.SendReceive[any, ModeInfo](&m.pubsub,
+		topicGetMode, topicCurrentMode,
+		nil, timeoutMs)
+	if ok {
+		result = &r
+	}
+	return
+}
+
+func (m *Manage) pubMode() {
+	pubsubmutex.Publish(&m.pubsub,
+		pubsubmutex.Message[ModeInfo]{Topic: topicCurrentMode, Data: m.getModeInfo()})
+}
+
 // GetMonitorAlertTimes returns all monitor alert times
 func (m *Manage) GetMonitorAlertTimes(timeoutMs int) (result map[string]monitor.AlertTimes) {
 	r, ok := pubsubmutex.SendReceive[any, map[string]monitor.AlertTimes](&m.pubsub,
@@ -159,6 +454,11 @@ func (m *Manage) GetDataDirectory() string {
 	return m.manageConf.Data
 }
 
+// GetEncryptionKey returns the key of sealed files or nil when encryption is off
+func (m *Manage) GetEncryptionKey() *seal.Key {
+	return m.key
+}
+
 // Start runs the processes
 func (m *Manage) Start() {
 	m.run()
@@ -173,41 +473,110 @@ func (m *Manage) addAllMonitors() {
 	}
 }
 
-func (m *Manage) setupMonitor(name string, configPath string) (mon *monitor.Monitor) {
-	if configPath == "" {
-		return
-	}
-	runtimeConfigDir := runtime.GetRuntimeDirectory(".config")
-	monConfigPath := runtimeConfigDir + configPath
-	monConf := monitor.NewConfig(monConfigPath)
-	if monConf == nil {
-		log.Errorf("Could not setup %s. monitor.NewConfig returned nil for %s", name, monConfigPath)
-		return
-	}
+func newVideoReader(name string, filename string, url string, monConf *monitor.Config) *videosource.VideoReader {
 	var video videosource.VideoSource
-	if monConf.Filename != "" {
-		video = videosource.NewFileSource(monConf.Filename, monConf.Filename)
-	} else if monConf.URL != "" {
-		ipcamSource := videosource.NewIPCamSource(name, monConf.URL)
+	if filename != "" {
+		video = videosource.NewFileSource(filename, filename)
+	} else if url != "" {
+		ipcamSource := videosource.NewIPCamSource(name, url)
 		if monConf.CaptureTimeoutMilliSeconds > 0 {
 			ipcamSource.SetCaptureTimeoutMs(monConf.CaptureTimeoutMilliSeconds)
 		}
 		video = ipcamSource
 	} else {
 		log.Errorln("No video source for", name)
-		return
+		return nil
 	}
 	if video == nil {
 		log.Errorln("Could not create video source for", name)
-		return
+		return nil
 	}
 	videoReader := videosource.NewVideoReader(video, monConf.MaxSourceFps, monConf.MaxOutputFps)
 	if videoReader == nil {
 		log.Errorln("Could not create video reader for", name)
-		return
+		return nil
+	}
+	return videoReader
+}
+
+// sharedSource is a shared reader with the config of the monitor that created it
+type sharedSource struct {
+	reader *monitor.SharedReader
+	conf   *monitor.Config
+}
+
+// sameReaderSettings returns true when the configs read their source the same way
+func sameReaderSettings(a *monitor.Config, b *monitor.Config) bool {
+	return a.MaxSourceFps == b.MaxSourceFps && a.MaxOutputFps == b.MaxOutputFps &&
+		a.Quality == b.Quality && a.CaptureTimeoutMilliSeconds == b.CaptureTimeoutMilliSeconds
+}
+
+// getSharedReader returns the running reader for the monitor source or creates a new one.
+// Monitors with the same source share one connection using the reader settings of the first monitor.
+func (m *Manage) getSharedReader(name string, monConf *monitor.Config) *monitor.SharedReader {
+	for cur, source := range m.sharedReaders {
+		if source.reader.Closed() {
+			delete(m.sharedReaders, cur)
+		}
+	}
+	if source, found := m.sharedReaders[sharedReaderKey(monConf)]; found {
+		log.Infoln("Sharing video source with", name)
+		if !sameReaderSettings(source.conf, monConf) {
+			log.Warnf("Reader settings of %s differ from the shared video source and are ignored", name)
+		}
+		return source.reader
+	}
+	videoReader := newVideoReader(name, monConf.Filename, monConf.URL, monConf)
+	if videoReader == nil {
+		return nil
 	}
 	videoReader.SetQuality(monConf.Quality)
-	mon = monitor.NewMonitor(name, videoReader)
+	shared := monitor.NewSharedReader(videoReader)
+	m.sharedReaders[sharedReaderKey(monConf)] = &sharedSource{reader: shared, conf: monConf}
+	return shared
+}
+
+func sharedReaderKey(monConf *monitor.Config) string {
+	if monConf.Filename != "" {
+		return "file:" + monConf.Filename
+	}
+	return "url:" + monConf.URL
+}
+
+func (m *Manage) setupMonitor(name string, configPath string) (mon *monitor.Monitor) {
+	if configPath == "" {
+		return
+	}
+	runtimeConfigDir := runtime.GetRuntimeDirectory(".config")
+	monConfigPath := runtimeConfigDir + configPath
+	monConf := monitor.NewConfig(monConfigPath)
+	if monConf == nil {
+		log.Errorf("Could not setup %s. monitor.NewConfig returned nil for %s", name, monConfigPath)
+		return
+	}
+	if monConf.View != nil && (monConf.DetectFilename != "" || monConf.DetectURL != "") {
+		log.Errorf("Could not setup %s. view is not supported with a detection stream", name)
+		return
+	}
+	// the detection reader is created first so a failure does not leave an unused shared reader
+	var detectReader *videosource.VideoReader
+	if monConf.DetectFilename != "" || monConf.DetectURL != "" {
+		detectReader = newVideoReader(name+"-detect", monConf.DetectFilename, monConf.DetectURL, monConf)
+		if detectReader == nil {
+			return
+		}
+		detectReader.SetQuality(monConf.Quality)
+	}
+	shared := m.getSharedReader(name, monConf)
+	if shared == nil {
+		return
+	}
+	mon = monitor.NewMonitor(name, shared.Reader)
+	mon.SetEncryption(m.key)
+	mon.SetSharedReader(shared, monConf.View)
+	if detectReader != nil {
+		mon.SetDetectReader(detectReader)
+	}
 	mon.ConfigPaths = append(mon.ConfigPaths, monConfigPath)
 	if monConf.RecordFilename != "" {
 		recordConfigPath := runtimeConfigDir + monConf.RecordFilename
@@ -227,6 +596,15 @@ func (m *Manage) setupMonitor(name string, configPath string) (mon *monitor.Moni
 		mon.SetContinuous(m.manageConf.Data, continuousConf)
 		mon.ConfigPaths = append(mon.ConfigPaths, continuousConfigPath)
 	}
+	if monConf.EventFilename != "" {
+		eventConfigPath := runtimeConfigDir + monConf.EventFilename
+		eventConf := monitor.NewEventConfig(eventConfigPath)
+		if eventConf == nil {
+			log.Warnf("Optional config file %s not found.", eventConfigPath)
+		}
+		mon.SetEvents(m.manageConf.Data, eventConf)
+		mon.ConfigPaths = append(mon.ConfigPaths, eventConfigPath)
+	}
 	if monConf.AlertFilename != "" {
 		alertPath := runtimeConfigDir + monConf.AlertFilename
 		alertSettings := monitor.NewAlertConfig(alertPath)
@@ -246,36 +624,30 @@ func (m *Manage) setupMonitor(name string, configPath string) (mon *monitor.Moni
 			mon.SetAlert(m.Notifier, nil, m.manageConf.Data, alertSettings)
 		}
 	}
-	if monConf.MotionFilename != "" {
-		motionPath := runtimeConfigDir + monConf.MotionFilename
-		motionConf := motion.NewConfig(motionPath)
-		if motionConf == nil {
-			log.Warnf("Optional config file %s not found.", motionPath)
-		}
-		mon.SetMotion(motionConf)
-		mon.ConfigPaths = append(mon.ConfigPaths, motionPath)
-	}
-	if monConf.TensorFilename != "" {
-		tensorPath := runtimeConfigDir + monConf.TensorFilename
-		tensorConf := tensor.NewConfig(tensorPath)
-		if tensorConf == nil {
-			log.Warnf("Optional config file %s not found.", tensorPath)
+	stages := make([]monitor.Stage, 0)
+	for _, cur := range monConf.GetPipeline() {
+		stage := monitor.NewStage(cur.Stage, name)
+		if stage == nil {
+			log.Errorf("Unknown pipeline stage %s for %s", cur.Stage, name)
+			continue
 		}
-		mon.SetTensor(tensorConf)
-		mon.ConfigPaths = append(mon.ConfigPaths, tensorPath)
-	}
-	if monConf.FaceFilename != "" {
-		facePath := runtimeConfigDir + monConf.FaceFilename
-		faceConf := face.NewConfig(facePath)
-		if faceConf == nil {
-			log.Warnf("Optional config file %s not found.", facePath)
+		if cur.Config != "" {
+			stagePath := runtimeConfigDir + cur.Config
+			if !stage.SetConfig(stagePath) {
+				log.Warnf("Optional config file %s not found.", stagePath)
+			}
+			mon.ConfigPaths = append(mon.ConfigPaths, stagePath)
 		}
-		mon.SetFace(faceConf)
-		mon.ConfigPaths = append(mon.ConfigPaths, facePath)
+		stages = append(stages, stage)
 	}
+	mon.SetPipeline(stages)
+	mon.SetArmState(m.getArmState(name))
+	mon.SetPolicy(m.getPolicy(name))
 	mon.SetStaleConfig(monConf.StaleTimeout, monConf.StaleMaxRetry)
 	mon.SetBufferSeconds(monConf.BufferSeconds)
 	mon.SetDelayBufferDuration(monConf.DelayBufferMilliSeconds)
+	mon.SetAdaptive(monConf.AnalysisMinFps, monConf.AnalysisMaxFps)
+	mon.SetOverlay(monConf.Overlay)
 	return mon
 }
 
@@ -327,8 +699,12 @@ func (m *Manage) doCheckStaleMonitors(lastStaleList []*monitor.Monitor) (staleLi
 			log.Warningln("Stale monitor", cur.Name)
 		}
 	}
-	for _, stale := range staleList {
-		m.removeMonitor(stale, true)
+	restartList := m.withSharedSources(staleList)
+	for _, cur := range restartList {
+		m.removeMonitor(cur, true)
+	}
+	m.closeSharedSources(restartList)
+	for _, stale := range restartList {
 		if stale.StaleRetry == 0 {
 			log.Errorln("Stale monitor DONE retrying for", stale.Name)
 			continue
@@ -355,15 +731,79 @@ func (m *Manage) doCheckStaleMonitors(lastStaleList []*monitor.Monitor) (staleLi
 	return
 }
 
+// withSharedSources returns the monitors and the other monitors sharing their sources.
+// A stalled source keeps running while any monitor is attached, so all of them restart together.
+func (m *Manage) withSharedSources(mons []*monitor.Monitor) []*monitor.Monitor {
+	result := append([]*monitor.Monitor{}, mons...)
+	for _, cur := range mons {
+		shared := cur.GetSharedReader()
+		if shared == nil {
+			continue
+		}
+		for _, other := range m.mons {
+			if other.GetSharedReader() == shared && !slices.Contains(result, other) {
+				log.Warningln("Restarting", other.Name, "with the shared source of", cur.Name)
+				result = append(result, other)
+			}
+		}
+	}
+	return result
+}
+
+// closeSharedSources closes the shared sources of the monitors so the next setup reconnects
+func (m *Manage) closeSharedSources(mons []*monitor.Monitor) {
+	for _, cur := range mons {
+		shared := cur.GetSharedReader()
+		if shared == nil {
+			continue
+		}
+		shared.Close()
+		for key, source := range m.sharedReaders {
+			if source.reader == shared {
+				delete(m.sharedReaders, key)
+			}
+		}
+	}
+}
+
 func (m *Manage) run() {
 	go func() {
 		defer close(m.done)
 		defer m.pubsub.Close()
 
+		if m.eventDB != nil {
+			defer m.eventDB.Close()
+			if eventSub := m.SubscribeEvents(100); eventSub != nil {
+				storeStop := make(chan bool)
+				storeDone := make(chan bool)
+				go m.storeEvents(eventSub, storeStop, storeDone)
+				defer eventSub.Unsubscribe()
+				defer func() {
+					close(storeStop)
+					<-storeDone
+				}()
+			}
+		}
+
 		defer m.cleanupAllMonitors()
 
 		m.addAllMonitors()
 
+		if m.storage != nil {
+			m.storage.Start()
+			defer func() {
+				m.storage.Stop()
+				m.storage.Wait()
+			}()
+		}
+		if m.archiver != nil {
+			m.archiver.Start()
+			defer func() {
+				m.archiver.Stop()
+				m.archiver.Wait()
+			}()
+		}
+
 		addMonSub, _ := pubsubmutex.Subscribe[*monitor.Monitor](&m.pubsub, topicAddMon, m.pubsub.GetUniqueSubscriberID(), 10)
 		defer addMonSub.Unsubscribe()
 		removeMonSub, _ := pubsubmutex.Subscribe[*monitor.Monitor](&m.pubsub, topicRemoveMon, m.pubsub.GetUniqueSubscriberID(), 10)
@@ -374,8 +814,24 @@ func (m *Manage) run() {
 		defer getMonNamesSub.Unsubscribe()
 		getMonFrameStatsSub, _ := pubsubmutex.Subscribe[string](&m.pubsub, topicGetMonitorFrameStats, m.pubsub.GetUniqueSubscriberID(), 10)
 		defer getMonFrameStatsSub.Unsubscribe()
+		getMonPipelineStatsSub, _ := pubsubmutex.Subscribe[string](&m.pubsub, topicGetMonitorPipelineStats, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer getMonPipelineStatsSub.Unsubscribe()
+		getMonLiveTrackerSub, _ := pubsubmutex.Subscribe[string](&m.pubsub, topicGetMonitorLiveTracker, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer getMonLiveTrackerSub.Unsubscribe()
+		getMonLatestFrameSub, _ := pubsubmutex.Subscribe[string](&m.pubsub, topicGetMonitorLatestFrame, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer getMonLatestFrameSub.Unsubscribe()
+		getMonOverlaySub, _ := pubsubmutex.Subscribe[string](&m.pubsub, topicGetMonitorOverlay, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer getMonOverlaySub.Unsubscribe()
 		getMonAlertTimesSub, _ := pubsubmutex.Subscribe[any](&m.pubsub, topicGetMonitorAlertTimes, m.pubsub.GetUniqueSubscriberID(), 10)
 		defer getMonAlertTimesSub.Unsubscribe()
+		setMonArmStateSub, _ := pubsubmutex.Subscribe[armRequest](&m.pubsub, topicSetMonitorArmState, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer setMonArmStateSub.Unsubscribe()
+		getMonArmStateSub, _ := pubsubmutex.Subscribe[string](&m.pubsub, topicGetMonitorArmState, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer getMonArmStateSub.Unsubscribe()
+		setModeSub, _ := pubsubmutex.Subscribe[modeRequest](&m.pubsub, topicSetMode, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer setModeSub.Unsubscribe()
+		getModeSub, _ := pubsubmutex.Subscribe[any](&m.pubsub, topicGetMode, m.pubsub.GetUniqueSubscriberID(), 10)
+		defer getModeSub.Unsubscribe()
 
 		staleTicker := time.NewTicker(time.Second)
 		defer staleTicker.Stop()
@@ -417,11 +873,58 @@ func (m *Manage) run() {
 				}
 				name := msg.Data
 				m.pubMonitorFrameStats(name)
+			case msg, ok := <-getMonPipelineStatsSub.Ch:
+				if !ok {
+					continue
+				}
+				name := msg.Data
+				m.pubMonitorPipelineStats(name)
+			case msg, ok := <-getMonLiveTrackerSub.Ch:
+				if !ok {
+					continue
+				}
+				name := msg.Data
+				m.pubMonitorLiveTracker(name)
+			case msg, ok := <-getMonLatestFrameSub.Ch:
+				if !ok {
+					continue
+				}
+				name := msg.Data
+				m.pubMonitorLatestFrame(name)
+			case msg, ok := <-getMonOverlaySub.Ch:
+				if !ok {
+					continue
+				}
+				name := msg.Data
+				m.pubMonitorOverlay(name)
 			case _, ok := <-getMonAlertTimesSub.Ch:
 				if !ok {
 					continue
 				}
 				m.pubMonitorAlertTimes()
+			case msg, ok := <-setMonArmStateSub.Ch:
+				if !ok {
+					continue
+				}
+				req := msg.Data
+				m.pubMonitorArmStateSet(req)
+			case msg, ok := <-getMonArmStateSub.Ch:
+				if !ok {
+					continue
+				}
+				name := msg.Data
+				m.pubMonitorArmState(name)
+			case msg, ok := <-setModeSub.Ch:
+				if !ok {
+					continue
+				}
+				req := msg.Data
+				m.pubModeSet(req)
+			case _, ok := <-getModeSub.Ch:
+				if !ok {
+					continue
+				}
+				m.pubMode()
 			case <-staleTicker.C:
 				lastStaleList = m.doCheckStaleMonitors(lastStaleList)
 			case event, ok := <-m.wtr.Events:
@@ -505,12 +1008,15 @@ func (m *Manage) doMonitorConfigChanges(modPath string, inList []mon) (retryList
 		m.removeMonitor(cur, false)
 		if found, conf := m.getMonitorConf(cur.Name); found {
 			tryList = append(tryList, conf)
+		} else {
+			m.removeRetentions(cur.Name)
 		}
 	}
 	for _, conf := range tryList {
 		newMon := m.setupMonitor(conf.Name, conf.ConfigPath)
 		if newMon == nil {
 			log.Warningln("Config change setup monitor FAILED for", conf.Name)
+			m.removeRetentions(conf.Name)
 			retryList = append(retryList, conf)
 			continue
 		}
@@ -520,6 +1026,14 @@ func (m *Manage) doMonitorConfigChanges(modPath string, inList []mon) (retryList
 	return
 }
 
+// removeRetentions drops the storage limits of a monitor no longer configured, or whose config no longer sets it up,
+// so its files are only pruned by the global limits. They are set again when the monitor is added.
+func (m *Manage) removeRetentions(name string) {
+	if m.storage != nil {
+		m.storage.RemoveRetentions(name)
+	}
+}
+
 func (m *Manage) associatedMonitors(modPath string) (result []*monitor.Monitor) {
 	result = make([]*monitor.Monitor, 0)
 	for _, cur := range m.mons {
@@ -0,0 +1,112 @@
+package manage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+// MigrateData moves the files written directly to the category directories into the per-monitor daily directories.
+// A file belongs to the configured monitor with the longest name its filename begins with, followed by an underscore,
+// and is dated by its modified time, with sidecars following their recording.
+func MigrateData(conf *Config) (moved int, err error) {
+	if conf == nil || conf.Data == "" {
+		return 0, fmt.Errorf("no data directory configured")
+	}
+	names := make([]string, 0, len(conf.Monitors))
+	for _, cur := range conf.Monitors {
+		if cur.Name != "" {
+			names = append(names, cur.Name)
+		}
+	}
+	sortLongestFirst(names)
+	categories := []string{monitor.CategoryContinuous, monitor.CategoryRecordings, monitor.CategoryEvents, monitor.CategoryAlerts}
+	for _, category := range categories {
+		count, categoryErr := migrateCategory(filepath.Join(filepath.Clean(conf.Data), category), names)
+		moved += count
+		if categoryErr != nil && err == nil {
+			err = categoryErr
+		}
+		if count > 0 {
+			log.Infoln("Migrated", count, category, "files")
+		}
+	}
+	return moved, err
+}
+
+func migrateCategory(categoryDir string, names []string) (moved int, err error) {
+	entries, readErr := os.ReadDir(categoryDir)
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return 0, nil
+		}
+		return 0, readErr
+	}
+	// date sidecars by their recording
+	dates := make(map[string]time.Time)
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			continue
+		}
+		infos = append(infos, info)
+		if !monitor.IsRecordingSidecar(info.Name()) {
+			dates[storageBase(info.Name())] = info.ModTime()
+		}
+	}
+	for _, info := range infos {
+		name := migrateMonitor(info.Name(), names)
+		if name == "" {
+			log.Warnln("No monitor for file, not migrated", filepath.Join(categoryDir, info.Name()))
+			continue
+		}
+		date, found := dates[storageBase(info.Name())]
+		if !found {
+			date = info.ModTime()
+		}
+		dateDir := monitor.DateDirectory(monitor.MonitorDirectory(filepath.Dir(categoryDir), filepath.Base(categoryDir), name), date)
+		if mkErr := os.MkdirAll(dateDir, os.ModePerm); mkErr != nil {
+			if err == nil {
+				err = mkErr
+			}
+			continue
+		}
+		if renameErr := os.Rename(filepath.Join(categoryDir, info.Name()), filepath.Join(dateDir, info.Name())); renameErr != nil {
+			if err == nil {
+				err = renameErr
+			}
+			continue
+		}
+		moved++
+	}
+	return moved, err
+}
+
+// sortLongestFirst sorts monitor names so cam1_garage is matched before cam1
+func sortLongestFirst(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+}
+
+// migrateMonitor returns the first of the names sorted longest first that the filename begins with followed by an underscore,
+// or empty when none
+func migrateMonitor(filename string, names []string) string {
+	for _, name := range names {
+		if strings.HasPrefix(filename, name+"_") {
+			return name
+		}
+	}
+	return ""
+}
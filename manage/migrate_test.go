@@ -0,0 +1,76 @@
+package manage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+func TestMigrateData(t *testing.T) {
+	dataDir := t.TempDir()
+	day := time.Date(2024, 1, 2, 23, 59, 58, 0, time.Local)
+	writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1_a.mp4", 10, day)
+	writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1_a.json", 10, day.Add(5*time.Second))
+	writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam10_a.jpg", 10, day)
+	unknown := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "other_a.jpg", 10, day)
+	nested := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_b.jpg", 10, day)
+
+	conf := &Config{Data: dataDir, Monitors: []mon{{Name: "cam1"}, {Name: "cam10"}}}
+	moved, err := MigrateData(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 3 {
+		t.Errorf("MigrateData() = %v, expected %v", moved, 3)
+	}
+	tests := []struct {
+		category string
+		name     string
+		exists   bool
+	}{
+		{monitor.CategoryRecordings, "cam1/2024-01-02/cam1_a.mp4", true},
+		{monitor.CategoryRecordings, "cam1/2024-01-02/cam1_a.json", true},
+		{monitor.CategoryRecordings, "cam1_a.mp4", false},
+		{monitor.CategoryAlerts, "cam10/2024-01-02/cam10_a.jpg", true},
+		{monitor.CategoryAlerts, "cam1/2024-01-02/cam10_a.jpg", false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dataDir, test.category, filepath.FromSlash(test.name))
+		if result := storageExists(path); result != test.exists {
+			t.Errorf("MigrateData() %s exists = %v, expected %v", test.name, result, test.exists)
+		}
+	}
+	if !storageExists(unknown) || !storageExists(nested) {
+		t.Error("MigrateData() expected unknown and migrated files left in place")
+	}
+
+	moved, err = MigrateData(conf)
+	if err != nil || moved != 0 {
+		t.Errorf("MigrateData() again = %v, %v, expected 0, nil", moved, err)
+	}
+}
+
+func TestMigrateDataUnconfigured(t *testing.T) {
+	dataDir := t.TempDir()
+	day := time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local)
+	writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1_a.jpg", 10, day)
+	cam10 := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam10_a.jpg", 10, day)
+	garage := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1-garage_a.jpg", 10, day)
+
+	conf := &Config{Data: dataDir, Monitors: []mon{{Name: "cam1"}}}
+	moved, err := MigrateData(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 {
+		t.Errorf("MigrateData() = %v, expected %v", moved, 1)
+	}
+	if !storageExists(filepath.Join(dataDir, monitor.CategoryAlerts, "cam1", "2024-01-02", "cam1_a.jpg")) {
+		t.Error("MigrateData() expected cam1_a.jpg in the cam1 daily directory")
+	}
+	if !storageExists(cam10) || !storageExists(garage) {
+		t.Error("MigrateData() expected files of unconfigured monitors left in place")
+	}
+}
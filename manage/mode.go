@@ -0,0 +1,151 @@
+package manage
+
+import (
+	"encoding/csv"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jonoton/go-runtime"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+// Mode Constants
+var (
+	ModeStateFilename = "mode.yaml"
+	ModeAuditFilename = "modes"
+)
+
+// ModeInfo contains the active mode and the configured mode names
+type ModeInfo struct {
+	Active string
+	Modes  []string
+}
+
+type modeRequest struct {
+	name   string
+	source []string
+}
+
+type modeState struct {
+	Mode string `yaml:"mode"`
+}
+
+func newModeLogger() *stdlog.Logger {
+	logDir := runtime.GetRuntimeDirectory(".logs")
+	l := &stdlog.Logger{}
+	l.SetOutput(&lumberjack.Logger{
+		Filename:   logDir + ModeAuditFilename,
+		MaxSize:    1,
+		MaxBackups: 5,
+		MaxAge:     28,
+		Compress:   false,
+	})
+	return l
+}
+
+func (m *Manage) modeStatePath() string {
+	if m.manageConf.Data == "" {
+		return ""
+	}
+	return filepath.Join(m.manageConf.Data, ModeStateFilename)
+}
+
+// loadActiveMode returns the persisted mode, falling back to the configured default mode
+func (m *Manage) loadActiveMode() string {
+	result := m.manageConf.DefaultMode
+	if modeStatePath := m.modeStatePath(); modeStatePath != "" {
+		if yamlFile, err := os.ReadFile(modeStatePath); err == nil {
+			state := modeState{}
+			if err = yaml.Unmarshal(yamlFile, &state); err != nil {
+				log.Errorf("Could not read mode state %s: %v", modeStatePath, err)
+			} else {
+				result = state.Mode
+			}
+		}
+	}
+	if result != "" && m.findMode(result) == nil {
+		log.Warnf("Mode %s not found in %s. Using no mode.", result, ConfigFilename)
+		result = ""
+	}
+	return result
+}
+
+func (m *Manage) saveActiveMode() {
+	modeStatePath := m.modeStatePath()
+	if modeStatePath == "" {
+		return
+	}
+	data, err := yaml.Marshal(modeState{Mode: m.activeMode})
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	tmpPath := modeStatePath + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil {
+		log.Errorf("Could not save mode state %s: %v", modeStatePath, err)
+		return
+	}
+	if err = os.Rename(tmpPath, modeStatePath); err != nil {
+		log.Errorf("Could not save mode state %s: %v", modeStatePath, err)
+	}
+}
+
+func (m *Manage) findMode(name string) *mode {
+	for i, cur := range m.manageConf.Modes {
+		if cur.Name == name {
+			return &m.manageConf.Modes[i]
+		}
+	}
+	return nil
+}
+
+// getPolicy returns the monitor policy for the active mode
+func (m *Manage) getPolicy(monitorName string) monitor.Policy {
+	activeMode := m.findMode(m.activeMode)
+	if activeMode == nil {
+		return monitor.DefaultPolicy()
+	}
+	if policy, found := activeMode.Monitors[monitorName]; found {
+		return policy
+	}
+	return activeMode.Default
+}
+
+func (m *Manage) getModeInfo() ModeInfo {
+	result := ModeInfo{
+		Active: m.activeMode,
+		Modes:  make([]string, 0),
+	}
+	for _, cur := range m.manageConf.Modes {
+		result.Modes = append(result.Modes, cur.Name)
+	}
+	return result
+}
+
+// setMode switches to the mode, applies the policies, and records the switch in the audit log
+func (m *Manage) setMode(req modeRequest) bool {
+	if m.findMode(req.name) == nil {
+		return false
+	}
+	previous := m.activeMode
+	m.activeMode = req.name
+	for name, mon := range m.mons {
+		mon.SetPolicy(m.getPolicy(name))
+	}
+	m.saveActiveMode()
+	var line strings.Builder
+	w := csv.NewWriter(&line)
+	w.UseCRLF = true
+	w.Write(append([]string{time.Now().Format(time.RFC3339), previous, req.name}, req.source...))
+	w.Flush()
+	m.modeLogger.Print(line.String())
+	log.Infof("Mode changed from %s to %s by %s", previous, req.name, strings.Join(req.source, " "))
+	return true
+}
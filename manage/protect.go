@@ -0,0 +1,176 @@
+package manage
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jonoton/scout/monitor"
+)
+
+// ProtectFilename is the file in the data directory persisting the protected files
+var ProtectFilename = "protected.yaml"
+
+// Protect Errors
+var (
+	ErrNoStorage       = errors.New("no data directory")
+	ErrUnknownCategory = errors.New("unknown category")
+	ErrFileNotFound    = errors.New("file not found")
+)
+
+func loadProtected(protectPath string) map[string]map[string]bool {
+	result := make(map[string]map[string]bool)
+	yamlFile, err := os.ReadFile(protectPath)
+	if err != nil {
+		return result
+	}
+	saved := make(map[string][]string)
+	if err = yaml.Unmarshal(yamlFile, &saved); err != nil {
+		log.Errorf("Could not read protected files %s: %v", protectPath, err)
+		return result
+	}
+	for category, paths := range saved {
+		result[category] = make(map[string]bool)
+		for _, cur := range paths {
+			result[category][cur] = true
+		}
+	}
+	return result
+}
+
+func saveProtected(protectPath string, protected map[string][]string) {
+	data, err := yaml.Marshal(protected)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	tmpPath := protectPath + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil {
+		log.Errorf("Could not save protected files %s: %v", protectPath, err)
+		return
+	}
+	if err = os.Rename(tmpPath, protectPath); err != nil {
+		log.Errorf("Could not save protected files %s: %v", protectPath, err)
+	}
+}
+
+// Protect keeps or releases the file at the slash path relative to the category directory.
+// Protecting any file of a recording or alert set keeps all of its files.
+func (s *storage) Protect(category string, rel string, protect bool) error {
+	if !isStorageCategory(category) {
+		return ErrUnknownCategory
+	}
+	rel = strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+filepath.FromSlash(rel))), "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if protect {
+		info, err := os.Stat(filepath.Join(s.dataDir, category, filepath.FromSlash(rel)))
+		local := err == nil && !info.IsDir()
+		if !local && (s.archived == nil || !s.archived(category, rel)) {
+			return ErrFileNotFound
+		}
+		if s.protected[category] == nil {
+			s.protected[category] = make(map[string]bool)
+		}
+		s.protected[category][rel] = true
+		log.Infoln("Protected", category, rel)
+	} else {
+		if !s.protected[category][rel] {
+			return ErrFileNotFound
+		}
+		delete(s.protected[category], rel)
+		log.Infoln("Released", category, rel)
+	}
+	saveProtected(filepath.Join(s.dataDir, ProtectFilename), s.protectedList())
+	return nil
+}
+
+// protectedGroup returns whether a file of the category is protected itself or through another file
+// of its recording or alert set. Files are matched by name so archived files are matched too.
+func (s *storage) protectedGroup(category string) func(rel string) bool {
+	s.mu.Lock()
+	bases := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for cur := range s.protected[category] {
+		bases[protectBase(cur)] = true
+		dirs[path.Dir(cur)] = true
+	}
+	s.mu.Unlock()
+	if category == monitor.CategoryAlerts {
+		grouped := make([]string, 0)
+		for dir := range dirs {
+			setPaths, _ := filepath.Glob(filepath.Join(s.dataDir, category, filepath.FromSlash(dir), "*"+monitor.RecordingSidecarExt))
+			for _, setPath := range setPaths {
+				set, err := monitor.ReadAlertSet(s.key, setPath)
+				if err != nil {
+					continue
+				}
+				members := []string{path.Join(dir, protectBase(filepath.Base(setPath)))}
+				for _, name := range set.Files {
+					members = append(members, path.Join(dir, protectBase(name)))
+				}
+				for _, member := range members {
+					if bases[member] {
+						grouped = append(grouped, members...)
+						break
+					}
+				}
+			}
+		}
+		for _, member := range grouped {
+			bases[member] = true
+		}
+	}
+	return func(rel string) bool {
+		return bases[protectBase(rel)]
+	}
+}
+
+// protectBase returns the slash path without extension shared by a recording and its sidecars
+func protectBase(rel string) string {
+	return strings.TrimSuffix(rel, path.Ext(rel))
+}
+
+// Protected returns the protected slash paths of each category
+func (s *storage) Protected() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.protectedList()
+}
+
+func (s *storage) protectedList() map[string][]string {
+	result := make(map[string][]string)
+	for category, paths := range s.protected {
+		if len(paths) == 0 {
+			continue
+		}
+		result[category] = make([]string, 0, len(paths))
+		for cur := range paths {
+			result[category] = append(result[category], cur)
+		}
+		sort.Strings(result[category])
+	}
+	return result
+}
+
+// ProtectFile keeps the file at the slash path relative to the category directory from being pruned, or releases it
+func (m *Manage) ProtectFile(category string, rel string, protect bool) error {
+	if m.storage == nil {
+		return ErrNoStorage
+	}
+	return m.storage.Protect(category, rel, protect)
+}
+
+// GetProtectedFiles returns the protected slash paths of each category
+func (m *Manage) GetProtectedFiles() map[string][]string {
+	if m.storage == nil {
+		return make(map[string][]string)
+	}
+	return m.storage.Protected()
+}
@@ -0,0 +1,511 @@
+package manage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/scout/eventdb"
+	"github.com/jonoton/scout/monitor"
+	"github.com/jonoton/scout/seal"
+)
+
+const defaultStorageIntervalMinutes = 10
+
+// storageCategories in the default prune order
+var storageCategories = []string{
+	monitor.CategoryContinuous,
+	monitor.CategoryRecordings,
+	monitor.CategoryEvents,
+	monitor.CategoryAlerts,
+}
+
+// storageFile is a file in a category directory with its attached sidecars, or an alert set.
+// Files from before the per-monitor layout have no monitor until matched by name.
+type storageFile struct {
+	category  string
+	monitor   string
+	name      string
+	paths     []string
+	labels    []string
+	size      uint64
+	modTime   time.Time
+	expires   time.Time
+	protected bool
+	grouped   bool
+	removed   bool
+}
+
+// storageSidecar is the cached content of a sidecar or alert set file
+type storageSidecar struct {
+	modTime time.Time
+	labels  []string
+	files   []string
+}
+
+// storage owns the data directory and prunes the files of all monitors
+type storage struct {
+	dataDir        string
+	maxBytes       uint64
+	minFreePercent float64
+	interval       time.Duration
+	order          []string
+	minRetention   map[string]time.Duration
+	retentions     map[string][]monitor.Retention
+	protected      map[string]map[string]bool
+	archived       func(category string, rel string) bool
+	findArchived   func(category string, rel string) bool
+	events         *eventdb.EventDB
+	key            *seal.Key
+	sidecars       map[string]storageSidecar
+	dateDirs       []string
+	mu             sync.Mutex
+	usage          func(path string) (total uint64, free uint64, err error)
+	cancel         chan bool
+	cancelOnce     sync.Once
+	done           chan bool
+}
+
+func newStorage(dataDir string, conf *StorageConfig) *storage {
+	s := &storage{
+		dataDir:      dataDir,
+		interval:     defaultStorageIntervalMinutes * time.Minute,
+		order:        storageCategories,
+		minRetention: make(map[string]time.Duration),
+		retentions:   make(map[string][]monitor.Retention),
+		protected:    loadProtected(filepath.Join(dataDir, ProtectFilename)),
+		sidecars:     make(map[string]storageSidecar),
+		usage:        diskUsage,
+		cancel:       make(chan bool),
+		done:         make(chan bool),
+	}
+	if conf == nil {
+		return s
+	}
+	if conf.MaxGB > 0 {
+		s.maxBytes = uint64(conf.MaxGB * (1 << 30))
+	}
+	s.minFreePercent = conf.MinFreePercent
+	if conf.IntervalMinutes > 0 {
+		s.interval = time.Duration(conf.IntervalMinutes) * time.Minute
+	}
+	if len(conf.PruneOrder) > 0 {
+		s.order = make([]string, 0, len(conf.PruneOrder))
+		for _, cur := range conf.PruneOrder {
+			if !isStorageCategory(cur) {
+				log.Warnln("Storage unknown category in pruneOrder", cur)
+				continue
+			}
+			s.order = append(s.order, cur)
+		}
+	}
+	for category, days := range conf.MinRetentionDays {
+		if !isStorageCategory(category) {
+			log.Warnln("Storage unknown category in minRetentionDays", category)
+			continue
+		}
+		s.minRetention[category] = time.Duration(days) * 24 * time.Hour
+	}
+	return s
+}
+
+func isStorageCategory(category string) bool {
+	for _, cur := range storageCategories {
+		if cur == category {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetentions sets the per-monitor limits of the monitor's files
+func (s *storage) SetRetentions(monitorName string, retentions []monitor.Retention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retentions[monitorName] = retentions
+}
+
+// RemoveRetentions drops the per-monitor limits of a monitor that is no longer configured
+func (s *storage) RemoveRetentions(monitorName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.retentions, monitorName)
+}
+
+// Start pruning now and every interval
+func (s *storage) Start() {
+	go func() {
+		defer close(s.done)
+		tick := time.NewTicker(s.interval)
+		defer tick.Stop()
+		s.prune(time.Now())
+		for {
+			select {
+			case <-tick.C:
+				s.prune(time.Now())
+			case <-s.cancel:
+				return
+			}
+		}
+	}()
+}
+
+// Stop pruning
+func (s *storage) Stop() {
+	s.cancelOnce.Do(func() {
+		close(s.cancel)
+	})
+}
+
+// Wait until done
+func (s *storage) Wait() {
+	<-s.done
+}
+
+// prune enforces the per-monitor limits, then the global budget and free disk floor in prune order.
+// Protected files are never removed.
+func (s *storage) prune(now time.Time) {
+	files := s.scan()
+	s.mu.Lock()
+	retentions := make([]monitor.Retention, 0)
+	names := make([]string, 0, len(s.retentions))
+	for name, cur := range s.retentions {
+		names = append(names, name)
+		retentions = append(retentions, cur...)
+	}
+	s.mu.Unlock()
+	// files from before the per-monitor layout belong to the monitor with the longest matching name
+	sortLongestFirst(names)
+	for _, category := range storageCategories {
+		for _, f := range files[category] {
+			if f.monitor == "" {
+				f.monitor = migrateMonitor(f.name, names)
+			}
+		}
+	}
+
+	for _, retention := range retentions {
+		matched := make([]*storageFile, 0)
+		total := uint64(0)
+		for _, f := range files[retention.Category] {
+			if f.removed || f.monitor != retention.Monitor {
+				continue
+			}
+			if maxAge := retention.MaxAgeOf(f.labels); maxAge > 0 {
+				f.expires = f.modTime.Add(maxAge)
+				if now.After(f.expires) && !f.protected {
+					s.remove(f)
+					continue
+				}
+			}
+			matched = append(matched, f)
+			total += f.size
+		}
+		if retention.MaxBytes > 0 && total > retention.MaxBytes {
+			s.pruneOldest(matched, total-retention.MaxBytes, now)
+		}
+	}
+
+	excess := uint64(0)
+	if s.maxBytes > 0 {
+		total := uint64(0)
+		for _, category := range storageCategories {
+			for _, f := range files[category] {
+				if !f.removed {
+					total += f.size
+				}
+			}
+		}
+		if info, err := os.Stat(filepath.Join(s.dataDir, eventdb.Filename)); err == nil {
+			total += uint64(info.Size())
+		}
+		if total > s.maxBytes {
+			excess = total - s.maxBytes
+		}
+	}
+	if s.minFreePercent > 0 {
+		diskTotal, diskFree, err := s.usage(s.dataDir)
+		if err != nil {
+			log.Warnln("Storage could not get free disk space", err)
+		} else if need := uint64(s.minFreePercent / 100 * float64(diskTotal)); diskFree < need && need-diskFree > excess {
+			excess = need - diskFree
+		}
+	}
+	if excess > 0 {
+		s.pruneExcess(files, excess, now)
+	}
+	s.removeEmptyDateDirs(now)
+	s.pruneEvents(files)
+}
+
+// pruneEvents removes the events whose files are all gone from the data directory and the archive,
+// and clears the file paths of an event that are gone. An event without files is removed once it
+// started before the oldest remaining event file of its monitor.
+func (s *storage) pruneEvents(files map[string][]*storageFile) {
+	if s.events == nil {
+		return
+	}
+	names := make(map[string]map[string]bool)
+	oldest := make(map[string]time.Time)
+	for _, category := range storageCategories {
+		names[category] = make(map[string]bool)
+		for _, f := range files[category] {
+			if f.removed {
+				continue
+			}
+			for _, path := range f.paths {
+				names[category][filepath.Base(path)] = true
+			}
+			if category == monitor.CategoryEvents {
+				if cur, found := oldest[f.monitor]; !found || f.modTime.Before(cur) {
+					oldest[f.monitor] = f.modTime
+				}
+			}
+		}
+	}
+	// files are matched by name since videos are filed by date after the event stored their path
+	exists := func(path string) bool {
+		rel, err := filepath.Rel(s.dataDir, path)
+		if err != nil {
+			return false
+		}
+		parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(parts) < 2 {
+			return false
+		}
+		if names[parts[0]][filepath.Base(path)] {
+			return true
+		}
+		if s.findArchived != nil && s.findArchived(parts[0], parts[1]) {
+			return true
+		}
+		// saved since the scan
+		_, err = os.Stat(path)
+		return err == nil
+	}
+	removed, err := s.events.Retain(func(event *monitor.Event) (bool, bool) {
+		if event.SnapshotPath == "" && event.RecordingPath == "" {
+			cur, found := oldest[event.Monitor]
+			return !found || !event.StartTime.Before(cur), false
+		}
+		changed := false
+		if event.SnapshotPath != "" && !exists(event.SnapshotPath) {
+			event.SnapshotPath = ""
+			changed = true
+		}
+		if event.RecordingPath != "" && !exists(event.RecordingPath) {
+			event.RecordingPath = ""
+			changed = true
+		}
+		return event.SnapshotPath != "" || event.RecordingPath != "", changed
+	})
+	if err != nil {
+		log.Errorln("Storage could not prune events", err)
+	} else if removed > 0 {
+		log.Infoln("Storage removed", removed, "events whose files were pruned")
+	}
+}
+
+// pruneExcess removes the oldest files category by category in prune order until excess is freed
+func (s *storage) pruneExcess(files map[string][]*storageFile, excess uint64, now time.Time) {
+	freed := uint64(0)
+	for _, category := range s.order {
+		if freed >= excess {
+			break
+		}
+		freed += s.pruneOldest(files[category], excess-freed, now)
+	}
+	if freed < excess {
+		log.Warnln("Storage could only free", freed, "of", excess, "bytes due to minimum retention and protected files")
+	}
+}
+
+// pruneOldest removes the files expiring first, then the oldest, outside of minimum retention
+// until amount is freed and returns the freed bytes
+func (s *storage) pruneOldest(files []*storageFile, amount uint64, now time.Time) uint64 {
+	candidates := make([]*storageFile, 0, len(files))
+	for _, f := range files {
+		if !f.removed && !f.protected && now.Sub(f.modTime) >= s.minRetention[f.category] {
+			candidates = append(candidates, f)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].expires, candidates[j].expires
+		if a.Equal(b) {
+			return false
+		}
+		// files without an expiry go last
+		return !a.IsZero() && (b.IsZero() || a.Before(b))
+	})
+	freed := uint64(0)
+	for _, f := range candidates {
+		if freed >= amount {
+			break
+		}
+		s.remove(f)
+		freed += f.size
+	}
+	return freed
+}
+
+func (s *storage) remove(f *storageFile) {
+	for _, path := range f.paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Errorln(err)
+		}
+	}
+	f.removed = true
+}
+
+// scan returns the files of each category oldest first, where recording sidecars are attached to their video
+// and the files of an alert set to the set
+func (s *storage) scan() map[string][]*storageFile {
+	result := make(map[string][]*storageFile)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dateDirs = make([]string, 0)
+	seen := make(map[string]bool)
+	for _, category := range storageCategories {
+		categoryDir := filepath.Join(s.dataDir, category)
+		files := make([]*storageFile, 0)
+		bases := make(map[string]*storageFile)
+		sidecars := make([]*storageFile, 0)
+		filepath.WalkDir(categoryDir, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel, relErr := filepath.Rel(categoryDir, path)
+			if relErr != nil {
+				return nil
+			}
+			parts := strings.Split(filepath.ToSlash(rel), "/")
+			if entry.IsDir() {
+				if len(parts) == 2 {
+					s.dateDirs = append(s.dateDirs, path)
+				}
+				return nil
+			}
+			info, infoErr := entry.Info()
+			if infoErr != nil {
+				return nil
+			}
+			f := &storageFile{
+				category:  category,
+				name:      entry.Name(),
+				paths:     []string{path},
+				size:      uint64(info.Size()),
+				modTime:   info.ModTime(),
+				protected: s.protected[category][filepath.ToSlash(rel)],
+			}
+			if len(parts) > 1 {
+				f.monitor = parts[0]
+			}
+			seen[category+"/"+filepath.ToSlash(rel)] = true
+			if monitor.IsRecordingSidecar(f.name) {
+				sidecars = append(sidecars, f)
+				return nil
+			}
+			bases[storageBase(path)] = f
+			files = append(files, f)
+			return nil
+		})
+		for _, sidecar := range sidecars {
+			owner, found := bases[storageBase(sidecar.paths[0])]
+			if found {
+				owner.attach(sidecar)
+			} else {
+				owner = sidecar
+				files = append(files, owner)
+			}
+			content := s.readSidecar(category, sidecar)
+			owner.labels = append(owner.labels, content.labels...)
+			for _, name := range content.files {
+				member, found := bases[storageBase(filepath.Join(filepath.Dir(sidecar.paths[0]), name))]
+				if found && member != owner && !member.grouped {
+					owner.attach(member)
+					member.grouped = true
+				}
+			}
+		}
+		kept := make([]*storageFile, 0, len(files))
+		for _, f := range files {
+			if !f.grouped {
+				kept = append(kept, f)
+			}
+		}
+		sort.Slice(kept, func(i, j int) bool {
+			return kept[i].modTime.Before(kept[j].modTime)
+		})
+		result[category] = kept
+	}
+	for path := range s.sidecars {
+		if !seen[path] {
+			delete(s.sidecars, path)
+		}
+	}
+	return result
+}
+
+// attach adds the paths of other to the file
+func (f *storageFile) attach(other *storageFile) {
+	f.paths = append(f.paths, other.paths...)
+	f.size += other.size
+	f.protected = f.protected || other.protected
+}
+
+// readSidecar returns the labels of a recording sidecar or the labels and files of an alert set
+func (s *storage) readSidecar(category string, f *storageFile) storageSidecar {
+	path := f.paths[0]
+	if !strings.HasSuffix(path, monitor.RecordingSidecarExt) {
+		return storageSidecar{}
+	}
+	key := category + "/" + s.relPath(category, path)
+	if cached, found := s.sidecars[key]; found && cached.modTime.Equal(f.modTime) {
+		return cached
+	}
+	result := storageSidecar{modTime: f.modTime}
+	switch category {
+	case monitor.CategoryRecordings:
+		if sidecar, err := monitor.ReadRecordingSidecar(s.key, path); err == nil {
+			for _, cur := range sidecar.Labels {
+				result.labels = append(result.labels, cur.Label)
+			}
+		}
+	case monitor.CategoryAlerts:
+		if set, err := monitor.ReadAlertSet(s.key, path); err == nil {
+			result.labels = set.Labels
+			result.files = set.Files
+		}
+	}
+	s.sidecars[key] = result
+	return result
+}
+
+// relPath returns the slash path relative to the category directory
+func (s *storage) relPath(category string, path string) string {
+	rel, err := filepath.Rel(filepath.Join(s.dataDir, category), path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// removeEmptyDateDirs removes the empty daily directories before today
+func (s *storage) removeEmptyDateDirs(now time.Time) {
+	today := now.Format(monitor.DateLayout)
+	for _, cur := range s.dateDirs {
+		if filepath.Base(cur) < today {
+			// only removed when empty
+			os.Remove(cur)
+		}
+	}
+}
+
+func storageBase(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
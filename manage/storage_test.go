@@ -0,0 +1,340 @@
+package manage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonoton/scout/eventdb"
+	"github.com/jonoton/scout/monitor"
+)
+
+func writeStorageFile(t *testing.T, dataDir string, category string, name string, size int, modTime time.Time) string {
+	t.Helper()
+	path := filepath.Join(dataDir, category, filepath.FromSlash(name))
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func storageExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestStoragePruneOrder(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	oldContinuous := writeStorageFile(t, dataDir, monitor.CategoryContinuous, "cam1/2024-01-01/cam1_a.mp4", 100, now.Add(-3*time.Hour))
+	newContinuous := writeStorageFile(t, dataDir, monitor.CategoryContinuous, "cam1/2024-01-01/cam1_b.mp4", 100, now.Add(-time.Hour))
+	oldRecording := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.mp4", 100, now.Add(-5*time.Hour))
+	oldSidecar := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.json", 10, now.Add(-5*time.Hour))
+	oldAlert := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg", 100, now.Add(-6*time.Hour))
+
+	s := newStorage(dataDir, nil)
+	s.maxBytes = 250
+	s.prune(now)
+	if storageExists(oldContinuous) || storageExists(newContinuous) {
+		t.Error("prune() expected continuous pruned first")
+	}
+	if !storageExists(oldRecording) || !storageExists(oldSidecar) || !storageExists(oldAlert) {
+		t.Error("prune() removed more than needed")
+	}
+
+	s.maxBytes = 150
+	s.prune(now)
+	if storageExists(oldRecording) || storageExists(oldSidecar) {
+		t.Error("prune() expected the recording and its sidecar pruned")
+	}
+	if !storageExists(oldAlert) {
+		t.Error("prune() expected alerts pruned last")
+	}
+}
+
+func TestStorageMinRetention(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	old := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1_a.mp4", 100, now.Add(-72*time.Hour))
+	recent := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1_b.mp4", 100, now.Add(-time.Hour))
+
+	s := newStorage(dataDir, &StorageConfig{MinRetentionDays: map[string]int{monitor.CategoryRecordings: 1}})
+	s.usage = func(path string) (uint64, uint64, error) {
+		return 1000, 0, nil
+	}
+	s.minFreePercent = 50
+	s.prune(now)
+	if storageExists(old) {
+		t.Error("prune() expected the old recording pruned for the free disk floor")
+	}
+	if !storageExists(recent) {
+		t.Error("prune() removed a recording within minimum retention")
+	}
+}
+
+func TestStorageRetentions(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	expired := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg", 10, now.Add(-3*time.Hour))
+	similar := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam10/2024-01-01/cam10_a.jpg", 10, now.Add(-3*time.Hour))
+	legacy := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1_old.jpg", 10, now.Add(-3*time.Hour))
+	legacySimilar := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam10_old.jpg", 10, now.Add(-3*time.Hour))
+	oldest := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-02/cam1_b.jpg", 100, now.Add(-2*time.Hour))
+	newest := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-02/cam1_c.jpg", 100, now.Add(-time.Hour))
+
+	s := newStorage(dataDir, nil)
+	s.SetRetentions("cam1", []monitor.Retention{
+		{Category: monitor.CategoryAlerts, Monitor: "cam1", MaxAge: 150 * time.Minute, MaxBytes: 150},
+	})
+	s.SetRetentions("cam10", []monitor.Retention{})
+	s.prune(now)
+	if storageExists(expired) || storageExists(legacy) || storageExists(oldest) {
+		t.Error("prune() expected the expired and oldest files of cam1 pruned")
+	}
+	if !storageExists(similar) || !storageExists(legacySimilar) || !storageExists(newest) {
+		t.Error("prune() removed files within the limits")
+	}
+	if storageExists(filepath.Dir(expired)) {
+		t.Error("prune() expected the empty daily directory removed")
+	}
+}
+
+func TestStoragePruneEvents(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	expired := writeStorageFile(t, dataDir, monitor.CategoryEvents, "cam1/2024-01-01/cam1_a.jpg", 10, now.Add(-3*time.Hour))
+	kept := writeStorageFile(t, dataDir, monitor.CategoryEvents, "cam1/2024-01-02/cam1_b.jpg", 10, now.Add(-time.Hour))
+	writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-02/cam1_b.mp4", 10, now.Add(-time.Hour))
+	events := eventdb.NewEventDB(filepath.Join(dataDir, eventdb.Filename))
+	if events == nil {
+		t.Fatal("NewEventDB() returned nil")
+	}
+	defer events.Close()
+	// the recording was staged when the event closed and filed by date since
+	staged := filepath.Join(dataDir, monitor.CategoryRecordings, "cam1", "cam1_b.mp4")
+	archived := filepath.Join(dataDir, monitor.CategoryEvents, "cam1", "2024-01-01", "cam1_c.jpg")
+	for _, event := range []monitor.Event{
+		{ID: "a", Monitor: "cam1", StartTime: now.Add(-3 * time.Hour), SnapshotPath: expired},
+		{ID: "b", Monitor: "cam1", StartTime: now.Add(-time.Hour), SnapshotPath: kept, RecordingPath: staged},
+		{ID: "c", Monitor: "cam1", StartTime: now.Add(-3 * time.Hour), SnapshotPath: archived,
+			RecordingPath: filepath.Join(dataDir, monitor.CategoryRecordings, "cam1", "2024-01-01", "cam1_c.mp4")},
+		{ID: "d", Monitor: "cam1", StartTime: now.Add(-3 * time.Hour)},
+		{ID: "e", Monitor: "cam1", StartTime: now},
+	} {
+		if err := events.Put(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newStorage(dataDir, nil)
+	s.events = events
+	s.findArchived = func(category string, rel string) bool {
+		return category == monitor.CategoryEvents && rel == "cam1/2024-01-01/cam1_c.jpg"
+	}
+	s.SetRetentions("cam1", []monitor.Retention{
+		{Category: monitor.CategoryEvents, Monitor: "cam1", MaxAge: 150 * time.Minute},
+	})
+	s.prune(now)
+	if storageExists(expired) {
+		t.Fatal("prune() expected the expired snapshot pruned")
+	}
+	tests := []struct {
+		id        string
+		exists    bool
+		recording bool
+	}{
+		{id: "a", exists: false},
+		{id: "b", exists: true, recording: true},
+		{id: "c", exists: true, recording: false},
+		{id: "d", exists: false},
+		{id: "e", exists: true},
+	}
+	for _, tt := range tests {
+		event := events.Get(tt.id)
+		if (event != nil) != tt.exists {
+			t.Errorf("Get(%s) = %v, expected exists %v", tt.id, event, tt.exists)
+			continue
+		}
+		if event != nil && (event.RecordingPath != "") != tt.recording {
+			t.Errorf("Get(%s) RecordingPath = %s, expected recording %v", tt.id, event.RecordingPath, tt.recording)
+		}
+	}
+}
+
+func TestStorageRemoveRetentions(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	expired := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg", 10, now.Add(-3*time.Hour))
+
+	s := newStorage(dataDir, nil)
+	s.SetRetentions("cam1", []monitor.Retention{
+		{Category: monitor.CategoryAlerts, Monitor: "cam1", MaxAge: time.Hour},
+	})
+	s.RemoveRetentions("cam1")
+	s.prune(now)
+	if !storageExists(expired) {
+		t.Error("prune() expected the limits of a removed monitor dropped")
+	}
+}
+
+func writeStorageJSON(t *testing.T, dataDir string, category string, name string, v interface{}, modTime time.Time) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeStorageFile(t, dataDir, category, name, 0, modTime)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStorageLabelRetention(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	modTime := now.Add(-72 * time.Hour)
+	person := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.mp4", 10, modTime)
+	writeStorageJSON(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.json", monitor.RecordingSidecar{
+		Labels: []monitor.TimelineLabel{{Label: "cat"}, {Label: "person"}},
+	}, modTime)
+	cat := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_b.mp4", 10, modTime)
+	writeStorageJSON(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_b.json", monitor.RecordingSidecar{
+		Labels: []monitor.TimelineLabel{{Label: "cat"}},
+	}, modTime)
+	unlabeled := writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_c.mp4", 10, modTime)
+	alertImage := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg", 10, modTime)
+	alertFace := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a_face.jpg", 10, modTime)
+	alertSet := writeStorageJSON(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.json", monitor.AlertSet{
+		Labels: []string{"face"},
+		Files:  []string{"cam1_a.jpg", "cam1_a_face.jpg"},
+	}, modTime)
+	alertCat := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_b.jpg", 10, modTime)
+	writeStorageJSON(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_b.json", monitor.AlertSet{
+		Labels: []string{"cat"},
+		Files:  []string{"cam1_b.jpg"},
+	}, modTime)
+
+	byLabel := map[string]time.Duration{"person": 30 * 24 * time.Hour, "face": 30 * 24 * time.Hour, "cat": 48 * time.Hour}
+	s := newStorage(dataDir, nil)
+	s.SetRetentions("cam1", []monitor.Retention{
+		{Category: monitor.CategoryRecordings, Monitor: "cam1", MaxAge: 7 * 24 * time.Hour, LabelMaxAge: byLabel},
+		{Category: monitor.CategoryAlerts, Monitor: "cam1", LabelMaxAge: byLabel},
+	})
+	s.prune(now)
+	tests := []struct {
+		path   string
+		exists bool
+	}{
+		{person, true},
+		{cat, false},
+		{unlabeled, true},
+		{alertImage, true},
+		{alertFace, true},
+		{alertSet, true},
+		{alertCat, false},
+	}
+	for _, test := range tests {
+		if result := storageExists(test.path); result != test.exists {
+			t.Errorf("prune() %s exists = %v, expected %v", filepath.Base(test.path), result, test.exists)
+		}
+	}
+}
+
+func TestStorageProtect(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	protected := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a_face.jpg", 100, now.Add(-3*time.Hour))
+	setImage := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.jpg", 100, now.Add(-3*time.Hour))
+	writeStorageJSON(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.json", monitor.AlertSet{
+		Files: []string{"cam1_a.jpg", "cam1_a_face.jpg"},
+	}, now.Add(-3*time.Hour))
+	other := writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_b.jpg", 100, now.Add(-3*time.Hour))
+
+	s := newStorage(dataDir, nil)
+	if err := s.Protect(monitor.CategoryAlerts, "cam1/2024-01-01/missing.jpg", true); err != ErrFileNotFound {
+		t.Errorf("Protect() = %v, expected %v", err, ErrFileNotFound)
+	}
+	if err := s.Protect("other", "cam1/2024-01-01/cam1_a_face.jpg", true); err != ErrUnknownCategory {
+		t.Errorf("Protect() = %v, expected %v", err, ErrUnknownCategory)
+	}
+	if err := s.Protect(monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a_face.jpg", true); err != nil {
+		t.Fatal(err)
+	}
+	s.SetRetentions("cam1", []monitor.Retention{
+		{Category: monitor.CategoryAlerts, Monitor: "cam1", MaxAge: time.Hour},
+	})
+	s.maxBytes = 1
+	s.prune(now)
+	if !storageExists(protected) || !storageExists(setImage) {
+		t.Error("prune() removed a protected alert set")
+	}
+	if storageExists(other) {
+		t.Error("prune() expected the unprotected alert pruned")
+	}
+
+	reloaded := newStorage(dataDir, nil)
+	list := reloaded.Protected()[monitor.CategoryAlerts]
+	if len(list) != 1 || list[0] != "cam1/2024-01-01/cam1_a_face.jpg" {
+		t.Errorf("Protected() = %v, expected the saved path", list)
+	}
+	if err := reloaded.Protect(monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a_face.jpg", false); err != nil {
+		t.Fatal(err)
+	}
+	reloaded.SetRetentions("cam1", []monitor.Retention{
+		{Category: monitor.CategoryAlerts, Monitor: "cam1", MaxAge: time.Hour},
+	})
+	reloaded.prune(now)
+	if storageExists(protected) || storageExists(setImage) {
+		t.Error("prune() expected the released alert set pruned")
+	}
+}
+
+func TestStorageProtectedGroup(t *testing.T) {
+	now := time.Now()
+	dataDir := t.TempDir()
+	writeStorageFile(t, dataDir, monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.json", 10, now)
+	writeStorageFile(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a_face.jpg", 100, now)
+	writeStorageJSON(t, dataDir, monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a.json", monitor.AlertSet{
+		Files: []string{"cam1_a.jpg", "cam1_a_face.jpg", "cam1_a_preview.jpg"},
+	}, now)
+
+	s := newStorage(dataDir, nil)
+	// the recording and an alert set image were archived, leaving their sidecars
+	s.archived = func(category string, rel string) bool {
+		return rel == "cam1/2024-01-01/cam1_a.mp4" || rel == "cam1/2024-01-01/cam1_a.jpg"
+	}
+	if err := s.Protect(monitor.CategoryRecordings, "cam1/2024-01-01/cam1_a.mp4", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Protect(monitor.CategoryAlerts, "cam1/2024-01-01/cam1_a_face.jpg", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Protect(monitor.CategoryRecordings, "cam1/2024-01-01/cam1_b.mp4", true); err != ErrFileNotFound {
+		t.Errorf("Protect() = %v, expected %v", err, ErrFileNotFound)
+	}
+
+	recordings := s.protectedGroup(monitor.CategoryRecordings)
+	if !recordings("cam1/2024-01-01/cam1_a.json") || !recordings("cam1/2024-01-01/cam1_a.vtt") {
+		t.Error("protectedGroup() expected the recording sidecars protected")
+	}
+	if recordings("cam1/2024-01-01/cam1_b.mp4") {
+		t.Error("protectedGroup() expected another recording unprotected")
+	}
+	alerts := s.protectedGroup(monitor.CategoryAlerts)
+	if !alerts("cam1/2024-01-01/cam1_a.jpg") || !alerts("cam1/2024-01-01/cam1_a_preview.jpg") {
+		t.Error("protectedGroup() expected the alert set protected")
+	}
+	if alerts("cam1/2024-01-01/cam1_b.jpg") {
+		t.Error("protectedGroup() expected another alert unprotected")
+	}
+}
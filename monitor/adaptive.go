@@ -0,0 +1,185 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Adaptive Constants
+const (
+	adaptiveDefaultMaxFps   = 30
+	adaptiveOverloadBusy    = 0.9
+	adaptiveUnderloadBusy   = 0.6
+	adaptiveRestoreSeconds  = 5
+	adaptiveMinGateEntries  = 64
+	adaptiveGateTolerancePc = 10
+)
+
+// AdaptiveStats contains the adaptive analysis rate state
+type AdaptiveStats struct {
+	MinFps     int
+	MaxFps     int
+	TargetFps  int
+	LastChange time.Time
+	LastReason string
+}
+
+// analysisGate decides which frames are analyzed by sheddable stages.
+// Decisions are remembered by frame time so every stage skips the same frames.
+type analysisGate struct {
+	mu           sync.Mutex
+	targetFps    int
+	lastAnalyzed time.Time
+	decisions    map[int64]bool
+	order        []int64
+	maxEntries   int
+}
+
+func newAnalysisGate() *analysisGate {
+	g := &analysisGate{
+		targetFps:  0,
+		decisions:  make(map[int64]bool),
+		order:      make([]int64, 0, adaptiveMinGateEntries),
+		maxEntries: adaptiveMinGateEntries,
+	}
+	return g
+}
+
+// setMaxFrames remembers the decisions of as many frames as can be queued between the stages
+func (g *analysisGate) setMaxFrames(frames int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxEntries = max(frames, adaptiveMinGateEntries)
+}
+
+func (g *analysisGate) setTargetFps(fps int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.targetFps = fps
+}
+
+// Analyze returns true when the frame created at the given time should be analyzed
+func (g *analysisGate) Analyze(created time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := created.UnixNano()
+	if decision, found := g.decisions[key]; found {
+		return decision
+	}
+	decision := true
+	if g.targetFps > 0 && !g.lastAnalyzed.IsZero() {
+		interval := time.Second / time.Duration(g.targetFps)
+		tolerance := interval * adaptiveGateTolerancePc / 100
+		decision = created.Sub(g.lastAnalyzed) >= interval-tolerance
+	}
+	if decision {
+		g.lastAnalyzed = created
+	}
+	if len(g.order) >= g.maxEntries {
+		delete(g.decisions, g.order[0])
+		g.order = g.order[1:]
+	}
+	g.decisions[key] = decision
+	g.order = append(g.order, key)
+	return decision
+}
+
+// adaptiveController adjusts the analysis rate from the sheddable stage stats
+type adaptiveController struct {
+	name         string
+	minFps       int
+	maxFps       int
+	gate         *analysisGate
+	mu           sync.Mutex
+	stats        AdaptiveStats
+	lastAnalyzed map[string]int
+	underloadSec int
+}
+
+func newAdaptiveController(name string, minFps int, maxFps int) *adaptiveController {
+	if maxFps < minFps {
+		maxFps = minFps
+	}
+	a := &adaptiveController{
+		name:   name,
+		minFps: minFps,
+		maxFps: maxFps,
+		gate:   newAnalysisGate(),
+		stats: AdaptiveStats{
+			MinFps:    minFps,
+			MaxFps:    maxFps,
+			TargetFps: maxFps,
+		},
+		lastAnalyzed: make(map[string]int),
+		underloadSec: 0,
+	}
+	a.gate.setTargetFps(maxFps)
+	return a
+}
+
+// Stats returns the current adaptive stats
+func (a *adaptiveController) Stats() AdaptiveStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// evaluate is called once per second with the sheddable stage stats
+func (a *adaptiveController) evaluate(stageStats []ProcessStats, bufferSize int) {
+	maxBusy := 0.0
+	maxQueueDepth := 0
+	for _, cur := range stageStats {
+		analyzedTotal := cur.OutTotal - cur.SkippedTotal
+		analyzedPerSec := analyzedTotal - a.lastAnalyzed[cur.Name]
+		a.lastAnalyzed[cur.Name] = analyzedTotal
+		busy := cur.LatencyP50Ms * float64(analyzedPerSec) / 1000
+		if busy > maxBusy {
+			maxBusy = busy
+		}
+		if cur.QueueDepth > maxQueueDepth {
+			maxQueueDepth = cur.QueueDepth
+		}
+	}
+	queueLimit := bufferSize / 2
+	if queueLimit < 1 {
+		queueLimit = 1
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	target := a.stats.TargetFps
+	reason := ""
+	if maxBusy > adaptiveOverloadBusy || maxQueueDepth > queueLimit {
+		a.underloadSec = 0
+		target = target * 3 / 4
+		if target >= a.stats.TargetFps {
+			target = a.stats.TargetFps - 1
+		}
+		if target < a.minFps {
+			target = a.minFps
+		}
+		reason = "overloaded"
+	} else if maxBusy < adaptiveUnderloadBusy && maxQueueDepth == 0 && target < a.maxFps {
+		a.underloadSec++
+		if a.underloadSec >= adaptiveRestoreSeconds {
+			a.underloadSec = 0
+			target++
+			if target > a.maxFps {
+				target = a.maxFps
+			}
+			reason = "underloaded"
+		}
+	} else {
+		a.underloadSec = 0
+	}
+	if target == a.stats.TargetFps {
+		return
+	}
+	log.Infof("Adaptive analysis %s changed %d to %d fps (busy %.2f, queue %d) for %s",
+		reason, a.stats.TargetFps, target, maxBusy, maxQueueDepth, a.name)
+	a.stats.TargetFps = target
+	a.stats.LastChange = time.Now()
+	a.stats.LastReason = reason
+	a.gate.setTargetFps(target)
+}
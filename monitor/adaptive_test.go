@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalysisGate(t *testing.T) {
+	g := newAnalysisGate()
+	g.setTargetFps(5)
+	start := time.Now()
+	analyzed := 0
+	for i := 0; i < 30; i++ {
+		created := start.Add(time.Duration(i) * time.Second / 30)
+		first := g.Analyze(created)
+		// A later stage must see the same decision for the same frame
+		if second := g.Analyze(created); second != first {
+			t.Fatalf("frame %d decision changed from %v to %v", i, first, second)
+		}
+		if first {
+			analyzed++
+		}
+	}
+	if analyzed != 5 {
+		t.Errorf("analyzed = %d, expected 5", analyzed)
+	}
+}
+
+func TestAnalysisGateQueued(t *testing.T) {
+	g := newAnalysisGate()
+	g.setTargetFps(5)
+	g.setMaxFrames(300)
+	start := time.Now()
+	first := make([]bool, 0, 300)
+	// a later stage sees the frames after a full queue
+	for i := 0; i < 300; i++ {
+		first = append(first, g.Analyze(start.Add(time.Duration(i)*time.Second/30)))
+	}
+	for i, expected := range first {
+		if result := g.Analyze(start.Add(time.Duration(i) * time.Second / 30)); result != expected {
+			t.Fatalf("Analyze() frame %d = %v, expected %v", i, result, expected)
+		}
+	}
+}
+
+func TestAdaptiveControllerEvaluate(t *testing.T) {
+	a := newAdaptiveController("test", 2, 10)
+	a.evaluate([]ProcessStats{{Name: StageTensor, OutTotal: 10, LatencyP50Ms: 200}}, 0)
+	if stats := a.Stats(); stats.TargetFps != 7 || stats.LastReason != "overloaded" {
+		t.Errorf("TargetFps = %d LastReason = %s, expected 7 and overloaded", stats.TargetFps, stats.LastReason)
+	}
+	for i := 0; i < adaptiveRestoreSeconds; i++ {
+		a.evaluate([]ProcessStats{{Name: StageTensor, OutTotal: 10, LatencyP50Ms: 200}}, 0)
+	}
+	if stats := a.Stats(); stats.TargetFps != 8 || stats.LastReason != "underloaded" {
+		t.Errorf("TargetFps = %d LastReason = %s, expected 8 and underloaded", stats.TargetFps, stats.LastReason)
+	}
+}
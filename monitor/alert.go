@@ -2,7 +2,6 @@ package monitor
 
 import (
 	"fmt"
-	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -12,11 +11,11 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/jonoton/go-dir"
 	"github.com/jonoton/go-notify"
 	"github.com/jonoton/go-ringbuffer"
 	"github.com/jonoton/go-runtime"
 	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/seal"
 )
 
 // Alert Constants
@@ -48,6 +47,14 @@ type Alert struct {
 	cancel        chan bool
 	cancelOnce    sync.Once
 	LastAlert     AlertTimes
+	tracker       *ProcessTracker
+	bufferedCount int
+	bufferedMu    sync.Mutex
+	notifyEmail   bool
+	notifyText    bool
+	notifyMu      sync.Mutex
+	overlay       *Overlay
+	key           *seal.Key
 }
 
 // NewAlert creates a new Alert
@@ -55,7 +62,7 @@ func NewAlert(name string, notifier *notify.Notify, notifyRxConf *notify.RxConfi
 	if saveDirectory == "" || alertConf == nil {
 		return nil
 	}
-	alertDir := filepath.Clean(saveDirectory+"/alerts") + string(filepath.Separator)
+	alertDir := MonitorDirectory(saveDirectory, CategoryAlerts, name)
 	os.MkdirAll(alertDir, os.ModePerm)
 
 	a := &Alert{
@@ -71,6 +78,12 @@ func NewAlert(name string, notifier *notify.Notify, notifyRxConf *notify.RxConfi
 		done:          make(chan bool),
 		cancel:        make(chan bool),
 		LastAlert:     AlertTimes{},
+		tracker:       NewProcessTracker("alert"),
+		overlay:       NewOverlay(name, nil),
+		bufferedCount: 0,
+		notifyEmail:   true,
+		notifyText:    true,
+		key:           nil,
 	}
 	return a
 }
@@ -87,12 +100,10 @@ func (a *Alert) Start() {
 		for {
 			select {
 			case <-a.cancel:
-				a.prune()
 				a.doAlerts()
 				break Loop
 			case <-a.hourTick.C:
 				a.hourSent = 0
-				a.prune()
 			case <-a.intervalTick.C:
 				a.doAlerts()
 			}
@@ -107,11 +118,39 @@ func (a *Alert) Start() {
 // Push a processed image to buffer
 func (a *Alert) Push(img *videosource.ProcessedImage) {
 	if img.HasObject() {
+		a.bufferedMu.Lock()
+		a.tracker.In(a.bufferedCount)
+		a.bufferedMu.Unlock()
+		start := time.Now()
 		a.addUpdateBuffer(img.Ref())
+		a.tracker.Out(start)
 	}
 	img.Cleanup()
 }
 
+// SetNotify sets whether alerts are sent by email and text
+func (a *Alert) SetNotify(email bool, text bool) {
+	a.notifyMu.Lock()
+	defer a.notifyMu.Unlock()
+	a.notifyEmail = email
+	a.notifyText = text
+}
+
+// SetOverlay sets the overlay drawn on highlighted alert images
+func (a *Alert) SetOverlay(overlay *Overlay) {
+	a.overlay = overlay
+}
+
+// SetEncryption sets the key that seals alert images once they are sent
+func (a *Alert) SetEncryption(key *seal.Key) {
+	a.key = key
+}
+
+// Stats returns the current process stats
+func (a *Alert) Stats() ProcessStats {
+	return a.tracker.Stats()
+}
+
 // Close the processes
 func (a *Alert) Close() {
 	a.cancelOnce.Do(func() {
@@ -127,6 +166,12 @@ func (a *Alert) addUpdateBuffer(img *videosource.ProcessedImage) {
 	sort.Stable(videosource.ProcessedImageByObjPercent(allBuffered))
 	sort.Stable(videosource.ProcessedImageByFaceLen(allBuffered))
 	sort.Stable(videosource.ProcessedImageByFacePercent(allBuffered))
+	a.bufferedMu.Lock()
+	if evicted := a.bufferedCount + 1 - len(allBuffered); evicted > 0 {
+		a.tracker.Drop(evicted)
+	}
+	a.bufferedCount = len(allBuffered)
+	a.bufferedMu.Unlock()
 	for i := len(allBuffered) - 1; i >= 0; i-- {
 		a.ringBuffer.Add(&allBuffered[i])
 	}
@@ -140,51 +185,20 @@ func (a *Alert) ptrSliceToSlice(ptrSlice []*videosource.ProcessedImage) []videos
 	return result
 }
 
-func (a *Alert) prune() {
-	a.deleteOldAlerts()
-	a.deleteWhenFull()
-}
-
 func (a *Alert) doAlerts() {
 	poppedList := a.ptrSliceToSlice(a.ringBuffer.GetAll())
+	a.bufferedMu.Lock()
+	a.bufferedCount = 0
+	a.bufferedMu.Unlock()
 	sort.Sort(videosource.ProcessedImageByCreatedTime(poppedList))
 	nowTime := time.Now()
 	nowTimeStr := getFormattedKitchenTimestamp(nowTime)
 
 	a.setLastAlerts(poppedList)
-	imageInfos := a.saveAlerts(poppedList)
+	imageInfos, written := a.saveAlerts(poppedList)
 	a.sendAlerts(imageInfos, nowTimeStr)
-}
-
-func (a *Alert) deleteOldAlerts() {
-	expiredFiles, _ := dir.Expired(a.saveDirectory, dir.RegexBeginsWith(a.name),
-		time.Now(), time.Duration(a.alertConf.DeleteAfterHours)*time.Hour)
-	for _, fileInfo := range expiredFiles {
-		fullPath := filepath.Clean(a.saveDirectory + string(filepath.Separator) + fileInfo.Name())
-		err := os.Remove(fullPath)
-		if err != nil {
-			log.Errorln(err)
-		}
-	}
-}
-
-func (a *Alert) deleteWhenFull() {
-	dirSize, _ := dir.Size(a.saveDirectory, dir.RegexBeginsWith(a.name))
-	if int(math.Ceil(dir.BytesToGigaBytes(dirSize))) > a.alertConf.DeleteAfterGB {
-		files, _ := dir.List(a.saveDirectory, dir.RegexBeginsWith(a.name))
-		sort.Sort(dir.AscendingTime(files))
-		for _, fileInfo := range files {
-			if int(math.Ceil(dir.BytesToGigaBytes(dirSize))) <= a.alertConf.DeleteAfterGB {
-				break
-			}
-			dirSize -= uint64(fileInfo.Size())
-			fullPath := filepath.Clean(a.saveDirectory + string(filepath.Separator) + fileInfo.Name())
-			err := os.Remove(fullPath)
-			if err != nil {
-				log.Errorln(err)
-			}
-		}
-	}
+	// attachments are sent as saved
+	sealFiles(a.key, written...)
 }
 
 func hasPersonObject(objects []videosource.ObjectInfo) (found bool) {
@@ -223,24 +237,28 @@ func (a *Alert) setLastAlerts(poppedList []videosource.ProcessedImage) {
 	}
 }
 
-func (a *Alert) saveAlerts(poppedList []videosource.ProcessedImage) (result []imageInfo) {
+func (a *Alert) saveAlerts(poppedList []videosource.ProcessedImage) (result []imageInfo, written []string) {
 	if len(poppedList) == 0 {
 		return
 	}
 
 	result = make([]imageInfo, 0)
+	written = make([]string, 0)
 
 	for index, curPop := range poppedList {
 		imageInfo := imageInfo{
 			Name: fmt.Sprintf("Image %d", index+1),
 			Time: getFormattedKitchenTimestamp(curPop.Original.CreatedTime()),
 		}
+		saveDir := makeDateDirectory(a.saveDirectory, curPop.Original.CreatedTime())
 		infos := make([]attachedInfo, 0)
+		saved := make([]string, 0)
 		if a.alertConf.SaveOriginal && curPop.Original.IsFilled() {
 			title := "Original"
 			percentage := ""
-			videosource.SavePreview(curPop.Original, curPop.Original.CreatedTime(), a.saveDirectory, a.name, title, percentage)
-			s := videosource.SaveImage(curPop.Original, curPop.Original.CreatedTime(), a.saveDirectory, a.alertConf.SaveQuality, a.name, title, percentage)
+			p := videosource.SavePreview(curPop.Original, curPop.Original.CreatedTime(), saveDir, a.name, title, percentage)
+			s := videosource.SaveImage(curPop.Original, curPop.Original.CreatedTime(), saveDir, a.alertConf.SaveQuality, a.name, title, percentage)
+			saved = append(saved, s, p)
 			info := attachedInfo{
 				Title:      title,
 				Percentage: percentage,
@@ -252,9 +270,10 @@ func (a *Alert) saveAlerts(poppedList []videosource.ProcessedImage) (result []im
 		if a.alertConf.SaveHighlighted && curPop.HasObject() {
 			title := "Highlighted"
 			percentage := ""
-			highlighted := curPop.HighlightedAll()
-			videosource.SavePreview(*highlighted, curPop.Original.CreatedTime(), a.saveDirectory, a.name, title, percentage)
-			s := videosource.SaveImage(*highlighted, curPop.Original.CreatedTime(), a.saveDirectory, a.alertConf.SaveQuality, a.name, title, percentage)
+			highlighted := a.overlay.Alert(&curPop)
+			p := videosource.SavePreview(*highlighted, curPop.Original.CreatedTime(), saveDir, a.name, title, percentage)
+			s := videosource.SaveImage(*highlighted, curPop.Original.CreatedTime(), saveDir, a.alertConf.SaveQuality, a.name, title, percentage)
+			saved = append(saved, s, p)
 			highlighted.Cleanup()
 			info := attachedInfo{
 				Title:      title,
@@ -269,8 +288,9 @@ func (a *Alert) saveAlerts(poppedList []videosource.ProcessedImage) (result []im
 				title := cur.Description
 				percentage := fmt.Sprintf("%d", cur.Percentage)
 				object := curPop.Object(i)
-				videosource.SavePreview(*object, curPop.Original.CreatedTime(), a.saveDirectory, a.name, title, percentage)
-				s := videosource.SaveImage(*object, curPop.Original.CreatedTime(), a.saveDirectory, 100, a.name, title, percentage)
+				p := videosource.SavePreview(*object, curPop.Original.CreatedTime(), saveDir, a.name, title, percentage)
+				s := videosource.SaveImage(*object, curPop.Original.CreatedTime(), saveDir, 100, a.name, title, percentage)
+				saved = append(saved, s, p)
 				object.Cleanup()
 				info := attachedInfo{
 					Title:      title,
@@ -286,8 +306,9 @@ func (a *Alert) saveAlerts(poppedList []videosource.ProcessedImage) (result []im
 				title := "Face"
 				percentage := fmt.Sprintf("%d", cur.Percentage)
 				face := curPop.Face(i)
-				videosource.SavePreview(*face, curPop.Original.CreatedTime(), a.saveDirectory, a.name, title, percentage)
-				s := videosource.SaveImage(*face, curPop.Original.CreatedTime(), a.saveDirectory, 100, a.name, title, percentage)
+				p := videosource.SavePreview(*face, curPop.Original.CreatedTime(), saveDir, a.name, title, percentage)
+				s := videosource.SaveImage(*face, curPop.Original.CreatedTime(), saveDir, 100, a.name, title, percentage)
+				saved = append(saved, s, p)
 				face.Cleanup()
 				info := attachedInfo{
 					Title:      title,
@@ -298,6 +319,12 @@ func (a *Alert) saveAlerts(poppedList []videosource.ProcessedImage) (result []im
 				infos = append(infos, info)
 			}
 		}
+		if setPath, err := saveAlertSet(a.name, curPop.Original.CreatedTime(), alertSetLabels(&curPop), saved); err != nil {
+			log.Warnln("Could not save alert set", a.name, err)
+		} else if setPath != "" {
+			saved = append(saved, setPath)
+		}
+		written = append(written, saved...)
 		imageInfo.AttachedInfo = infos
 		result = append(result, imageInfo)
 		curPop.Cleanup()
@@ -337,8 +364,10 @@ func (a *Alert) sendAlerts(imageInfos []imageInfo, alertTime string) {
 	sendAttachments := a.hourSent < a.alertConf.MaxSendAttachmentsPerHour
 	emails := a.notifyRxConf.Email
 	phones := a.notifyRxConf.GetPhones()
-	hasEmails := len(emails) > 0
-	hasPhones := len(phones) > 0
+	a.notifyMu.Lock()
+	hasEmails := len(emails) > 0 && a.notifyEmail
+	hasPhones := len(phones) > 0 && a.notifyText
+	a.notifyMu.Unlock()
 	if len(imageInfos) > 0 && (hasEmails || hasPhones) {
 		attachments := make([]string, 0)
 		title := "Scout Alert " + a.name
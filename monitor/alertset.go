@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jonoton/go-videosource"
+
+	"github.com/jonoton/scout/seal"
+)
+
+// AlertSet lists the files saved for one alert image and the labels detected in it.
+// It is saved as JSON named like the first file, and the files are kept or removed together.
+type AlertSet struct {
+	Monitor string
+	Time    time.Time
+	Labels  []string
+	Files   []string
+}
+
+// ReadAlertSet reads an alert set file, decrypting it with the key when sealed
+func ReadAlertSet(key *seal.Key, setPath string) (*AlertSet, error) {
+	data, err := seal.ReadFile(key, setPath)
+	if err != nil {
+		return nil, err
+	}
+	s := &AlertSet{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// alertSetLabels returns the sorted object labels of the image and face when it has faces
+func alertSetLabels(img *videosource.ProcessedImage) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, cur := range img.Objects {
+		if !seen[cur.Description] {
+			seen[cur.Description] = true
+			result = append(result, cur.Description)
+		}
+	}
+	if len(img.Faces) > 0 {
+		result = append(result, EventLabelFace)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// saveAlertSet writes the set of the saved paths next to them and returns its path, doing nothing when none were saved
+func saveAlertSet(monitorName string, created time.Time, labels []string, paths []string) (string, error) {
+	s := &AlertSet{
+		Monitor: monitorName,
+		Time:    created,
+		Labels:  labels,
+		Files:   make([]string, 0, len(paths)),
+	}
+	first := ""
+	for _, cur := range paths {
+		if cur == "" {
+			continue
+		}
+		if first == "" {
+			first = cur
+		}
+		s.Files = append(s.Files, filepath.Base(cur))
+	}
+	if first == "" {
+		return "", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	setPath := recordingBase(first) + RecordingSidecarExt
+	return setPath, os.WriteFile(setPath, data, 0644)
+}
@@ -0,0 +1,213 @@
+package monitor
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"github.com/jonoton/go-videosource"
+)
+
+// Align Constants
+const (
+	alignTolerance  = 500 * time.Millisecond
+	alignMaxWait    = time.Second
+	alignMaxHistory = 64
+)
+
+type queuedFrame struct {
+	img   videosource.Image
+	found bool
+}
+
+// frameQueue holds frames in pipeline order.
+// Stages output one image per input image in order, so the queue stays aligned with the pipeline output.
+type frameQueue struct {
+	mu     sync.Mutex
+	frames []queuedFrame
+}
+
+func newFrameQueue() *frameQueue {
+	q := &frameQueue{
+		frames: make([]queuedFrame, 0),
+	}
+	return q
+}
+
+func (q *frameQueue) push(img videosource.Image, found bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.frames = append(q.frames, queuedFrame{img: img, found: found})
+}
+
+func (q *frameQueue) pop() (img videosource.Image, found bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.frames) == 0 {
+		return
+	}
+	cur := q.frames[0]
+	q.frames = q.frames[1:]
+	return cur.img, cur.found
+}
+
+// cleanup releases all held frames
+func (q *frameQueue) cleanup() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, cur := range q.frames {
+		if cur.found {
+			cur.img.Cleanup()
+		}
+	}
+	q.frames = q.frames[:0]
+}
+
+// restore moves the detection results onto the next queued frame.
+// The detected image is returned unchanged when no frame was queued.
+func (q *frameQueue) restore(detected videosource.ProcessedImage) videosource.ProcessedImage {
+	target, found := q.pop()
+	if !found {
+		return detected
+	}
+	return moveDetections(detected, target)
+}
+
+// alignedDetections are the detection results of a detection stream frame and its size
+type alignedDetections struct {
+	created    time.Time
+	size       image.Point
+	detections videosource.ProcessedImage
+}
+
+type pendingFrame struct {
+	img     videosource.Image
+	arrived time.Time
+}
+
+// streamAligner holds main stream frames until the detection results captured nearest to them are known
+// and attaches those results, rescaled, to each main stream frame.
+type streamAligner struct {
+	history []alignedDetections
+	pending []pendingFrame
+}
+
+func newStreamAligner() *streamAligner {
+	s := &streamAligner{
+		history: make([]alignedDetections, 0, alignMaxHistory),
+		pending: make([]pendingFrame, 0),
+	}
+	return s
+}
+
+// update keeps the detection results of the frame and cleans up the detection frame
+func (s *streamAligner) update(detected videosource.ProcessedImage) {
+	if len(s.history) >= alignMaxHistory {
+		s.history = s.history[1:]
+	}
+	s.history = append(s.history, alignedDetections{
+		created: detected.Original.CreatedTime(),
+		size:    image.Pt(detected.Original.Width(), detected.Original.Height()),
+		detections: videosource.ProcessedImage{
+			Motions: detected.Motions,
+			Objects: detected.Objects,
+			Faces:   detected.Faces,
+		},
+	})
+	detected.Original.Cleanup()
+}
+
+// addMain holds the main stream frame until its detection results are known
+func (s *streamAligner) addMain(img videosource.Image, now time.Time) {
+	s.pending = append(s.pending, pendingFrame{img: img, arrived: now})
+}
+
+// ready returns the held frames, in order, with the detection results captured nearest to them.
+// A frame is ready once results captured at or after it arrived, once it waited alignMaxWait, or when all is set.
+func (s *streamAligner) ready(now time.Time, all bool) []videosource.ProcessedImage {
+	result := make([]videosource.ProcessedImage, 0)
+	for len(s.pending) > 0 {
+		cur := s.pending[0]
+		created := cur.img.CreatedTime()
+		known := len(s.history) > 0 && !s.history[len(s.history)-1].created.Before(created)
+		if !all && !known && now.Sub(cur.arrived) < alignMaxWait {
+			break
+		}
+		s.pending = s.pending[1:]
+		nearest := nearestDetections(s.history, created)
+		if nearest < 0 {
+			result = append(result, *videosource.NewProcessedImage(cur.img))
+			continue
+		}
+		result = append(result, rescaleDetections(s.history[nearest].detections, s.history[nearest].size, cur.img))
+	}
+	return result
+}
+
+// cleanup releases the held frames
+func (s *streamAligner) cleanup() {
+	for _, cur := range s.pending {
+		cur.img.Cleanup()
+	}
+	s.pending = s.pending[:0]
+}
+
+// nearestDetections returns the index of the results captured nearest to created within alignTolerance, or -1 when none
+func nearestDetections(history []alignedDetections, created time.Time) int {
+	nearest := -1
+	var nearestDelta time.Duration
+	for i := range history {
+		delta := history[i].created.Sub(created)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta <= alignTolerance && (nearest < 0 || delta < nearestDelta) {
+			nearest = i
+			nearestDelta = delta
+		}
+	}
+	return nearest
+}
+
+// moveDetections returns the detection results rescaled onto the target image and cleans up the detected image
+func moveDetections(detected videosource.ProcessedImage, target videosource.Image) videosource.ProcessedImage {
+	if !target.IsFilled() || detected.Original.Width() == 0 || detected.Original.Height() == 0 {
+		target.Cleanup()
+		return detected
+	}
+	result := rescaleDetections(detected, image.Pt(detected.Original.Width(), detected.Original.Height()), target)
+	detected.Original.Cleanup()
+	return result
+}
+
+// rescaleDetections returns the target image with the detection results found on an image of the size
+func rescaleDetections(detected videosource.ProcessedImage, size image.Point, target videosource.Image) videosource.ProcessedImage {
+	result := videosource.NewProcessedImage(target)
+	if size.X == 0 || size.Y == 0 {
+		return *result
+	}
+	scaleX := float64(target.Width()) / float64(size.X)
+	scaleY := float64(target.Height()) / float64(size.Y)
+	for _, cur := range detected.Motions {
+		cur.Rect = scaleRect(cur.Rect, scaleX, scaleY)
+		result.Motions = append(result.Motions, cur)
+	}
+	for _, cur := range detected.Objects {
+		cur.Rect = scaleRect(cur.Rect, scaleX, scaleY)
+		result.Objects = append(result.Objects, cur)
+	}
+	for _, cur := range detected.Faces {
+		cur.Rect = scaleRect(cur.Rect, scaleX, scaleY)
+		result.Faces = append(result.Faces, cur)
+	}
+	return *result
+}
+
+func scaleRect(rect image.Rectangle, scaleX float64, scaleY float64) image.Rectangle {
+	return image.Rect(
+		int(float64(rect.Min.X)*scaleX),
+		int(float64(rect.Min.Y)*scaleY),
+		int(float64(rect.Max.X)*scaleX),
+		int(float64(rect.Max.Y)*scaleY),
+	)
+}
@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func TestScaleRect(t *testing.T) {
+	tests := []struct {
+		name     string
+		rect     image.Rectangle
+		scaleX   float64
+		scaleY   float64
+		expected image.Rectangle
+	}{
+		{name: "same", rect: image.Rect(10, 20, 30, 40), scaleX: 1, scaleY: 1, expected: image.Rect(10, 20, 30, 40)},
+		{name: "substream to main", rect: image.Rect(64, 36, 128, 72), scaleX: 3, scaleY: 3, expected: image.Rect(192, 108, 384, 216)},
+		{name: "aspect change", rect: image.Rect(10, 10, 20, 20), scaleX: 2, scaleY: 1.5, expected: image.Rect(20, 15, 40, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := scaleRect(tt.rect, tt.scaleX, tt.scaleY); result != tt.expected {
+				t.Errorf("scaleRect() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNearestDetections(t *testing.T) {
+	start := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	history := []alignedDetections{
+		{created: start},
+		{created: start.Add(200 * time.Millisecond)},
+		{created: start.Add(400 * time.Millisecond)},
+	}
+	tests := []struct {
+		name     string
+		created  time.Time
+		expected int
+	}{
+		{name: "exact", created: start.Add(200 * time.Millisecond), expected: 1},
+		{name: "nearest earlier", created: start.Add(250 * time.Millisecond), expected: 1},
+		{name: "nearest later", created: start.Add(350 * time.Millisecond), expected: 2},
+		{name: "before all", created: start.Add(-300 * time.Millisecond), expected: 0},
+		{name: "outside tolerance", created: start.Add(400*time.Millisecond + alignTolerance + time.Millisecond), expected: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := nearestDetections(history, tt.created); result != tt.expected {
+				t.Errorf("nearestDetections() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+	if result := nearestDetections(nil, start); result != -1 {
+		t.Errorf("nearestDetections() without history = %v, expected %v", result, -1)
+	}
+}
@@ -10,23 +10,38 @@ import (
 
 // Config contains the parameters for Monitor
 type Config struct {
-	Filename                   string `yaml:"filename,omitempty"`
-	URL                        string `yaml:"url,omitempty"`
-	MaxSourceFps               int    `yaml:"maxSourceFps,omitempty"`
-	MaxOutputFps               int    `yaml:"maxOutputFps,omitempty"`
-	Quality                    int    `yaml:"quality,omitempty"`
-	CaptureTimeoutMilliSeconds int    `yaml:"captureTimeoutMilliSeconds,omitempty"`
-	StaleTimeout               int    `yaml:"staleTimeout,omitempty"`
-	StaleMaxRetry              int    `yaml:"staleMaxRetry,omitempty"`
-	BufferSeconds              int    `yaml:"bufferSeconds,omitempty"`
-	DelayBufferMilliSeconds    int    `yaml:"delayBufferMilliSeconds,omitempty"`
-	MotionFilename             string `yaml:"motion,omitempty"`
-	TensorFilename             string `yaml:"tensor,omitempty"`
-	FaceFilename               string `yaml:"face,omitempty"`
-	NotifyRxFilename           string `yaml:"notifyRx,omitempty"`
-	AlertFilename              string `yaml:"alert,omitempty"`
-	RecordFilename             string `yaml:"record,omitempty"`
-	ContinuousFilename         string `yaml:"continuous,omitempty"`
+	Filename                   string          `yaml:"filename,omitempty"`
+	URL                        string          `yaml:"url,omitempty"`
+	DetectFilename             string          `yaml:"detectFilename,omitempty"`
+	DetectURL                  string          `yaml:"detectUrl,omitempty"`
+	View                       *View           `yaml:"view,omitempty"`
+	Overlay                    *OverlayConfig  `yaml:"overlay,omitempty"`
+	MaxSourceFps               int             `yaml:"maxSourceFps,omitempty"`
+	MaxOutputFps               int             `yaml:"maxOutputFps,omitempty"`
+	Quality                    int             `yaml:"quality,omitempty"`
+	CaptureTimeoutMilliSeconds int             `yaml:"captureTimeoutMilliSeconds,omitempty"`
+	StaleTimeout               int             `yaml:"staleTimeout,omitempty"`
+	StaleMaxRetry              int             `yaml:"staleMaxRetry,omitempty"`
+	BufferSeconds              int             `yaml:"bufferSeconds,omitempty"`
+	DelayBufferMilliSeconds    int             `yaml:"delayBufferMilliSeconds,omitempty"`
+	PreprocessFilename         string          `yaml:"preprocess,omitempty"`
+	MotionFilename             string          `yaml:"motion,omitempty"`
+	TensorFilename             string          `yaml:"tensor,omitempty"`
+	FaceFilename               string          `yaml:"face,omitempty"`
+	NotifyRxFilename           string          `yaml:"notifyRx,omitempty"`
+	AlertFilename              string          `yaml:"alert,omitempty"`
+	RecordFilename             string          `yaml:"record,omitempty"`
+	ContinuousFilename         string          `yaml:"continuous,omitempty"`
+	EventFilename              string          `yaml:"event,omitempty"`
+	Pipeline                   []PipelineStage `yaml:"pipeline,omitempty"`
+	AnalysisMinFps             int             `yaml:"analysisMinFps,omitempty"`
+	AnalysisMaxFps             int             `yaml:"analysisMaxFps,omitempty"`
+}
+
+// PipelineStage contains the stage name and optional config for a pipeline step
+type PipelineStage struct {
+	Stage  string `yaml:"stage"`
+	Config string `yaml:"config,omitempty"`
 }
 
 // NewConfig creates a new Config
@@ -45,18 +60,35 @@ func NewConfig(configPath string) *Config {
 	return c
 }
 
+// GetPipeline returns the ordered pipeline stages, defaulting to preprocess when set, motion, tensor, and face
+func (c *Config) GetPipeline() []PipelineStage {
+	if len(c.Pipeline) > 0 {
+		return c.Pipeline
+	}
+	result := make([]PipelineStage, 0)
+	if c.PreprocessFilename != "" {
+		result = append(result, PipelineStage{Stage: StagePreprocess, Config: c.PreprocessFilename})
+	}
+	return append(result,
+		PipelineStage{Stage: StageMotion, Config: c.MotionFilename},
+		PipelineStage{Stage: StageTensor, Config: c.TensorFilename},
+		PipelineStage{Stage: StageFace, Config: c.FaceFilename},
+	)
+}
+
 // RecordConfig contains the parameters for record settings
 type RecordConfig struct {
-	RecordObjects    bool   `yaml:"recordObjects,omitempty"`
-	MaxPreSec        int    `yaml:"maxPreSec,omitempty"`
-	TimeoutSec       int    `yaml:"timeoutSec,omitempty"`
-	MaxSec           int    `yaml:"maxSec,omitempty"`
-	DeleteAfterHours int    `yaml:"deleteAfterHours,omitempty"`
-	DeleteAfterGB    int    `yaml:"deleteAfterGB,omitempty"`
-	Codec            string `yaml:"codec,omitempty"`
-	FileType         string `yaml:"fileType,omitempty"`
-	BufferSeconds    int    `yaml:"bufferSeconds,omitempty"`
-	PortableOnly     bool   `yaml:"portableOnly,omitempty"`
+	RecordObjects           bool           `yaml:"recordObjects,omitempty"`
+	MaxPreSec               int            `yaml:"maxPreSec,omitempty"`
+	TimeoutSec              int            `yaml:"timeoutSec,omitempty"`
+	MaxSec                  int            `yaml:"maxSec,omitempty"`
+	DeleteAfterHours        int            `yaml:"deleteAfterHours,omitempty"`
+	DeleteAfterHoursByLabel map[string]int `yaml:"deleteAfterHoursByLabel,omitempty"`
+	DeleteAfterGB           int            `yaml:"deleteAfterGB,omitempty"`
+	Codec                   string         `yaml:"codec,omitempty"`
+	FileType                string         `yaml:"fileType,omitempty"`
+	BufferSeconds           int            `yaml:"bufferSeconds,omitempty"`
+	PortableOnly            bool           `yaml:"portableOnly,omitempty"`
 }
 
 // NewRecordConfig creates a new RecordConfig
@@ -105,17 +137,18 @@ func NewContinuousConfig(configPath string) *ContinuousConfig {
 
 // AlertConfig contains the parameters for alert notification settings
 type AlertConfig struct {
-	IntervalMinutes           int  `yaml:"intervalMinutes,omitempty"`
-	MaxImagesPerInterval      int  `yaml:"maxImagesPerInterval,omitempty"`
-	MaxSendAttachmentsPerHour int  `yaml:"maxSendAttachmentsPerHour,omitempty"`
-	SaveQuality               int  `yaml:"saveQuality,omitempty"`
-	SaveOriginal              bool `yaml:"saveOriginal,omitempty"`
-	SaveHighlighted           bool `yaml:"saveHighlighted,omitempty"`
-	SaveObjectsCount          int  `yaml:"saveObjectsCount,omitempty"`
-	SaveFacesCount            int  `yaml:"saveFacesCount,omitempty"`
-	TextAttachments           bool `yaml:"textAttachments,omitempty"`
-	DeleteAfterHours          int  `yaml:"deleteAfterHours,omitempty"`
-	DeleteAfterGB             int  `yaml:"deleteAfterGB,omitempty"`
+	IntervalMinutes           int            `yaml:"intervalMinutes,omitempty"`
+	MaxImagesPerInterval      int            `yaml:"maxImagesPerInterval,omitempty"`
+	MaxSendAttachmentsPerHour int            `yaml:"maxSendAttachmentsPerHour,omitempty"`
+	SaveQuality               int            `yaml:"saveQuality,omitempty"`
+	SaveOriginal              bool           `yaml:"saveOriginal,omitempty"`
+	SaveHighlighted           bool           `yaml:"saveHighlighted,omitempty"`
+	SaveObjectsCount          int            `yaml:"saveObjectsCount,omitempty"`
+	SaveFacesCount            int            `yaml:"saveFacesCount,omitempty"`
+	TextAttachments           bool           `yaml:"textAttachments,omitempty"`
+	DeleteAfterHours          int            `yaml:"deleteAfterHours,omitempty"`
+	DeleteAfterHoursByLabel   map[string]int `yaml:"deleteAfterHoursByLabel,omitempty"`
+	DeleteAfterGB             int            `yaml:"deleteAfterGB,omitempty"`
 }
 
 // NewAlertConfig creates a new AlertConfig
@@ -133,3 +166,28 @@ func NewAlertConfig(configPath string) *AlertConfig {
 	}
 	return c
 }
+
+// EventConfig contains the parameters for event settings
+type EventConfig struct {
+	DebounceSeconds  int  `yaml:"debounceSeconds,omitempty"`
+	SaveSnapshot     bool `yaml:"saveSnapshot,omitempty"`
+	SnapshotQuality  int  `yaml:"snapshotQuality,omitempty"`
+	DeleteAfterHours int  `yaml:"deleteAfterHours,omitempty"`
+	DeleteAfterGB    int  `yaml:"deleteAfterGB,omitempty"`
+}
+
+// NewEventConfig creates a new EventConfig
+func NewEventConfig(configPath string) *EventConfig {
+	c := &EventConfig{}
+	yamlFile, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Printf("yamlFile.Get err   #%v ", err)
+		return nil
+	}
+	err = yaml.Unmarshal(yamlFile, c)
+	if err != nil {
+		log.Printf("Unmarshal: %v", err)
+		return nil
+	}
+	return c
+}
@@ -1,18 +1,14 @@
 package monitor
 
 import (
-	"math"
 	"os"
 	"path/filepath"
-	"sort"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
-	"github.com/jonoton/go-dir"
 	pubsubmutex "github.com/jonoton/go-pubsubmutex"
 	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/seal"
 )
 
 const topicContinuousImages = "topic-continuous-images"
@@ -28,7 +24,10 @@ type Continuous struct {
 	done           chan bool
 	cancel         chan bool
 	cancelOnce     sync.Once
-	hourTick       *time.Ticker
+	fileTick       *time.Ticker
+	tracker        *ProcessTracker
+	key            *seal.Key
+	lastSent       time.Time
 }
 
 // NewContinuous creates a new Continuous
@@ -36,7 +35,7 @@ func NewContinuous(name string, saveDirectory string, continuousConf *Continuous
 	if saveDirectory == "" || continuousConf == nil {
 		return nil
 	}
-	continuousDir := filepath.Clean(saveDirectory+"/continuous") + string(filepath.Separator)
+	continuousDir := MonitorDirectory(saveDirectory, CategoryContinuous, name)
 	os.MkdirAll(continuousDir, os.ModePerm)
 	codec := "mp4v"
 	if len(continuousConf.Codec) == 4 {
@@ -57,7 +56,9 @@ func NewContinuous(name string, saveDirectory string, continuousConf *Continuous
 		bufferSize: continuousConf.BufferSeconds * outFps,
 		done:       make(chan bool),
 		cancel:     make(chan bool),
-		hourTick:   time.NewTicker(time.Hour),
+		fileTick:   time.NewTicker(10 * time.Second),
+		tracker:    NewProcessTracker("continuous"),
+		key:        nil,
 	}
 	pubsubmutex.RegisterTopic[*videosource.ProcessedImage](&c.pubsub, topicContinuousImages)
 
@@ -69,17 +70,23 @@ func (c *Continuous) Wait() {
 	<-c.done
 }
 
+// SetEncryption sets the key that seals finished segments
+func (c *Continuous) SetEncryption(key *seal.Key) {
+	c.key = key
+}
+
 // Start the processes
 func (c *Continuous) Start() {
 	go func() {
 		c.writer.Start()
 		imageSub, _ := pubsubmutex.Subscribe[*videosource.ProcessedImage](&c.pubsub,
 			topicContinuousImages, c.pubsub.GetUniqueSubscriberID(), c.bufferSize)
+		c.tracker.SetQueueDepthFunc(func() int { return len(imageSub.Ch) })
 	Loop:
 		for {
 			select {
-			case <-c.hourTick.C:
-				c.prune()
+			case <-c.fileTick.C:
+				c.fileSegments(settledBefore(c.lastSent, c.ContinuousConf.BufferSeconds))
 			case msg, ok := <-imageSub.Ch:
 				if !ok {
 					if msg.Data != nil {
@@ -92,66 +99,50 @@ func (c *Continuous) Start() {
 					continue
 				}
 				img := msg.Data
+				c.tracker.In(len(imageSub.Ch))
+				start := time.Now()
 				c.process(*img)
+				c.tracker.Out(start)
 			case <-c.cancel:
 				break Loop
 			}
 		}
 		imageSub.Unsubscribe()
-		c.hourTick.Stop()
-		c.prune()
+		c.fileTick.Stop()
 		c.writer.Close()
 		c.writer.Wait()
+		c.fileSegments(time.Now())
 		c.pubsub.Close()
 		close(c.done)
 	}()
 }
 
-func (c *Continuous) process(img videosource.ProcessedImage) {
-	c.writer.Trigger()
-	c.writer.Send(img)
-}
-
-func (c *Continuous) prune() {
-	c.deleteOldContinuous()
-	c.deleteWhenFull()
-}
-
-func (c *Continuous) deleteOldContinuous() {
-	expiredFiles, _ := dir.Expired(c.saveDirectory, dir.RegexBeginsWith(c.name),
-		time.Now(), time.Duration(c.ContinuousConf.DeleteAfterHours)*time.Hour)
-	for _, fileInfo := range expiredFiles {
-		fullPath := filepath.Clean(c.saveDirectory + string(filepath.Separator) + fileInfo.Name())
-		err := os.Remove(fullPath)
-		if err != nil {
-			log.Errorln(err)
+// fileSegments seals and moves the segments finished before settled to their daily directory
+func (c *Continuous) fileSegments(settled time.Time) {
+	for _, fileInfo := range finishedFiles(c.saveDirectory, settled) {
+		// a segment still being written is filed on a later pass
+		if sealFiles(c.key, filepath.Join(c.saveDirectory, fileInfo.Name())) {
+			fileByDate(c.saveDirectory, fileInfo.ModTime(), fileInfo.Name())
 		}
 	}
 }
 
-func (c *Continuous) deleteWhenFull() {
-	dirSize, _ := dir.Size(c.saveDirectory, dir.RegexBeginsWith(c.name))
-	if int(math.Ceil(dir.BytesToGigaBytes(dirSize))) > c.ContinuousConf.DeleteAfterGB {
-		files, _ := dir.List(c.saveDirectory, dir.RegexBeginsWith(c.name))
-		sort.Sort(dir.AscendingTime(files))
-		for _, fileInfo := range files {
-			if int(math.Ceil(dir.BytesToGigaBytes(dirSize))) <= c.ContinuousConf.DeleteAfterGB {
-				break
-			}
-			dirSize -= uint64(fileInfo.Size())
-			fullPath := filepath.Clean(c.saveDirectory + string(filepath.Separator) + fileInfo.Name())
-			err := os.Remove(fullPath)
-			if err != nil {
-				log.Errorln(err)
-			}
-		}
-	}
+func (c *Continuous) process(img videosource.ProcessedImage) {
+	c.writer.Trigger()
+	c.writer.Send(img)
+	c.lastSent = time.Now()
 }
 
 // Send a processed image to buffer
 func (c *Continuous) Send(img *videosource.ProcessedImage) {
 	pubsubmutex.Publish(&c.pubsub,
 		pubsubmutex.Message[*videosource.ProcessedImage]{Topic: topicContinuousImages, Data: img})
+	c.tracker.Offer()
+}
+
+// Stats returns the current process stats
+func (c *Continuous) Stats() ProcessStats {
+	return c.tracker.Stats()
 }
 
 // Close notified by caller that input stream is done/closed
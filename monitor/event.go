@@ -0,0 +1,344 @@
+package monitor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	pubsubmutex "github.com/jonoton/go-pubsubmutex"
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/seal"
+)
+
+const topicEventImages = "topic-event-images"
+
+// Event Constants
+const (
+	EventOpen   = "open"
+	EventUpdate = "update"
+	EventClose  = "close"
+
+	EventLabelFace = "face"
+
+	defaultEventDebounceSeconds = 5
+)
+
+// EventLabel contains a detected label and the max confidence seen during the event
+type EventLabel struct {
+	Label         string
+	MaxConfidence int
+}
+
+// Event is a detection on a monitor from the first to the last detected frame
+type Event struct {
+	ID            string
+	Monitor       string
+	State         string
+	StartTime     time.Time
+	EndTime       time.Time
+	Labels        []EventLabel
+	Zones         []string // empty until zones can be configured
+	SnapshotPath  string
+	RecordingPath string
+}
+
+// HasLabel returns true if the event contains the label
+func (e Event) HasLabel(label string) bool {
+	for _, cur := range e.Labels {
+		if strings.EqualFold(cur.Label, label) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Event) copy() Event {
+	r := e
+	r.Labels = append([]EventLabel{}, e.Labels...)
+	r.Zones = append([]string{}, e.Zones...)
+	return r
+}
+
+// Events groups detections into events and publishes the open, update, and close lifecycle
+type Events struct {
+	name           string
+	saveDirectory  string
+	EventConf      *EventConfig
+	debounce       time.Duration
+	publish        func(Event)
+	recordingAt    func(time.Time) string
+	pubsub         pubsubmutex.PubSub
+	bufferSize     int
+	current        *Event
+	best           *videosource.ProcessedImage
+	bestConfidence int
+	lastSeen       time.Time
+	done           chan bool
+	cancel         chan bool
+	cancelOnce     sync.Once
+	secondTick     *time.Ticker
+	tracker        *ProcessTracker
+	overlay        *Overlay
+	key            *seal.Key
+}
+
+// NewEvents creates a new Events which calls publish on each event change.
+// Snapshots are only saved when saveDirectory is set and eventConf enables them.
+func NewEvents(name string, saveDirectory string, eventConf *EventConfig, outFps int, publish func(Event)) *Events {
+	debounceSec := defaultEventDebounceSeconds
+	eventDir := ""
+	if eventConf != nil {
+		if eventConf.DebounceSeconds > 0 {
+			debounceSec = eventConf.DebounceSeconds
+		}
+		if saveDirectory != "" && eventConf.SaveSnapshot {
+			eventDir = MonitorDirectory(saveDirectory, CategoryEvents, name)
+			os.MkdirAll(eventDir, os.ModePerm)
+		}
+	}
+	bufferSize := outFps
+	if bufferSize < 10 {
+		bufferSize = 10
+	}
+	e := &Events{
+		name:           name,
+		saveDirectory:  eventDir,
+		EventConf:      eventConf,
+		debounce:       time.Duration(debounceSec) * time.Second,
+		publish:        publish,
+		recordingAt:    nil,
+		pubsub:         *pubsubmutex.NewPubSub(),
+		bufferSize:     bufferSize,
+		current:        nil,
+		best:           nil,
+		bestConfidence: 0,
+		done:           make(chan bool),
+		cancel:         make(chan bool),
+		secondTick:     time.NewTicker(time.Second),
+		tracker:        NewProcessTracker("event"),
+		overlay:        NewOverlay(name, nil),
+		key:            nil,
+	}
+	pubsubmutex.RegisterTopic[*videosource.ProcessedImage](&e.pubsub, topicEventImages)
+
+	return e
+}
+
+// SetRecordingLookup sets the function returning the recording path for a time
+func (e *Events) SetRecordingLookup(recordingAt func(time.Time) string) {
+	e.recordingAt = recordingAt
+}
+
+// SetOverlay sets the overlay drawn on snapshots
+func (e *Events) SetOverlay(overlay *Overlay) {
+	e.overlay = overlay
+}
+
+// SetEncryption sets the key that seals snapshots
+func (e *Events) SetEncryption(key *seal.Key) {
+	e.key = key
+}
+
+// Wait until done
+func (e *Events) Wait() {
+	<-e.done
+}
+
+// Start the processes
+func (e *Events) Start() {
+	go func() {
+		imageSub, _ := pubsubmutex.Subscribe[*videosource.ProcessedImage](&e.pubsub,
+			topicEventImages, e.pubsub.GetUniqueSubscriberID(), e.bufferSize)
+		e.tracker.SetQueueDepthFunc(func() int { return len(imageSub.Ch) })
+	Loop:
+		for {
+			select {
+			case <-e.secondTick.C:
+				if e.current != nil && time.Since(e.lastSeen) >= e.debounce {
+					e.closeEvent()
+				}
+			case msg, ok := <-imageSub.Ch:
+				if !ok {
+					if msg.Data != nil {
+						img := msg.Data
+						img.Cleanup()
+					}
+					break Loop
+				}
+				if msg.Data == nil {
+					continue
+				}
+				img := msg.Data
+				e.tracker.In(len(imageSub.Ch))
+				start := time.Now()
+				e.process(img)
+				e.tracker.Out(start)
+			case <-e.cancel:
+				break Loop
+			}
+		}
+		imageSub.Unsubscribe()
+		if e.current != nil {
+			e.closeEvent()
+		}
+		e.secondTick.Stop()
+		e.pubsub.Close()
+		close(e.done)
+	}()
+}
+
+// Send a processed image to the event grouping
+func (e *Events) Send(img *videosource.ProcessedImage) {
+	if !img.HasObject() && !img.HasFace() {
+		img.Cleanup()
+		return
+	}
+	pubsubmutex.Publish(&e.pubsub,
+		pubsubmutex.Message[*videosource.ProcessedImage]{Topic: topicEventImages, Data: img})
+	e.tracker.Offer()
+}
+
+// Stats returns the current process stats
+func (e *Events) Stats() ProcessStats {
+	return e.tracker.Stats()
+}
+
+// Close notified by caller that input stream is done/closed
+func (e *Events) Close() {
+	e.cancelOnce.Do(func() {
+		close(e.cancel)
+	})
+}
+
+func (e *Events) process(img *videosource.ProcessedImage) {
+	created := img.Original.CreatedTime()
+	labels := imageEventLabels(img)
+	state := EventUpdate
+	if e.current == nil {
+		state = EventOpen
+		e.current = &Event{
+			ID:        newEventID(created),
+			Monitor:   e.name,
+			StartTime: created,
+			Labels:    make([]EventLabel, 0),
+			Zones:     make([]string, 0),
+		}
+	}
+	var changed bool
+	e.current.Labels, changed = mergeEventLabels(e.current.Labels, labels)
+	e.current.EndTime = created
+	e.lastSeen = time.Now()
+
+	maxConfidence := 0
+	for _, cur := range labels {
+		if cur.MaxConfidence > maxConfidence {
+			maxConfidence = cur.MaxConfidence
+		}
+	}
+	if e.best == nil || maxConfidence > e.bestConfidence {
+		if e.best != nil {
+			e.best.Cleanup()
+		}
+		e.best = img
+		e.bestConfidence = maxConfidence
+	} else {
+		img.Cleanup()
+	}
+
+	if state == EventOpen || changed {
+		e.current.State = state
+		e.publish(e.current.copy())
+	}
+}
+
+func (e *Events) closeEvent() {
+	if e.best != nil {
+		e.current.SnapshotPath = e.saveSnapshot(e.best, e.bestConfidence)
+		e.best.Cleanup()
+		e.best = nil
+		e.bestConfidence = 0
+	}
+	if e.recordingAt != nil {
+		e.current.RecordingPath = e.recordingAt(e.current.StartTime)
+	}
+	e.current.State = EventClose
+	log.Infof("Event %s closed for %s with %d labels", e.current.ID, e.name, len(e.current.Labels))
+	e.publish(e.current.copy())
+	e.current = nil
+}
+
+func (e *Events) saveSnapshot(img *videosource.ProcessedImage, confidence int) string {
+	if e.saveDirectory == "" {
+		return ""
+	}
+	quality := e.EventConf.SnapshotQuality
+	if quality <= 0 {
+		quality = 100
+	}
+	title := "Event"
+	percentage := fmt.Sprintf("%d", confidence)
+	created := img.Original.CreatedTime()
+	highlighted := e.overlay.Alert(img)
+	saveDir := makeDateDirectory(e.saveDirectory, created)
+	p := videosource.SavePreview(*highlighted, created, saveDir, e.name, title, percentage)
+	s := videosource.SaveImage(*highlighted, created, saveDir, quality, e.name, title, percentage)
+	highlighted.Cleanup()
+	sealFiles(e.key, s, p)
+	return s
+}
+
+// EventIDPrefix returns the id prefix for events starting at the time.
+// Ids sort by start time so a prefix can be used to seek a time range.
+func EventIDPrefix(start time.Time) string {
+	return fmt.Sprintf("%016x", start.UnixNano())
+}
+
+func newEventID(start time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return EventIDPrefix(start) + hex.EncodeToString(suffix)
+}
+
+func imageEventLabels(img *videosource.ProcessedImage) []EventLabel {
+	result := make([]EventLabel, 0)
+	for _, cur := range img.Objects {
+		result = append(result, EventLabel{Label: cur.Description, MaxConfidence: cur.Percentage})
+	}
+	for _, cur := range img.Faces {
+		result = append(result, EventLabel{Label: EventLabelFace, MaxConfidence: cur.Percentage})
+	}
+	return result
+}
+
+// mergeEventLabels adds new labels and raises max confidences, returning true when changed
+func mergeEventLabels(labels []EventLabel, add []EventLabel) (result []EventLabel, changed bool) {
+	result = labels
+	for _, cur := range add {
+		found := false
+		for i := range result {
+			if strings.EqualFold(result[i].Label, cur.Label) {
+				found = true
+				if cur.MaxConfidence > result[i].MaxConfidence {
+					result[i].MaxConfidence = cur.MaxConfidence
+					changed = true
+				}
+				break
+			}
+		}
+		if !found {
+			result = append(result, cur)
+			changed = true
+		}
+	}
+	if changed {
+		sort.SliceStable(result, func(i, j int) bool { return result[i].MaxConfidence > result[j].MaxConfidence })
+	}
+	return
+}
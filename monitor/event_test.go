@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeEventLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   []EventLabel
+		add      []EventLabel
+		expected []EventLabel
+		changed  bool
+	}{
+		{
+			name:     "new label",
+			labels:   []EventLabel{{Label: "person", MaxConfidence: 60}},
+			add:      []EventLabel{{Label: "car", MaxConfidence: 80}},
+			expected: []EventLabel{{Label: "car", MaxConfidence: 80}, {Label: "person", MaxConfidence: 60}},
+			changed:  true,
+		},
+		{
+			name:     "higher confidence",
+			labels:   []EventLabel{{Label: "person", MaxConfidence: 60}},
+			add:      []EventLabel{{Label: "Person", MaxConfidence: 70}},
+			expected: []EventLabel{{Label: "person", MaxConfidence: 70}},
+			changed:  true,
+		},
+		{
+			name:     "lower confidence",
+			labels:   []EventLabel{{Label: "person", MaxConfidence: 60}},
+			add:      []EventLabel{{Label: "person", MaxConfidence: 50}},
+			expected: []EventLabel{{Label: "person", MaxConfidence: 60}},
+			changed:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, changed := mergeEventLabels(tt.labels, tt.add)
+			if changed != tt.changed {
+				t.Errorf("changed = %v, expected %v", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("mergeEventLabels() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"sync"
+
+	"github.com/jonoton/go-videosource"
+)
+
+// LatestFrame holds the most recent processed image of a monitor
+type LatestFrame struct {
+	mu  sync.Mutex
+	img *videosource.ProcessedImage
+}
+
+// NewLatestFrame creates a new LatestFrame
+func NewLatestFrame() *LatestFrame {
+	l := &LatestFrame{
+		img: nil,
+	}
+	return l
+}
+
+// Set replaces the held image and takes ownership of img
+func (l *LatestFrame) Set(img *videosource.ProcessedImage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.img != nil {
+		l.img.Cleanup()
+	}
+	l.img = img
+}
+
+// Get returns a reference to the held image or nil when empty. The caller must cleanup the result.
+func (l *LatestFrame) Get() *videosource.ProcessedImage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.img == nil {
+		return nil
+	}
+	return l.img.Ref()
+}
+
+// Clear releases the held image
+func (l *LatestFrame) Clear() {
+	l.Set(nil)
+}
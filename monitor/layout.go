@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/scout/seal"
+)
+
+// DateLayout names the daily directories of a monitor
+const DateLayout = "2006-01-02"
+
+// fileSettle is how long a video is unchanged while its writer gets frames before it is finished
+const fileSettle = 5 * time.Second
+
+// MonitorDirectory returns the directory of the monitor's files in a storage category
+func MonitorDirectory(saveDirectory string, category string, name string) string {
+	return filepath.Join(filepath.Clean(saveDirectory), category, name) + string(filepath.Separator)
+}
+
+// DateDirectory returns the daily directory of the time in the monitor directory
+func DateDirectory(monitorDir string, t time.Time) string {
+	return filepath.Join(monitorDir, t.Format(DateLayout)) + string(filepath.Separator)
+}
+
+// makeDateDirectory creates the daily directory of the time
+func makeDateDirectory(monitorDir string, t time.Time) string {
+	dateDir := DateDirectory(monitorDir, t)
+	os.MkdirAll(dateDir, os.ModePerm)
+	return dateDir
+}
+
+// settledBefore returns the time files must be unchanged since to be finished, given the last frame sent to the writer.
+// The writer keeps a file open while no frames arrive, such as during a camera stall, so files only settle while frames arrive.
+// Frames wait up to bufferSeconds in the writer before they are written.
+func settledBefore(lastSent time.Time, bufferSeconds int) time.Time {
+	return lastSent.Add(-fileSettle - time.Duration(bufferSeconds)*time.Second)
+}
+
+// finishedFiles returns the files written directly to the monitor directory and unchanged since settled
+func finishedFiles(monitorDir string, settled time.Time) []os.FileInfo {
+	result := make([]os.FileInfo, 0)
+	entries, err := os.ReadDir(monitorDir)
+	if err != nil {
+		return result
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		// left by sealing that stopped part way
+		if strings.HasSuffix(entry.Name(), seal.TmpExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(settled) {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// sealFiles seals the saved files when there is a key and returns false when a file is still being written
+func sealFiles(key *seal.Key, paths ...string) bool {
+	if key == nil {
+		return true
+	}
+	result := true
+	for _, cur := range paths {
+		if cur == "" {
+			continue
+		}
+		err := key.SealFile(cur)
+		if errors.Is(err, seal.ErrChanged) {
+			result = false
+		} else if err != nil {
+			log.Warnln("Could not encrypt", cur, err)
+		}
+	}
+	return result
+}
+
+// fileByDate moves the files from the monitor directory to the daily directory of the time
+func fileByDate(monitorDir string, t time.Time, names ...string) {
+	dateDir := makeDateDirectory(monitorDir, t)
+	for _, name := range names {
+		err := os.Rename(filepath.Join(monitorDir, name), filepath.Join(dateDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			log.Warnln("Could not move to daily directory", name, err)
+		}
+	}
+}
@@ -11,15 +11,56 @@ import (
 	"github.com/jonoton/go-delaybuffer"
 	"github.com/jonoton/go-pubsubmutex"
 	"github.com/jonoton/go-videosource"
-	"github.com/jonoton/scout/face"
-	"github.com/jonoton/scout/motion"
-	"github.com/jonoton/scout/tensor"
+	"github.com/jonoton/scout/seal"
 	log "github.com/sirupsen/logrus"
 )
 
 const topicMonitorImages = "topic-monitor-images"
 const topicGetMonitorFrameStats = "topic-get-monitor-frame-stats"
 const topicCurrentMonitorFrameStats = "topic-current-monitor-frame-stats"
+const topicMonitorEvents = "topic-monitor-events"
+
+// ArmState contains whether detection and alerting are armed
+type ArmState struct {
+	Armed                  bool `yaml:"armed"`
+	ContinuousWhenDisarmed bool `yaml:"continuousWhenDisarmed,omitempty"`
+}
+
+// Notify Channels
+const (
+	NotifyEmail = "email"
+	NotifyText  = "text"
+)
+
+// Policy contains what an armed monitor detects, alerts, and records in the active mode
+type Policy struct {
+	Detect bool     `yaml:"detect"`
+	Alert  bool     `yaml:"alert"`
+	Record bool     `yaml:"record"`
+	Notify []string `yaml:"notify,omitempty"`
+}
+
+// DefaultPolicy returns the policy used when no mode is active
+func DefaultPolicy() Policy {
+	return Policy{
+		Detect: true,
+		Alert:  true,
+		Record: true,
+	}
+}
+
+// Notifies returns true if the notify channel is allowed, defaulting to all channels
+func (p Policy) Notifies(channel string) bool {
+	if len(p.Notify) == 0 {
+		return true
+	}
+	for _, cur := range p.Notify {
+		if cur == channel {
+			return true
+		}
+	}
+	return false
+}
 
 // Monitor contains the video source
 type Monitor struct {
@@ -29,8 +70,13 @@ type Monitor struct {
 	delayBufferDuration time.Duration
 	ConfigPaths         []string
 	reader              *videosource.VideoReader
+	detectReader        *videosource.VideoReader
+	shared              *SharedReader
+	view                *View
+	overlay             *Overlay
 	record              *Record
 	continuous          *Continuous
+	events              *Events
 	notifier            *notify.Notify
 	notifyRxConf        *notify.RxConfig
 	staleTimeout        int
@@ -38,10 +84,15 @@ type Monitor struct {
 	StaleMaxRetry       int
 	IsStale             bool
 	frameStatsCombo     videosource.FrameStatsCombo
-	motion              *motion.Motion
-	tensor              *tensor.Tensor
-	face                *face.Face
+	stages              []Stage
+	liveTracker         *ProcessTracker
+	latest              *LatestFrame
+	adaptive            *adaptiveController
+	armState            ArmState
+	policy              Policy
+	stateMu             sync.Mutex
 	alert               *Alert
+	key                 *seal.Key
 	pubsub              pubsubmutex.PubSub
 	done                chan bool
 }
@@ -57,6 +108,10 @@ func NewMonitor(name string, reader *videosource.VideoReader) *Monitor {
 		bufferSec:           0,
 		delayBufferDuration: 0,
 		reader:              reader,
+		detectReader:        nil,
+		shared:              nil,
+		view:                nil,
+		overlay:             NewOverlay(name, nil),
 		record:              nil,
 		continuous:          nil,
 		notifier:            nil,
@@ -66,20 +121,47 @@ func NewMonitor(name string, reader *videosource.VideoReader) *Monitor {
 		StaleMaxRetry:       10,
 		IsStale:             false,
 		frameStatsCombo:     videosource.FrameStatsCombo{},
-		motion:              motion.NewMotion(name),
-		tensor:              tensor.NewTensor(name),
-		face:                face.NewFace(name),
-		pubsub:              *pubsubmutex.NewPubSub(),
-		alert:               nil,
-		done:                make(chan bool),
+		stages: []Stage{
+			NewStage(StageMotion, name),
+			NewStage(StageTensor, name),
+			NewStage(StageFace, name),
+		},
+		liveTracker: NewProcessTracker("live"),
+		latest:      NewLatestFrame(),
+		adaptive:    nil,
+		armState:    ArmState{Armed: true},
+		policy:      DefaultPolicy(),
+		pubsub:      *pubsubmutex.NewPubSub(),
+		alert:       nil,
+		key:         nil,
+		done:        make(chan bool),
 	}
 	pubsubmutex.RegisterTopic[*videosource.ProcessedImage](&m.pubsub, topicMonitorImages)
 	pubsubmutex.RegisterTopic[any](&m.pubsub, topicGetMonitorFrameStats)
 	pubsubmutex.RegisterTopic[*videosource.FrameStatsCombo](&m.pubsub, topicCurrentMonitorFrameStats)
+	pubsubmutex.RegisterTopic[Event](&m.pubsub, topicMonitorEvents)
+	m.events = NewEvents(name, "", nil, reader.MaxOutputFps, m.publishEvent)
 
 	return m
 }
 
+// SetDetectReader sets a separate detection stream reader.
+// The pipeline runs on the detection stream and every main stream frame is output with the results captured nearest to it rescaled onto it.
+func (m *Monitor) SetDetectReader(reader *videosource.VideoReader) {
+	m.detectReader = reader
+}
+
+// GetSharedReader returns the shared source
+func (m *Monitor) GetSharedReader() *SharedReader {
+	return m.shared
+}
+
+// SetSharedReader sets the shared source and the view applied to its frames
+func (m *Monitor) SetSharedReader(shared *SharedReader, view *View) {
+	m.shared = shared
+	m.view = view
+}
+
 func (m *Monitor) SetBufferSeconds(sec int) {
 	if sec > 0 {
 		m.bufferSize = m.reader.MaxOutputFps * sec
@@ -119,63 +201,228 @@ func (m *Monitor) SetContinuous(saveDirectory string, continuousConf *Continuous
 	m.continuous = NewContinuous(m.Name, saveDirectory, continuousConf, m.reader.MaxOutputFps)
 }
 
+// SetEncryption sets the key that seals saved videos and images, or nil to save them as is
+func (m *Monitor) SetEncryption(key *seal.Key) {
+	m.key = key
+}
+
+// SetArmState arms or disarms detection and alerting while live view keeps running
+func (m *Monitor) SetArmState(armState ArmState) {
+	m.stateMu.Lock()
+	m.armState = armState
+	m.stateMu.Unlock()
+	m.applyState()
+}
+
+// GetArmState returns the current arm state
+func (m *Monitor) GetArmState() ArmState {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.armState
+}
+
+// SetPolicy sets what the monitor detects, alerts, and records while armed
+func (m *Monitor) SetPolicy(policy Policy) {
+	m.stateMu.Lock()
+	m.policy = policy
+	m.stateMu.Unlock()
+	m.applyState()
+}
+
+// GetPolicy returns the current policy
+func (m *Monitor) GetPolicy() Policy {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.policy
+}
+
+func (m *Monitor) getState() (ArmState, Policy) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.armState, m.policy
+}
+
+func (m *Monitor) applyState() {
+	armState, policy := m.getState()
+	detect := armState.Armed && policy.Detect
+	for _, stage := range m.stages {
+		if cur, ok := stage.(armableStage); ok {
+			cur.setArmed(detect)
+		}
+	}
+	if m.alert != nil {
+		m.alert.SetNotify(policy.Notifies(NotifyEmail), policy.Notifies(NotifyText))
+	}
+}
+
+// SetOverlay sets the overlay layers for each output
+func (m *Monitor) SetOverlay(overlayConf *OverlayConfig) {
+	m.overlay = NewOverlay(m.Name, overlayConf)
+}
+
+// GetOverlay returns the overlay for each output
+func (m *Monitor) GetOverlay() *Overlay {
+	return m.overlay
+}
+
+// SetEvents sets the event grouping
+func (m *Monitor) SetEvents(saveDirectory string, eventConf *EventConfig) {
+	m.events = NewEvents(m.Name, saveDirectory, eventConf, m.reader.MaxOutputFps, m.publishEvent)
+}
+
 // SetAlert sets the alert notification
 func (m *Monitor) SetAlert(notifier *notify.Notify, notifyRxConf *notify.RxConfig, saveDirectory string, alertConf *AlertConfig) {
 	m.alert = NewAlert(m.Name, notifier, notifyRxConf, saveDirectory, alertConf)
+	m.applyState()
+}
+
+// SetPipeline sets the ordered processing stages
+func (m *Monitor) SetPipeline(stages []Stage) {
+	m.stages = stages
+	m.applyState()
+}
+
+// SetAdaptive enables adaptive analysis between minFps and maxFps when minFps is set.
+// The maxFps defaults to the monitor max output fps.
+func (m *Monitor) SetAdaptive(minFps int, maxFps int) {
+	if minFps <= 0 {
+		m.adaptive = nil
+		return
+	}
+	if maxFps <= 0 {
+		maxFps = m.reader.MaxOutputFps
+	}
+	if maxFps <= 0 {
+		maxFps = adaptiveDefaultMaxFps
+	}
+	m.adaptive = newAdaptiveController(m.Name, minFps, maxFps)
 }
 
-// SetMotion sets the Motion Config
-func (m *Monitor) SetMotion(config *motion.Config) {
-	m.motion.SetConfig(config)
+// GetPipelineStats returns the stats for each pipeline stage in order and each sink
+func (m *Monitor) GetPipelineStats() *PipelineStats {
+	result := &PipelineStats{
+		Stages: make([]ProcessStats, 0),
+		Sinks:  make([]ProcessStats, 0),
+	}
+	for _, stage := range m.stages {
+		result.Stages = append(result.Stages, stage.Stats())
+	}
+	if m.alert != nil {
+		result.Sinks = append(result.Sinks, m.alert.Stats())
+	}
+	if m.record != nil {
+		result.Sinks = append(result.Sinks, m.record.Stats())
+	}
+	if m.continuous != nil {
+		result.Sinks = append(result.Sinks, m.continuous.Stats())
+	}
+	result.Sinks = append(result.Sinks, m.events.Stats())
+	result.Sinks = append(result.Sinks, m.liveTracker.Stats())
+	if m.adaptive != nil {
+		adaptiveStats := m.adaptive.Stats()
+		result.Adaptive = &adaptiveStats
+	}
+	return result
 }
 
-// SetTensor sets the Tensor Config
-func (m *Monitor) SetTensor(config *tensor.Config) {
-	m.tensor.SetConfig(config)
+// GetLatestFrame returns the holder of the most recent processed image
+func (m *Monitor) GetLatestFrame() *LatestFrame {
+	return m.latest
 }
 
-// SetFace sets the Face Config
-func (m *Monitor) SetFace(config *face.Config) {
-	m.face.SetConfig(config)
+// GetLiveTracker returns the tracker shared by live subscribers
+func (m *Monitor) GetLiveTracker() *ProcessTracker {
+	return m.liveTracker
 }
 
 // Start will run the processes
 func (m *Monitor) Start() {
 	go func() {
-		motionInput := make(chan videosource.Image, m.bufferSize)
-		motionOutput := m.motion.Run(motionInput)
+		wg := &sync.WaitGroup{}
 
-		tensorInput := make(chan videosource.ProcessedImage, m.bufferSize)
-		tensorOutput := m.tensor.Run(tensorInput)
+		if m.adaptive != nil {
+			// each stage can hold a queue of frames and one in process
+			m.adaptive.gate.setMaxFrames((m.bufferSize + 1) * (len(m.stages) + 1))
+			for _, stage := range m.stages {
+				if cur, ok := stage.(sheddableStage); ok {
+					cur.setAnalysisGate(m.adaptive.gate)
+				}
+			}
+		}
 
-		faceInput := make(chan videosource.ProcessedImage, m.bufferSize)
-		faceOutput := m.face.Run(faceInput)
+		pipelineInput := make(chan videosource.ProcessedImage, m.bufferSize)
+		var stageOutput <-chan videosource.ProcessedImage = pipelineInput
+		for index, stage := range m.stages {
+			if index == 0 {
+				stageOutput = stage.Run(stageOutput)
+				continue
+			}
+			stageInput := make(chan videosource.ProcessedImage, m.bufferSize)
+			wg.Add(1)
+			go connectStages(stageOutput, stageInput, wg)
+			stageOutput = stage.Run(stageInput)
+		}
 
-		wg := &sync.WaitGroup{}
-		wg.Add(4)
+		pipelineOutputPtrChan := make(chan *videosource.ProcessedImage, m.bufferSize)
 
-		faceOutputPtrChan := make(chan *videosource.ProcessedImage, m.bufferSize)
+		var outputs *frameQueue
+		for _, stage := range m.stages {
+			if cur, ok := stage.(outputStage); ok && cur.keepsOutput() {
+				if outputs != nil {
+					log.Warnf("Only the first analysis only stage keeps the output for %s", m.Name)
+					continue
+				}
+				outputs = newFrameQueue()
+				cur.setOutputQueue(outputs)
+			}
+		}
+		if outputs != nil {
+			restoredOutput := make(chan videosource.ProcessedImage, m.bufferSize)
+			wg.Add(1)
+			go restoreStages(stageOutput, restoredOutput, outputs, wg)
+			stageOutput = restoredOutput
+		}
+		var detectedOutput <-chan videosource.ProcessedImage
+		var alignedOutput chan videosource.ProcessedImage
+		if m.detectReader != nil {
+			detectedOutput = stageOutput
+			alignedOutput = make(chan videosource.ProcessedImage, m.bufferSize)
+			stageOutput = alignedOutput
+		}
 
-		go convertToProcessImagePtrChan(faceOutput, faceOutputPtrChan, wg)
-		go motionToTensor(motionOutput, tensorInput, wg)
-		go tensorToFace(tensorOutput, faceInput, wg)
+		wg.Add(2)
+		go convertToProcessImagePtrChan(stageOutput, pipelineOutputPtrChan, wg)
 		var delayBuffer *delaybuffer.Buffer[*videosource.ProcessedImage]
 		if m.delayBufferDuration > 0 {
 			tickMs := 5
 			if m.reader.MaxOutputFps > 0 {
 				tickMs = (1000 / m.reader.MaxOutputFps) / 2
 			}
-			delayBuffer = delaybuffer.NewBuffer(faceOutputPtrChan, m.delayBufferDuration, time.Duration(tickMs)*time.Millisecond)
+			delayBuffer = delaybuffer.NewBuffer(pipelineOutputPtrChan, m.delayBufferDuration, time.Duration(tickMs)*time.Millisecond)
 			go m.processResults(delayBuffer.Out, wg)
 		} else {
-			go m.processResults(faceOutputPtrChan, wg)
+			go m.processResults(pipelineOutputPtrChan, wg)
 		}
 
-		readerOutput := m.reader.Start()
-		readerToMotion(readerOutput, motionInput)
+		if m.detectReader != nil {
+			wg.Add(1)
+			go alignStreams(m.startReader(), detectedOutput, alignedOutput, wg)
+			detectOutput := m.detectReader.Start()
+			detectToPipeline(detectOutput, pipelineInput)
+			m.detectReader.Wait()
+			m.stopReader()
+		} else {
+			readerOutput := m.startReader()
+			readerToPipeline(readerOutput, pipelineInput, m.view)
+		}
 
-		m.reader.Wait()
+		if m.shared == nil {
+			m.reader.Wait()
+		}
 		wg.Wait()
+		if outputs != nil {
+			outputs.cleanup()
+		}
 		if delayBuffer != nil {
 			delayBuffer.Close()
 		}
@@ -186,22 +433,84 @@ func (m *Monitor) Start() {
 	}()
 }
 
-func readerToMotion(inChan <-chan videosource.Image, outChan chan videosource.Image) {
+func (m *Monitor) startReader() <-chan videosource.Image {
+	if m.shared != nil {
+		return m.shared.Attach(m.Name, m.bufferSize)
+	}
+	return m.reader.Start()
+}
+
+func (m *Monitor) stopReader() {
+	if m.shared != nil {
+		m.shared.Detach(m.Name)
+		return
+	}
+	m.reader.Stop()
+}
+
+func readerToPipeline(inChan <-chan videosource.Image, outChan chan videosource.ProcessedImage, view *View) {
 	for img := range inChan {
-		outChan <- img
+		outChan <- *videosource.NewProcessedImage(view.Apply(img))
 	}
 	close(outChan)
 }
 
-func motionToTensor(inChan <-chan videosource.ProcessedImage, outChan chan videosource.ProcessedImage, wg *sync.WaitGroup) {
+func detectToPipeline(inChan <-chan videosource.Image, outChan chan videosource.ProcessedImage) {
 	for img := range inChan {
-		outChan <- img
+		outChan <- *videosource.NewProcessedImage(img)
+	}
+	close(outChan)
+}
+
+// alignStreams outputs every main stream frame with the detection results captured nearest to it
+func alignStreams(mainChan <-chan videosource.Image, detectedChan <-chan videosource.ProcessedImage,
+	outChan chan videosource.ProcessedImage, wg *sync.WaitGroup) {
+	aligner := newStreamAligner()
+	waitTick := time.NewTicker(alignMaxWait / 4)
+	defer waitTick.Stop()
+	for mainChan != nil {
+		select {
+		case img, ok := <-mainChan:
+			if !ok {
+				mainChan = nil
+				continue
+			}
+			aligner.addMain(img, time.Now())
+		case detected, ok := <-detectedChan:
+			if !ok {
+				detectedChan = nil
+				continue
+			}
+			aligner.update(detected)
+		case <-waitTick.C:
+		}
+		for _, cur := range aligner.ready(time.Now(), false) {
+			outChan <- cur
+		}
+	}
+	for _, cur := range aligner.ready(time.Now(), true) {
+		outChan <- cur
+	}
+	aligner.cleanup()
+	close(outChan)
+	// let the detection pipeline finish
+	if detectedChan != nil {
+		for detected := range detectedChan {
+			detected.Original.Cleanup()
+		}
+	}
+	wg.Done()
+}
+
+func restoreStages(inChan <-chan videosource.ProcessedImage, outChan chan videosource.ProcessedImage, outputs *frameQueue, wg *sync.WaitGroup) {
+	for img := range inChan {
+		outChan <- outputs.restore(img)
 	}
 	close(outChan)
 	wg.Done()
 }
 
-func tensorToFace(inChan <-chan videosource.ProcessedImage, outChan chan videosource.ProcessedImage, wg *sync.WaitGroup) {
+func connectStages(inChan <-chan videosource.ProcessedImage, outChan chan videosource.ProcessedImage, wg *sync.WaitGroup) {
 	for img := range inChan {
 		outChan <- img
 	}
@@ -219,14 +528,24 @@ func convertToProcessImagePtrChan(inChan <-chan videosource.ProcessedImage, outC
 
 func (m *Monitor) processResults(inChan <-chan *videosource.ProcessedImage, wg *sync.WaitGroup) {
 	if m.continuous != nil {
+		m.continuous.SetEncryption(m.key)
 		m.continuous.Start()
 	}
 	if m.record != nil {
+		m.record.SetEncryption(m.key)
 		m.record.Start()
 	}
 	if m.alert != nil {
+		m.alert.SetOverlay(m.overlay)
+		m.alert.SetEncryption(m.key)
 		m.alert.Start()
 	}
+	if m.record != nil {
+		m.events.SetRecordingLookup(m.record.RecordingAt)
+	}
+	m.events.SetOverlay(m.overlay)
+	m.events.SetEncryption(m.key)
+	m.events.Start()
 	getMonFrameStatsSub, _ := pubsubmutex.Subscribe[any](&m.pubsub, topicGetMonitorFrameStats, m.pubsub.GetUniqueSubscriberID(), 10)
 	sourceStatsSub := m.reader.GetSourceStatsSub()
 	outputStatsSub := m.reader.GetOutputStatsSub()
@@ -263,18 +582,32 @@ ProcessLoop:
 			if cur == nil {
 				continue
 			}
-			if m.alert != nil {
-				m.alert.Push(cur.Ref())
-			}
-			if m.record != nil {
-				m.record.Send(cur.Ref())
+			armState, policy := m.getState()
+			sendRecord := m.record != nil && armState.Armed && policy.Detect && policy.Record
+			sendContinuous := m.continuous != nil && (armState.Armed || armState.ContinuousWhenDisarmed)
+			if armState.Armed && policy.Detect {
+				if m.alert != nil && policy.Alert {
+					m.alert.Push(cur.Ref())
+				}
+				m.events.Send(cur.Ref())
 			}
-			if m.continuous != nil {
-				m.continuous.Send(cur.Ref())
+			if sendRecord || sendContinuous {
+				recorded := m.overlay.Record(cur)
+				if sendRecord {
+					m.record.Send(recorded.Ref())
+				}
+				if sendContinuous {
+					m.continuous.Send(recorded.Ref())
+				}
+				recorded.Cleanup()
 			}
+			m.latest.Set(cur.Ref())
 			pubsubmutex.Publish(&m.pubsub, pubsubmutex.Message[*videosource.ProcessedImage]{Topic: topicMonitorImages, Data: cur.Ref()})
 			cur.Cleanup()
 		case <-staleTicker.C:
+			if m.adaptive != nil {
+				m.adaptive.evaluate(m.sheddableStageStats(), m.bufferSize)
+			}
 			if m.reader.GetSourceType() == videosource.IPCamSourceType && m.reader.GetConnectionStatus() == videosource.Connecting {
 				// Ignoring stale check since still connecting monitor
 				continue
@@ -309,12 +642,28 @@ ProcessLoop:
 		m.continuous.Close()
 		m.continuous.Wait()
 	}
+	m.events.Close()
+	m.events.Wait()
+	m.latest.Clear()
 	wg.Done()
 }
 
+func (m *Monitor) sheddableStageStats() []ProcessStats {
+	result := make([]ProcessStats, 0)
+	for _, stage := range m.stages {
+		if cur, ok := stage.(sheddableStage); ok && cur.isSheddable() {
+			result = append(result, stage.Stats())
+		}
+	}
+	return result
+}
+
 // Stop will stop the processes
 func (m *Monitor) Stop() {
-	m.reader.Stop()
+	m.stopReader()
+	if m.detectReader != nil {
+		m.detectReader.Stop()
+	}
 }
 
 // Wait until done
@@ -331,6 +680,19 @@ func (m *Monitor) Subscribe(bufferSize int) (result *pubsubmutex.Subscriber[*vid
 	return
 }
 
+// SubscribeEvents returns a subscriber for the monitor's event open, update, and close changes
+func (m *Monitor) SubscribeEvents(bufferSize int) (result *pubsubmutex.Subscriber[Event]) {
+	r, err := pubsubmutex.Subscribe[Event](&m.pubsub, topicMonitorEvents, m.pubsub.GetUniqueSubscriberID(), bufferSize)
+	if err == nil && r != nil {
+		result = r
+	}
+	return
+}
+
+func (m *Monitor) publishEvent(event Event) {
+	pubsubmutex.Publish(&m.pubsub, pubsubmutex.Message[Event]{Topic: topicMonitorEvents, Data: event})
+}
+
 // GetMonitorFrameStats returns the monitor's frame stats
 func (m *Monitor) GetMonitorFrameStats(timeoutMs int) (result *videosource.FrameStatsCombo) {
 	r, ok := pubsubmutex.SendReceive[any, *videosource.FrameStatsCombo](&m.pubsub,
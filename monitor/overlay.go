@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/jonoton/go-videosource"
+	log "github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+)
+
+// Overlay Constants
+const (
+	OverlayBoxes     = "boxes"
+	OverlayLabels    = "labels"
+	OverlayTimestamp = "timestamp"
+	OverlayName      = "name"
+)
+
+const overlayTimestampFormat = "2006-01-02 15:04:05"
+
+// OverlayConfig contains the overlay layers for each output
+type OverlayConfig struct {
+	Live   []string `yaml:"live,omitempty"`
+	Record []string `yaml:"record,omitempty"`
+	Alert  []string `yaml:"alert,omitempty"`
+}
+
+// Overlay draws the configured layers for each output of a monitor
+type Overlay struct {
+	name   string
+	live   []string
+	record []string
+	alert  []string
+}
+
+// NewOverlay creates a new Overlay, defaulting to boxes for live and alert and no layers for recordings
+func NewOverlay(name string, conf *OverlayConfig) *Overlay {
+	o := &Overlay{
+		name:   name,
+		live:   []string{OverlayBoxes},
+		record: []string{},
+		alert:  []string{OverlayBoxes},
+	}
+	if conf != nil {
+		if conf.Live != nil {
+			o.live = conf.Live
+		}
+		if conf.Record != nil {
+			o.record = conf.Record
+		}
+		if conf.Alert != nil {
+			o.alert = conf.Alert
+		}
+		for _, layers := range [][]string{o.live, o.record, o.alert} {
+			for _, cur := range layers {
+				if !isOverlayLayer(cur) {
+					log.Warnln("Unknown overlay layer", cur, "for", name)
+				}
+			}
+		}
+	}
+	return o
+}
+
+func isOverlayLayer(layer string) bool {
+	switch layer {
+	case OverlayBoxes, OverlayLabels, OverlayTimestamp, OverlayName:
+		return true
+	}
+	return false
+}
+
+// Live returns the image for live view
+func (o *Overlay) Live(img *videosource.ProcessedImage) *videosource.Image {
+	return o.render(img, o.live)
+}
+
+// Alert returns the image for alerts and event snapshots
+func (o *Overlay) Alert(img *videosource.ProcessedImage) *videosource.Image {
+	return o.render(img, o.alert)
+}
+
+// Record returns the processed image for recordings with the layers burned into the original image
+func (o *Overlay) Record(img *videosource.ProcessedImage) *videosource.ProcessedImage {
+	if len(o.record) == 0 || !img.Original.IsFilled() {
+		return img.Ref()
+	}
+	result := moveDetections(*img.Ref(), *o.render(img, o.record))
+	return &result
+}
+
+func (o *Overlay) render(img *videosource.ProcessedImage, layers []string) *videosource.Image {
+	var result *videosource.Image
+	if hasLayer(layers, OverlayBoxes) {
+		result = img.HighlightedAll()
+	} else {
+		result = img.Original.Clone()
+	}
+	if !result.IsFilled() {
+		return result
+	}
+	fontScale := math.Max(0.4, float64(result.Height())/720*0.7)
+	if hasLayer(layers, OverlayLabels) {
+		for _, cur := range img.Objects {
+			drawText(&result.SharedMat.Mat, fmt.Sprintf("%s %d%%", cur.Description, cur.Percentage), cur.Rect.Min, fontScale)
+		}
+		for _, cur := range img.Faces {
+			drawText(&result.SharedMat.Mat, fmt.Sprintf("%s %d%%", EventLabelFace, cur.Percentage), cur.Rect.Min, fontScale)
+		}
+	}
+	if hasLayer(layers, OverlayTimestamp) {
+		drawText(&result.SharedMat.Mat, img.Original.CreatedTime().Format(overlayTimestampFormat), image.Pt(0, 0), fontScale)
+	}
+	if hasLayer(layers, OverlayName) {
+		drawText(&result.SharedMat.Mat, o.name, image.Pt(0, result.Height()), fontScale)
+	}
+	return result
+}
+
+func hasLayer(layers []string, layer string) bool {
+	for _, cur := range layers {
+		if cur == layer {
+			return true
+		}
+	}
+	return false
+}
+
+// drawText draws the text on a dark background with the top left corner near the point, kept within the image
+func drawText(mat *gocv.Mat, text string, pt image.Point, fontScale float64) {
+	thickness := int(math.Max(1, math.Round(fontScale*2)))
+	textSize, baseline := gocv.GetTextSizeWithBaseline(text, gocv.FontHersheySimplex, fontScale, thickness)
+	padding := thickness * 2
+	width := textSize.X + padding*2
+	height := textSize.Y + baseline + padding*2
+	x := min(max(pt.X, 0), max(mat.Cols()-width, 0))
+	y := min(max(pt.Y-height, 0), max(mat.Rows()-height, 0))
+	gocv.Rectangle(mat, image.Rect(x, y, x+width, y+height), color.RGBA{0, 0, 0, 255}, -1)
+	gocv.PutTextWithParams(mat, text, image.Pt(x+padding, y+padding+textSize.Y), gocv.FontHersheySimplex, fontScale,
+		color.RGBA{255, 255, 255, 255}, thickness, gocv.LineAA, false)
+}
@@ -0,0 +1,30 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewOverlay(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   *OverlayConfig
+		live   []string
+		record []string
+		alert  []string
+	}{
+		{name: "defaults", conf: nil, live: []string{OverlayBoxes}, record: []string{}, alert: []string{OverlayBoxes}},
+		{name: "record only", conf: &OverlayConfig{Record: []string{OverlayTimestamp}},
+			live: []string{OverlayBoxes}, record: []string{OverlayTimestamp}, alert: []string{OverlayBoxes}},
+		{name: "empty live", conf: &OverlayConfig{Live: []string{}},
+			live: []string{}, record: []string{}, alert: []string{OverlayBoxes}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOverlay("cam1", tt.conf)
+			if !reflect.DeepEqual(o.live, tt.live) || !reflect.DeepEqual(o.record, tt.record) || !reflect.DeepEqual(o.alert, tt.alert) {
+				t.Errorf("NewOverlay() = %v %v %v, expected %v %v %v", o.live, o.record, o.alert, tt.live, tt.record, tt.alert)
+			}
+		})
+	}
+}
@@ -1,10 +1,9 @@
 package monitor
 
 import (
-	"math"
 	"os"
 	"path/filepath"
-	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +12,7 @@ import (
 	"github.com/jonoton/go-dir"
 	pubsubmutex "github.com/jonoton/go-pubsubmutex"
 	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/seal"
 )
 
 const topicRecordImages = "topic-record-images"
@@ -22,13 +22,19 @@ type Record struct {
 	name          string
 	saveDirectory string
 	RecordConf    *RecordConfig
+	fileType      string
 	writer        *videosource.VideoWriter
 	pubsub        pubsubmutex.PubSub
 	bufferSize    int
 	done          chan bool
 	cancel        chan bool
 	cancelOnce    sync.Once
-	hourTick      *time.Ticker
+	fileTick      *time.Ticker
+	timeline      []timelineFrame
+	started       time.Time
+	tracker       *ProcessTracker
+	key           *seal.Key
+	lastSent      time.Time
 }
 
 // NewRecord creates a new Record
@@ -36,7 +42,7 @@ func NewRecord(name string, saveDirectory string, recordConf *RecordConfig, outF
 	if saveDirectory == "" || recordConf == nil {
 		return nil
 	}
-	recordDir := filepath.Clean(saveDirectory+"/recordings") + string(filepath.Separator)
+	recordDir := MonitorDirectory(saveDirectory, CategoryRecordings, name)
 	os.MkdirAll(recordDir, os.ModePerm)
 	codec := "mp4v"
 	if len(recordConf.Codec) == 4 {
@@ -51,13 +57,18 @@ func NewRecord(name string, saveDirectory string, recordConf *RecordConfig, outF
 		name:          name,
 		saveDirectory: recordDir,
 		RecordConf:    recordConf,
+		fileType:      fileType,
 		writer: videosource.NewVideoWriter(name, recordDir, codec, fileType, recordConf.BufferSeconds, recordConf.MaxPreSec,
 			recordConf.TimeoutSec, recordConf.MaxSec, outFps, true, true, saveFull, videosource.ActivityObject),
 		pubsub:     *pubsubmutex.NewPubSub(),
 		bufferSize: recordConf.BufferSeconds * outFps,
 		done:       make(chan bool),
 		cancel:     make(chan bool),
-		hourTick:   time.NewTicker(time.Hour),
+		fileTick:   time.NewTicker(10 * time.Second),
+		timeline:   make([]timelineFrame, 0),
+		started:    time.Now(),
+		tracker:    NewProcessTracker("record"),
+		key:        nil,
 	}
 	pubsubmutex.RegisterTopic[*videosource.ProcessedImage](&r.pubsub, topicRecordImages)
 
@@ -69,17 +80,23 @@ func (r *Record) Wait() {
 	<-r.done
 }
 
+// SetEncryption sets the key that seals finished recordings
+func (r *Record) SetEncryption(key *seal.Key) {
+	r.key = key
+}
+
 // Start the processes
 func (r *Record) Start() {
 	go func() {
 		r.writer.Start()
 		imageSub, _ := pubsubmutex.Subscribe[*videosource.ProcessedImage](&r.pubsub,
 			topicRecordImages, r.pubsub.GetUniqueSubscriberID(), r.bufferSize)
+		r.tracker.SetQueueDepthFunc(func() int { return len(imageSub.Ch) })
 	Loop:
 		for {
 			select {
-			case <-r.hourTick.C:
-				r.prune()
+			case <-r.fileTick.C:
+				r.fileRecordings(settledBefore(r.lastSent, r.RecordConf.BufferSeconds))
 			case msg, ok := <-imageSub.Ch:
 				if !ok {
 					if msg.Data != nil {
@@ -92,16 +109,19 @@ func (r *Record) Start() {
 					continue
 				}
 				img := msg.Data
+				r.tracker.In(len(imageSub.Ch))
+				start := time.Now()
 				r.process(*img)
+				r.tracker.Out(start)
 			case <-r.cancel:
 				break Loop
 			}
 		}
 		imageSub.Unsubscribe()
-		r.hourTick.Stop()
-		r.prune()
+		r.fileTick.Stop()
 		r.writer.Close()
 		r.writer.Wait()
+		r.fileRecordings(time.Now())
 		r.pubsub.Close()
 		close(r.done)
 	}()
@@ -111,49 +131,80 @@ func (r *Record) process(img videosource.ProcessedImage) {
 	if r.RecordConf.RecordObjects && img.HasObject() {
 		r.writer.Trigger()
 	}
+	r.timeline = addTimelineFrame(r.timeline,
+		timelineFrame{time: img.Original.CreatedTime(), detections: timelineDetections(&img)}, timelineKeep)
 	r.writer.Send(img)
+	r.lastSent = time.Now()
 }
 
-func (r *Record) prune() {
-	r.deleteOldRecordings()
-	r.deleteWhenFull()
-}
-
-func (r *Record) deleteOldRecordings() {
-	expiredFiles, _ := dir.Expired(r.saveDirectory, dir.RegexBeginsWith(r.name),
-		time.Now(), time.Duration(r.RecordConf.DeleteAfterHours)*time.Hour)
-	for _, fileInfo := range expiredFiles {
-		fullPath := filepath.Clean(r.saveDirectory + string(filepath.Separator) + fileInfo.Name())
-		err := os.Remove(fullPath)
+// fileRecordings writes the sidecars of the recordings finished before settled and moves them to their daily directory
+func (r *Record) fileRecordings(settled time.Time) {
+	for _, fileInfo := range finishedFiles(r.saveDirectory, settled) {
+		name := fileInfo.Name()
+		end := fileInfo.ModTime()
+		if IsRecordingSidecar(name) {
+			continue
+		}
+		fullPath := filepath.Join(r.saveDirectory, name)
+		// recordings from before the start or by another writer have no timeline
+		if !strings.HasPrefix(name, r.name+"_") || !strings.HasSuffix(name, "."+r.fileType) ||
+			end.Before(r.started) {
+			if sealFiles(r.key, fullPath) {
+				fileByDate(r.saveDirectory, end, name)
+			}
+			continue
+		}
+		duration, err := recordingDuration(fullPath)
+		if err == nil {
+			sidecar := buildSidecar(r.name, name, end.Add(-duration), end, r.timeline)
+			err = sidecar.Save(fullPath)
+		}
 		if err != nil {
-			log.Errorln(err)
+			log.Warnln("Recording sidecar skipped", name, err)
+		}
+		// a recording still being written is filed on a later pass
+		if !sealFiles(r.key, fullPath) {
+			continue
+		}
+		if err == nil {
+			sealFiles(r.key, RecordingSidecarPath(fullPath), RecordingSubtitlePath(fullPath))
 		}
+		fileByDate(r.saveDirectory, end, name,
+			filepath.Base(RecordingSidecarPath(name)), filepath.Base(RecordingSubtitlePath(name)))
 	}
 }
 
-func (r *Record) deleteWhenFull() {
-	dirSize, _ := dir.Size(r.saveDirectory, dir.RegexBeginsWith(r.name))
-	if int(math.Ceil(dir.BytesToGigaBytes(dirSize))) > r.RecordConf.DeleteAfterGB {
-		files, _ := dir.List(r.saveDirectory, dir.RegexBeginsWith(r.name))
-		sort.Sort(dir.AscendingTime(files))
+// Send a processed image to buffer
+func (r *Record) Send(img *videosource.ProcessedImage) {
+	pubsubmutex.Publish(&r.pubsub,
+		pubsubmutex.Message[*videosource.ProcessedImage]{Topic: topicRecordImages, Data: img})
+	r.tracker.Offer()
+}
+
+// RecordingAt returns the recording path covering the time or empty when not found.
+// A recording still being written is returned at its path in the monitor directory, before it is filed by date.
+func (r *Record) RecordingAt(t time.Time) string {
+	result := ""
+	var resultTime time.Time
+	dirs := []string{r.saveDirectory, DateDirectory(r.saveDirectory, t), DateDirectory(r.saveDirectory, t.AddDate(0, 0, 1))}
+	for _, cur := range dirs {
+		files, _ := dir.List(cur, dir.RegexEndsWith("\\."+r.fileType))
 		for _, fileInfo := range files {
-			if int(math.Ceil(dir.BytesToGigaBytes(dirSize))) <= r.RecordConf.DeleteAfterGB {
-				break
+			if fileInfo.IsDir() || !strings.HasPrefix(fileInfo.Name(), r.name+"_") || fileInfo.ModTime().Before(t) {
+				continue
 			}
-			dirSize -= uint64(fileInfo.Size())
-			fullPath := filepath.Clean(r.saveDirectory + string(filepath.Separator) + fileInfo.Name())
-			err := os.Remove(fullPath)
-			if err != nil {
-				log.Errorln(err)
+			if result == "" || fileInfo.ModTime().Before(resultTime) {
+				result = filepath.Join(cur, fileInfo.Name())
+				resultTime = fileInfo.ModTime()
 			}
 		}
 	}
+	return result
 }
 
-// Send a processed image to buffer
-func (r *Record) Send(img *videosource.ProcessedImage) {
-	pubsubmutex.Publish(&r.pubsub,
-		pubsubmutex.Message[*videosource.ProcessedImage]{Topic: topicRecordImages, Data: img})
+// Stats returns the current process stats
+func (r *Record) Stats() ProcessStats {
+	return r.tracker.Stats()
 }
 
 // Close notified by caller that input stream is done/closed
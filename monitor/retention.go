@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"strings"
+	"time"
+)
+
+// Storage Categories, named after their directory in the data directory
+const (
+	CategoryContinuous = "continuous"
+	CategoryRecordings = "recordings"
+	CategoryEvents     = "events"
+	CategoryAlerts     = "alerts"
+)
+
+// Retention contains the limits of a monitor's files in a storage category, where zero is no limit.
+// LabelMaxAge replaces MaxAge for files with a detected label in it.
+type Retention struct {
+	Category    string
+	Monitor     string
+	MaxAge      time.Duration
+	LabelMaxAge map[string]time.Duration
+	MaxBytes    uint64
+}
+
+func newRetention(category string, monitorName string, deleteAfterHours int, deleteAfterGB int) Retention {
+	r := Retention{
+		Category: category,
+		Monitor:  monitorName,
+	}
+	if deleteAfterHours > 0 {
+		r.MaxAge = time.Duration(deleteAfterHours) * time.Hour
+	}
+	if deleteAfterGB > 0 {
+		r.MaxBytes = uint64(deleteAfterGB) << 30
+	}
+	return r
+}
+
+func (r *Retention) setLabelHours(deleteAfterHoursByLabel map[string]int) {
+	if len(deleteAfterHoursByLabel) == 0 {
+		return
+	}
+	r.LabelMaxAge = make(map[string]time.Duration)
+	for label, hours := range deleteAfterHoursByLabel {
+		r.LabelMaxAge[strings.ToLower(label)] = time.Duration(hours) * time.Hour
+	}
+}
+
+// MaxAgeOf returns the max age of a file with the labels.
+// The longest age of the labels with a rule is used, otherwise MaxAge.
+func (r Retention) MaxAgeOf(labels []string) time.Duration {
+	matched := false
+	result := time.Duration(0)
+	for _, label := range labels {
+		age, found := r.LabelMaxAge[strings.ToLower(label)]
+		if !found {
+			continue
+		}
+		if age <= 0 {
+			return 0
+		}
+		if !matched || age > result {
+			result = age
+		}
+		matched = true
+	}
+	if !matched {
+		return r.MaxAge
+	}
+	return result
+}
+
+// Retentions returns the retention limits of the monitor's files
+func (m *Monitor) Retentions() []Retention {
+	result := make([]Retention, 0)
+	if m.continuous != nil {
+		conf := m.continuous.ContinuousConf
+		result = append(result, newRetention(CategoryContinuous, m.Name, conf.DeleteAfterHours, conf.DeleteAfterGB))
+	}
+	if m.record != nil {
+		conf := m.record.RecordConf
+		r := newRetention(CategoryRecordings, m.Name, conf.DeleteAfterHours, conf.DeleteAfterGB)
+		r.setLabelHours(conf.DeleteAfterHoursByLabel)
+		result = append(result, r)
+	}
+	if m.events != nil && m.events.EventConf != nil {
+		conf := m.events.EventConf
+		result = append(result, newRetention(CategoryEvents, m.Name, conf.DeleteAfterHours, conf.DeleteAfterGB))
+	}
+	if m.alert != nil {
+		conf := m.alert.alertConf
+		r := newRetention(CategoryAlerts, m.Name, conf.DeleteAfterHours, conf.DeleteAfterGB)
+		r.setLabelHours(conf.DeleteAfterHoursByLabel)
+		result = append(result, r)
+	}
+	return result
+}
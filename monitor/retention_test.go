@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionMaxAgeOf(t *testing.T) {
+	r := newRetention(CategoryRecordings, "cam1", 168, 0)
+	r.setLabelHours(map[string]int{"Person": 720, "face": 720, "cat": 48, "dog": 0})
+	tests := []struct {
+		labels   []string
+		expected time.Duration
+	}{
+		{nil, 168 * time.Hour},
+		{[]string{"car"}, 168 * time.Hour},
+		{[]string{"cat"}, 48 * time.Hour},
+		{[]string{"cat", "person"}, 720 * time.Hour},
+		{[]string{"face"}, 720 * time.Hour},
+		{[]string{"cat", "dog"}, 0},
+	}
+	for _, test := range tests {
+		if result := r.MaxAgeOf(test.labels); result != test.expected {
+			t.Errorf("MaxAgeOf(%v) = %v, expected %v", test.labels, result, test.expected)
+		}
+	}
+}
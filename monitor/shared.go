@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"sync"
+
+	"github.com/jonoton/go-videosource"
+	log "github.com/sirupsen/logrus"
+)
+
+// SharedReader fans out the frames of one VideoReader to multiple monitors
+type SharedReader struct {
+	Reader    *videosource.VideoReader
+	mu        sync.Mutex
+	consumers map[string]chan videosource.Image
+	started   bool
+	closed    bool
+}
+
+// NewSharedReader creates a new SharedReader
+func NewSharedReader(reader *videosource.VideoReader) *SharedReader {
+	s := &SharedReader{
+		Reader:    reader,
+		consumers: make(map[string]chan videosource.Image),
+		started:   false,
+		closed:    false,
+	}
+	return s
+}
+
+// Attach returns the frames for the consumer and starts the reader for the first consumer
+func (s *SharedReader) Attach(name string, bufferSize int) <-chan videosource.Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(chan videosource.Image, bufferSize)
+	if s.closed {
+		close(result)
+		return result
+	}
+	if cur, found := s.consumers[name]; found {
+		close(cur)
+	}
+	s.consumers[name] = result
+	if !s.started {
+		s.started = true
+		go s.run(s.Reader.Start())
+	}
+	return result
+}
+
+// Detach removes the consumer and stops the reader after the last consumer
+func (s *SharedReader) Detach(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, found := s.consumers[name]; found {
+		close(cur)
+		delete(s.consumers, name)
+	}
+	if len(s.consumers) == 0 && !s.closed {
+		s.closed = true
+		s.Reader.Stop()
+	}
+}
+
+// Close stops the reader for all consumers, such as when the source stalled
+func (s *SharedReader) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.Reader.Stop()
+	}
+}
+
+// Closed returns true when the reader is stopping or done and cannot be attached
+func (s *SharedReader) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *SharedReader) run(inChan <-chan videosource.Image) {
+	for img := range inChan {
+		s.mu.Lock()
+		for name, consumer := range s.consumers {
+			ref := img.Ref()
+			select {
+			case consumer <- *ref:
+			default:
+				ref.Cleanup()
+				log.Debugln("Shared reader dropped frame for", name)
+			}
+		}
+		s.mu.Unlock()
+		img.Cleanup()
+	}
+	s.Reader.Wait()
+	s.mu.Lock()
+	s.closed = true
+	for name, consumer := range s.consumers {
+		close(consumer)
+		delete(s.consumers, name)
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,264 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"github.com/jonoton/go-videosource"
+
+	"github.com/jonoton/scout/seal"
+)
+
+// Sidecar Extensions
+const (
+	RecordingSidecarExt  = ".json"
+	RecordingSubtitleExt = ".vtt"
+)
+
+const (
+	timelineKeep = time.Hour
+)
+
+// TimelineDetection is one object or face in a frame
+type TimelineDetection struct {
+	Label      string
+	Confidence int
+	Rect       image.Rectangle
+}
+
+// TimelineSecond contains the detections of the frame with the most detections in a second of the recording
+type TimelineSecond struct {
+	Offset     int
+	Detections []TimelineDetection
+}
+
+// TimelineLabel summarizes a label seen in the recording
+type TimelineLabel struct {
+	Label         string
+	FirstSec      int
+	Seconds       int
+	MaxConfidence int
+}
+
+// RecordingSidecar describes the detections of a recording
+type RecordingSidecar struct {
+	Monitor   string
+	Recording string
+	Start     time.Time
+	End       time.Time
+	Labels    []TimelineLabel
+	Timeline  []TimelineSecond
+}
+
+// ReadRecordingSidecar reads the sidecar of the recording path, decrypting it with the key when sealed
+func ReadRecordingSidecar(key *seal.Key, recordingPath string) (*RecordingSidecar, error) {
+	data, err := seal.ReadFile(key, RecordingSidecarPath(recordingPath))
+	if err != nil {
+		return nil, err
+	}
+	s := &RecordingSidecar{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RecordingSidecarPath returns the sidecar path of the recording path
+func RecordingSidecarPath(recordingPath string) string {
+	return recordingBase(recordingPath) + RecordingSidecarExt
+}
+
+// RecordingSubtitlePath returns the WebVTT subtitle path of the recording path
+func RecordingSubtitlePath(recordingPath string) string {
+	return recordingBase(recordingPath) + RecordingSubtitleExt
+}
+
+// IsRecordingSidecar returns true for sidecar and subtitle filenames
+func IsRecordingSidecar(filename string) bool {
+	return strings.HasSuffix(filename, RecordingSidecarExt) || strings.HasSuffix(filename, RecordingSubtitleExt)
+}
+
+func recordingBase(recordingPath string) string {
+	if i := strings.LastIndex(recordingPath, "."); i > strings.LastIndexAny(recordingPath, `/\`) {
+		return recordingPath[:i]
+	}
+	return recordingPath
+}
+
+// timelineFrame holds the detections of a frame at its capture time
+type timelineFrame struct {
+	time       time.Time
+	detections []TimelineDetection
+}
+
+func timelineDetections(img *videosource.ProcessedImage) []TimelineDetection {
+	result := make([]TimelineDetection, 0, len(img.Objects)+len(img.Faces))
+	for _, cur := range img.Objects {
+		result = append(result, TimelineDetection{Label: cur.Description, Confidence: cur.Percentage, Rect: cur.Rect})
+	}
+	for _, cur := range img.Faces {
+		result = append(result, TimelineDetection{Label: EventLabelFace, Confidence: cur.Percentage, Rect: cur.Rect})
+	}
+	return result
+}
+
+// addTimelineFrame keeps the frame with the most detections of each second, dropping frames older than keep
+func addTimelineFrame(frames []timelineFrame, frame timelineFrame, keep time.Duration) []timelineFrame {
+	if len(frame.detections) == 0 {
+		return frames
+	}
+	if last := len(frames) - 1; last >= 0 && frames[last].time.Truncate(time.Second).Equal(frame.time.Truncate(time.Second)) {
+		if len(frame.detections) > len(frames[last].detections) {
+			frames[last] = frame
+		}
+		return frames
+	}
+	frames = append(frames, frame)
+	oldest := frame.time.Add(-keep)
+	drop := 0
+	for drop < len(frames) && frames[drop].time.Before(oldest) {
+		drop++
+	}
+	return frames[drop:]
+}
+
+// buildSidecar creates the sidecar from the frames between start and end
+func buildSidecar(monitorName string, recording string, start time.Time, end time.Time, frames []timelineFrame) *RecordingSidecar {
+	s := &RecordingSidecar{
+		Monitor:   monitorName,
+		Recording: recording,
+		Start:     start,
+		End:       end,
+		Labels:    make([]TimelineLabel, 0),
+		Timeline:  make([]TimelineSecond, 0),
+	}
+	labels := make(map[string]*TimelineLabel)
+	for _, cur := range frames {
+		if cur.time.Before(start) || cur.time.After(end) {
+			continue
+		}
+		offset := int(cur.time.Sub(start) / time.Second)
+		s.Timeline = append(s.Timeline, TimelineSecond{Offset: offset, Detections: cur.detections})
+		seen := make(map[string]bool)
+		for _, det := range cur.detections {
+			label, found := labels[det.Label]
+			if !found {
+				label = &TimelineLabel{Label: det.Label, FirstSec: offset}
+				labels[det.Label] = label
+			}
+			if !seen[det.Label] {
+				seen[det.Label] = true
+				label.Seconds++
+			}
+			if det.Confidence > label.MaxConfidence {
+				label.MaxConfidence = det.Confidence
+			}
+		}
+	}
+	for _, cur := range labels {
+		s.Labels = append(s.Labels, *cur)
+	}
+	sort.Slice(s.Labels, func(i, j int) bool {
+		if s.Labels[i].FirstSec != s.Labels[j].FirstSec {
+			return s.Labels[i].FirstSec < s.Labels[j].FirstSec
+		}
+		return s.Labels[i].Label < s.Labels[j].Label
+	})
+	return s
+}
+
+// cueText returns the labels of the second with their highest confidence
+func cueText(second TimelineSecond) string {
+	best := make(map[string]int)
+	for _, det := range second.Detections {
+		if cur, found := best[det.Label]; !found || det.Confidence > cur {
+			best[det.Label] = det.Confidence
+		}
+	}
+	labels := make([]string, 0, len(best))
+	for label := range best {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, fmt.Sprintf("%s %d%%", label, best[label]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func vttTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// WriteVTT writes the labels of each second as WebVTT cues, joining consecutive seconds with the same labels
+func (s *RecordingSidecar) WriteVTT(w io.Writer) error {
+	if _, err := io.WriteString(w, "WEBVTT\n"); err != nil {
+		return err
+	}
+	cue := 0
+	for i := 0; i < len(s.Timeline); {
+		text := cueText(s.Timeline[i])
+		first := s.Timeline[i].Offset
+		last := first
+		j := i + 1
+		for j < len(s.Timeline) && s.Timeline[j].Offset == last+1 && cueText(s.Timeline[j]) == text {
+			last = s.Timeline[j].Offset
+			j++
+		}
+		i = j
+		cue++
+		_, err := fmt.Fprintf(w, "\n%d\n%s --> %s\n%s\n", cue,
+			vttTimestamp(time.Duration(first)*time.Second), vttTimestamp(time.Duration(last+1)*time.Second), text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the JSON sidecar and the WebVTT subtitles next to the recording path
+func (s *RecordingSidecar) Save(recordingPath string) error {
+	vtt, err := os.Create(RecordingSubtitlePath(recordingPath))
+	if err != nil {
+		return err
+	}
+	err = s.WriteVTT(vtt)
+	if closeErr := vtt.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// written last as it marks the recording done
+	return os.WriteFile(RecordingSidecarPath(recordingPath), data, 0644)
+}
+
+// recordingDuration returns the length of the video file
+func recordingDuration(path string) (time.Duration, error) {
+	vc, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer vc.Close()
+	frames := vc.Get(gocv.VideoCaptureFrameCount)
+	fps := vc.Get(gocv.VideoCaptureFPS)
+	if frames <= 0 || fps <= 0 {
+		return 0, errors.New("unknown recording length")
+	}
+	return time.Duration(frames / fps * float64(time.Second)), nil
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"bytes"
+	"image"
+	"testing"
+	"time"
+)
+
+func TestAddTimelineFrame(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	person := TimelineDetection{Label: "person", Confidence: 80, Rect: image.Rect(0, 0, 10, 10)}
+	car := TimelineDetection{Label: "car", Confidence: 60, Rect: image.Rect(10, 10, 20, 20)}
+	frames := make([]timelineFrame, 0)
+	frames = addTimelineFrame(frames, timelineFrame{time: start}, time.Minute)
+	frames = addTimelineFrame(frames, timelineFrame{time: start.Add(100 * time.Millisecond), detections: []TimelineDetection{person}}, time.Minute)
+	frames = addTimelineFrame(frames, timelineFrame{time: start.Add(500 * time.Millisecond), detections: []TimelineDetection{person, car}}, time.Minute)
+	frames = addTimelineFrame(frames, timelineFrame{time: start.Add(900 * time.Millisecond), detections: []TimelineDetection{car}}, time.Minute)
+	if len(frames) != 1 || len(frames[0].detections) != 2 {
+		t.Fatalf("addTimelineFrame() kept %v, expected the frame with the most detections", frames)
+	}
+	frames = addTimelineFrame(frames, timelineFrame{time: start.Add(2 * time.Minute), detections: []TimelineDetection{car}}, time.Minute)
+	if len(frames) != 1 || !frames[0].time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("addTimelineFrame() kept %v, expected old frames dropped", frames)
+	}
+}
+
+func TestBuildSidecar(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	person := TimelineDetection{Label: "person", Confidence: 80}
+	car := TimelineDetection{Label: "car", Confidence: 60}
+	frames := []timelineFrame{
+		{time: start.Add(-time.Second), detections: []TimelineDetection{person}},
+		{time: start.Add(2 * time.Second), detections: []TimelineDetection{car}},
+		{time: start.Add(3 * time.Second), detections: []TimelineDetection{car}},
+		{time: start.Add(4 * time.Second), detections: []TimelineDetection{car, person, {Label: "person", Confidence: 90}}},
+		{time: start.Add(20 * time.Second), detections: []TimelineDetection{person}},
+	}
+	s := buildSidecar("cam1", "cam1.mp4", start, start.Add(10*time.Second), frames)
+	if len(s.Timeline) != 3 || s.Timeline[0].Offset != 2 || s.Timeline[2].Offset != 4 {
+		t.Fatalf("buildSidecar() timeline = %v, expected offsets 2 to 4", s.Timeline)
+	}
+	expected := []TimelineLabel{
+		{Label: "car", FirstSec: 2, Seconds: 3, MaxConfidence: 60},
+		{Label: "person", FirstSec: 4, Seconds: 1, MaxConfidence: 90},
+	}
+	if len(s.Labels) != len(expected) || s.Labels[0] != expected[0] || s.Labels[1] != expected[1] {
+		t.Errorf("buildSidecar() labels = %v, expected %v", s.Labels, expected)
+	}
+
+	var out bytes.Buffer
+	if err := s.WriteVTT(&out); err != nil {
+		t.Fatal(err)
+	}
+	vtt := "WEBVTT\n\n1\n00:00:02.000 --> 00:00:04.000\ncar 60%\n\n2\n00:00:04.000 --> 00:00:05.000\ncar 60%, person 90%\n"
+	if out.String() != vtt {
+		t.Errorf("WriteVTT() = %q, expected %q", out.String(), vtt)
+	}
+}
+
+func TestRecordingSidecarPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/data/recordings/cam1_Portable.mp4", expected: "/data/recordings/cam1_Portable.json"},
+		{path: "/data/rec.v2/cam1", expected: "/data/rec.v2/cam1.json"},
+	}
+	for _, tt := range tests {
+		if got := RecordingSidecarPath(tt.path); got != tt.expected {
+			t.Errorf("RecordingSidecarPath(%s) = %s, expected %s", tt.path, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package monitor
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonoton/go-videosource"
+	"github.com/jonoton/scout/face"
+	"github.com/jonoton/scout/motion"
+	"github.com/jonoton/scout/preprocess"
+	"github.com/jonoton/scout/tensor"
+)
+
+// Stage Constants
+const (
+	StagePreprocess = "preprocess"
+	StageMotion     = "motion"
+	StageTensor     = "tensor"
+	StageFace       = "face"
+)
+
+// Stage is a step in the monitor processing pipeline
+type Stage interface {
+	// Name returns the stage name
+	Name() string
+	// SetConfig loads the stage config file and returns true when applied
+	SetConfig(configPath string) bool
+	// Run processes images from input and returns the output channel
+	Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage
+	// Stats returns the current stage stats
+	Stats() ProcessStats
+}
+
+// StageFactory creates a new Stage for a monitor
+type StageFactory func(monitorName string) Stage
+
+var (
+	stageFactoriesMu sync.Mutex
+	stageFactories   = map[string]StageFactory{
+		StagePreprocess: newPreprocessStage,
+		StageMotion:     newMotionStage,
+		StageTensor:     newTensorStage,
+		StageFace:       newFaceStage,
+	}
+)
+
+// RegisterStage adds a stage factory which can be referenced in a monitor pipeline
+func RegisterStage(stageName string, factory StageFactory) {
+	stageFactoriesMu.Lock()
+	defer stageFactoriesMu.Unlock()
+	stageFactories[stageName] = factory
+}
+
+// NewStage creates a registered stage or nil if not found
+func NewStage(stageName string, monitorName string) Stage {
+	stageFactoriesMu.Lock()
+	factory, found := stageFactories[stageName]
+	stageFactoriesMu.Unlock()
+	if !found {
+		return nil
+	}
+	return factory(monitorName)
+}
+
+// BaseStage tracks frames passing through a stage and can be embedded by Stage implementations.
+// Stages using RunTracked must output one image per input image in order.
+type BaseStage struct {
+	name      string
+	tracker   *ProcessTracker
+	sheddable bool
+	gate      *analysisGate
+	armable   bool
+	disarmed  atomic.Bool
+}
+
+// NewBaseStage creates a new BaseStage
+func NewBaseStage(name string) *BaseStage {
+	b := &BaseStage{
+		name:      name,
+		tracker:   NewProcessTracker(name),
+		sheddable: false,
+		gate:      nil,
+		armable:   true,
+	}
+	return b
+}
+
+// Name returns the stage name
+func (b *BaseStage) Name() string {
+	return b.name
+}
+
+// Stats returns the current stage stats
+func (b *BaseStage) Stats() ProcessStats {
+	return b.tracker.Stats()
+}
+
+// SetSheddable sets whether frames may skip the stage when the monitor is overloaded
+func (b *BaseStage) SetSheddable(sheddable bool) {
+	b.sheddable = sheddable
+}
+
+func (b *BaseStage) isSheddable() bool {
+	return b.sheddable
+}
+
+func (b *BaseStage) setAnalysisGate(gate *analysisGate) {
+	b.gate = gate
+}
+
+// SetArmable sets whether frames pass through unprocessed while the monitor is disarmed
+func (b *BaseStage) SetArmable(armable bool) {
+	b.armable = armable
+}
+
+func (b *BaseStage) setArmed(armed bool) {
+	b.disarmed.Store(b.armable && !armed)
+}
+
+// sheddableStage is implemented by stages embedding BaseStage
+type sheddableStage interface {
+	isSheddable() bool
+	setAnalysisGate(gate *analysisGate)
+}
+
+// armableStage is implemented by stages embedding BaseStage
+type armableStage interface {
+	setArmed(armed bool)
+}
+
+// outputStage is implemented by stages replacing the analysis image while keeping the output image
+type outputStage interface {
+	keepsOutput() bool
+	setOutputQueue(queue *frameQueue)
+}
+
+type stageToken struct {
+	bypass bool
+	img    videosource.ProcessedImage
+	start  time.Time
+}
+
+// RunTracked wraps runFunc to record the latency, queue depth, and frame counts.
+// Frames pass through unprocessed when the stage is disarmed,
+// or when the stage is sheddable and the analysis gate skips them.
+func (b *BaseStage) RunTracked(input <-chan videosource.ProcessedImage,
+	runFunc func(<-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
+	tokens := make(chan stageToken, 1024)
+	trackedInput := make(chan videosource.ProcessedImage)
+	go func() {
+		for img := range input {
+			b.tracker.In(len(input))
+			if b.disarmed.Load() || (b.sheddable && b.gate != nil && !b.gate.Analyze(img.Original.CreatedTime())) {
+				tokens <- stageToken{bypass: true, img: img}
+				continue
+			}
+			tokens <- stageToken{start: time.Now()}
+			trackedInput <- img
+		}
+		close(trackedInput)
+		close(tokens)
+	}()
+	output := runFunc(trackedInput)
+	r := make(chan videosource.ProcessedImage)
+	go func() {
+		outputOpen := true
+		for token := range tokens {
+			if token.bypass {
+				b.tracker.Skip()
+				r <- token.img
+				continue
+			}
+			if !outputOpen {
+				continue
+			}
+			img, ok := <-output
+			if !ok {
+				outputOpen = false
+				continue
+			}
+			b.tracker.Out(token.start)
+			r <- img
+		}
+		if outputOpen {
+			for img := range output {
+				r <- img
+			}
+		}
+		close(r)
+	}()
+	return r
+}
+
+type preprocessStage struct {
+	*BaseStage
+	preprocess *preprocess.Preprocess
+}
+
+func newPreprocessStage(monitorName string) Stage {
+	s := &preprocessStage{
+		BaseStage:  NewBaseStage(StagePreprocess),
+		preprocess: preprocess.NewPreprocess(monitorName),
+	}
+	s.SetArmable(false)
+	return s
+}
+
+func (s *preprocessStage) SetConfig(configPath string) bool {
+	conf := preprocess.NewConfig(configPath)
+	s.preprocess.SetConfig(conf)
+	return conf != nil
+}
+
+func (s *preprocessStage) Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
+	return s.RunTracked(input, s.preprocess.Run)
+}
+
+func (s *preprocessStage) keepsOutput() bool {
+	return s.preprocess.KeepsOutput()
+}
+
+func (s *preprocessStage) setOutputQueue(queue *frameQueue) {
+	s.preprocess.SetOutputFunc(func(img videosource.Image) {
+		queue.push(img, true)
+	})
+}
+
+type motionStage struct {
+	*BaseStage
+	motion *motion.Motion
+}
+
+func newMotionStage(monitorName string) Stage {
+	s := &motionStage{
+		BaseStage: NewBaseStage(StageMotion),
+		motion:    motion.NewMotion(monitorName),
+	}
+	return s
+}
+
+func (s *motionStage) SetConfig(configPath string) bool {
+	conf := motion.NewConfig(configPath)
+	s.motion.SetConfig(conf)
+	return conf != nil
+}
+
+func (s *motionStage) Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
+	return s.RunTracked(input, s.motion.Run)
+}
+
+type tensorStage struct {
+	*BaseStage
+	tensor *tensor.Tensor
+}
+
+func newTensorStage(monitorName string) Stage {
+	s := &tensorStage{
+		BaseStage: NewBaseStage(StageTensor),
+		tensor:    tensor.NewTensor(monitorName),
+	}
+	s.SetSheddable(true)
+	return s
+}
+
+func (s *tensorStage) SetConfig(configPath string) bool {
+	conf := tensor.NewConfig(configPath)
+	s.tensor.SetConfig(conf)
+	return conf != nil
+}
+
+func (s *tensorStage) Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
+	return s.RunTracked(input, s.tensor.Run)
+}
+
+type faceStage struct {
+	*BaseStage
+	face *face.Face
+}
+
+func newFaceStage(monitorName string) Stage {
+	s := &faceStage{
+		BaseStage: NewBaseStage(StageFace),
+		face:      face.NewFace(monitorName),
+	}
+	s.SetSheddable(true)
+	return s
+}
+
+func (s *faceStage) SetConfig(configPath string) bool {
+	conf := face.NewConfig(configPath)
+	s.face.SetConfig(conf)
+	return conf != nil
+}
+
+func (s *faceStage) Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
+	return s.RunTracked(input, s.face.Run)
+}
@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const maxLatencySamples = 100
+
+// ProcessStats contains the latency, queue depth, and drop metrics for a stage or sink
+type ProcessStats struct {
+	Name          string
+	InTotal       int
+	OutTotal      int
+	SkippedTotal  int
+	DroppedTotal  int
+	QueueDepth    int
+	MaxQueueDepth int
+	LatencyP50Ms  float64
+	LatencyP90Ms  float64
+	LatencyP99Ms  float64
+	LatencyMaxMs  float64
+}
+
+// PipelineStats contains the process stats for all stages and sinks of a monitor
+type PipelineStats struct {
+	Stages   []ProcessStats
+	Sinks    []ProcessStats
+	Adaptive *AdaptiveStats
+}
+
+// ProcessTracker records the metrics for a stage or sink
+type ProcessTracker struct {
+	mu             sync.Mutex
+	name           string
+	offeredTotal   int
+	inTotal        int
+	outTotal       int
+	skippedTotal   int
+	droppedTotal   int
+	queueDepth     int
+	maxQueueDepth  int
+	queueDepthFunc func() int
+	latencies      []time.Duration
+	latencyIndex   int
+}
+
+// NewProcessTracker creates a new ProcessTracker
+func NewProcessTracker(name string) *ProcessTracker {
+	p := &ProcessTracker{
+		name:      name,
+		latencies: make([]time.Duration, 0, maxLatencySamples),
+	}
+	return p
+}
+
+// SetQueueDepthFunc sets a function returning the live input queue depth
+func (p *ProcessTracker) SetQueueDepthFunc(queueDepthFunc func() int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queueDepthFunc = queueDepthFunc
+}
+
+// Offer records a frame published to an input queue that may drop when full.
+// Frames offered but never taken from the queue are counted as dropped.
+func (p *ProcessTracker) Offer() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offeredTotal++
+}
+
+// In records a frame taken from the input queue with the remaining queue depth
+func (p *ProcessTracker) In(queueDepth int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inTotal++
+	p.setQueueDepth(queueDepth)
+}
+
+func (p *ProcessTracker) setQueueDepth(queueDepth int) {
+	p.queueDepth = queueDepth
+	if queueDepth > p.maxQueueDepth {
+		p.maxQueueDepth = queueDepth
+	}
+}
+
+// Out records a processed frame which started processing at start
+func (p *ProcessTracker) Out(start time.Time) {
+	latency := time.Since(start)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outTotal++
+	if len(p.latencies) < maxLatencySamples {
+		p.latencies = append(p.latencies, latency)
+	} else {
+		p.latencies[p.latencyIndex] = latency
+	}
+	p.latencyIndex = (p.latencyIndex + 1) % maxLatencySamples
+}
+
+// Skip records a frame passed through without processing
+func (p *ProcessTracker) Skip() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outTotal++
+	p.skippedTotal++
+}
+
+// Drop records frames dropped by the process
+func (p *ProcessTracker) Drop(count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.droppedTotal += count
+}
+
+// Stats returns the current stats
+func (p *ProcessTracker) Stats() ProcessStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queueDepthFunc != nil {
+		p.setQueueDepth(p.queueDepthFunc())
+	}
+	result := ProcessStats{
+		Name:          p.name,
+		InTotal:       p.inTotal,
+		OutTotal:      p.outTotal,
+		SkippedTotal:  p.skippedTotal,
+		DroppedTotal:  p.droppedTotal,
+		QueueDepth:    p.queueDepth,
+		MaxQueueDepth: p.maxQueueDepth,
+	}
+	if p.offeredTotal > 0 {
+		result.InTotal = p.offeredTotal
+		if implied := p.offeredTotal - p.inTotal - p.queueDepth; implied > 0 {
+			result.DroppedTotal += implied
+		}
+	}
+	if len(p.latencies) > 0 {
+		sorted := make([]time.Duration, len(p.latencies))
+		copy(sorted, p.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		result.LatencyP50Ms = durationToMs(percentile(sorted, 50))
+		result.LatencyP90Ms = durationToMs(percentile(sorted, 90))
+		result.LatencyP99Ms = durationToMs(percentile(sorted, 99))
+		result.LatencyMaxMs = durationToMs(sorted[len(sorted)-1])
+	}
+	return result
+}
+
+// percentile returns the nearest rank percentile of sorted durations
+func percentile(sorted []time.Duration, percent int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (percent*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 0)
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		name     string
+		sorted   []time.Duration
+		percent  int
+		expected time.Duration
+	}{
+		{name: "empty", sorted: nil, percent: 50, expected: 0},
+		{name: "p50", sorted: sorted, percent: 50, expected: 5 * time.Millisecond},
+		{name: "p90", sorted: sorted, percent: 90, expected: 9 * time.Millisecond},
+		{name: "p99", sorted: sorted, percent: 99, expected: 10 * time.Millisecond},
+		{name: "p0", sorted: sorted, percent: 0, expected: 1 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := percentile(tt.sorted, tt.percent); result != tt.expected {
+				t.Errorf("percentile() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessTrackerDrops(t *testing.T) {
+	p := NewProcessTracker("test")
+	queued := 1
+	p.SetQueueDepthFunc(func() int { return queued })
+	for i := 0; i < 5; i++ {
+		p.Offer()
+	}
+	p.In(2)
+	p.Out(time.Now())
+	p.Drop(1)
+	stats := p.Stats()
+	if stats.InTotal != 5 {
+		t.Errorf("InTotal = %d, expected 5", stats.InTotal)
+	}
+	if stats.OutTotal != 1 {
+		t.Errorf("OutTotal = %d, expected 1", stats.OutTotal)
+	}
+	// 5 offered - 1 taken - 1 queued = 3 implied plus 1 explicit
+	if stats.DroppedTotal != 4 {
+		t.Errorf("DroppedTotal = %d, expected 4", stats.DroppedTotal)
+	}
+	if stats.QueueDepth != 1 || stats.MaxQueueDepth != 2 {
+		t.Errorf("QueueDepth = %d MaxQueueDepth = %d, expected 1 and 2", stats.QueueDepth, stats.MaxQueueDepth)
+	}
+}
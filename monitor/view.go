@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"image"
+
+	"github.com/jonoton/go-videosource"
+	"gocv.io/x/gocv"
+)
+
+// View contains the digital crop, rotation, and scale applied to the source frames
+type View struct {
+	Crop       *ViewCrop `yaml:"crop,omitempty"`
+	Rotate     int       `yaml:"rotate,omitempty"`
+	ScaleWidth int       `yaml:"scaleWidth,omitempty"`
+}
+
+// ViewCrop contains the crop region in source pixels
+type ViewCrop struct {
+	X      int `yaml:"x"`
+	Y      int `yaml:"y"`
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+}
+
+// Apply returns the transformed image and cleans up the source image
+func (v *View) Apply(img videosource.Image) videosource.Image {
+	if v == nil || !img.IsFilled() {
+		return img
+	}
+	result := img
+	if v.Crop != nil {
+		rect := image.Rect(v.Crop.X, v.Crop.Y, v.Crop.X+v.Crop.Width, v.Crop.Y+v.Crop.Height)
+		rect = rect.Intersect(image.Rect(0, 0, result.Width(), result.Height()))
+		if !rect.Empty() {
+			cropped := result.GetRegion(rect)
+			result.Cleanup()
+			result = cropped
+		}
+	}
+	if rotateFlag, ok := viewRotateFlag(v.Rotate); ok {
+		mat := gocv.NewMat()
+		gocv.Rotate(result.SharedMat.Mat, &mat, rotateFlag)
+		rotated := *videosource.NewImage(mat)
+		result.Cleanup()
+		result = rotated
+	}
+	if v.ScaleWidth > 0 && v.ScaleWidth != result.Width() {
+		scaled := result.ScaleToWidth(v.ScaleWidth)
+		result.Cleanup()
+		result = scaled
+	}
+	return result
+}
+
+func viewRotateFlag(degrees int) (gocv.RotateFlag, bool) {
+	switch (degrees%360 + 360) % 360 {
+	case 90:
+		return gocv.Rotate90Clockwise, true
+	case 180:
+		return gocv.Rotate180Clockwise, true
+	case 270:
+		return gocv.Rotate90CounterClockwise, true
+	}
+	return 0, false
+}
@@ -0,0 +1,28 @@
+package monitor
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestViewRotateFlag(t *testing.T) {
+	tests := []struct {
+		degrees  int
+		expected gocv.RotateFlag
+		ok       bool
+	}{
+		{degrees: 0, ok: false},
+		{degrees: 90, expected: gocv.Rotate90Clockwise, ok: true},
+		{degrees: 180, expected: gocv.Rotate180Clockwise, ok: true},
+		{degrees: 270, expected: gocv.Rotate90CounterClockwise, ok: true},
+		{degrees: -90, expected: gocv.Rotate90CounterClockwise, ok: true},
+		{degrees: 45, ok: false},
+	}
+	for _, tt := range tests {
+		result, ok := viewRotateFlag(tt.degrees)
+		if ok != tt.ok || (ok && result != tt.expected) {
+			t.Errorf("viewRotateFlag(%d) = %v, %v, expected %v, %v", tt.degrees, result, ok, tt.expected, tt.ok)
+		}
+	}
+}
@@ -124,7 +124,7 @@ func (m *Motion) SetConfig(config *Config) {
 }
 
 // Run starts the motion detection process
-func (m *Motion) Run(input <-chan videosource.Image) <-chan videosource.ProcessedImage {
+func (m *Motion) Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
 	r := make(chan videosource.ProcessedImage)
 	go func() {
 		defer close(r)
@@ -144,13 +144,13 @@ func (m *Motion) Run(input <-chan videosource.Image) <-chan videosource.Processe
 			gridSize = 16
 		}
 
-		for cur := range input {
-			result := *videosource.NewProcessedImage(cur)
+		for result := range input {
 			if m.Skip {
 				r <- result
 				continue
 			}
 
+			cur := result.Original
 			origWidth := cur.Width()
 			scaleWidth := m.scaleWidth
 			if m.scaleWidth <= 0 {
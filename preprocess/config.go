@@ -0,0 +1,56 @@
+package preprocess
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config contains the parameters for Preprocess
+type Config struct {
+	Skip         bool           `yaml:"skip,omitempty"`
+	Rotate       int            `yaml:"rotate,omitempty"`
+	Flip         string         `yaml:"flip,omitempty"`
+	Fisheye      *FisheyeConfig `yaml:"fisheye,omitempty"`
+	Clahe        *ClaheConfig   `yaml:"clahe,omitempty"`
+	Denoise      *DenoiseConfig `yaml:"denoise,omitempty"`
+	AnalysisOnly bool           `yaml:"analysisOnly,omitempty"`
+}
+
+// FisheyeConfig contains the fisheye calibration parameters
+type FisheyeConfig struct {
+	Width        int       `yaml:"width,omitempty"`
+	Height       int       `yaml:"height,omitempty"`
+	CameraMatrix []float64 `yaml:"cameraMatrix"`
+	Distortion   []float64 `yaml:"distortion"`
+	Balance      float64   `yaml:"balance,omitempty"`
+}
+
+// ClaheConfig contains the contrast limited adaptive histogram equalization parameters
+type ClaheConfig struct {
+	ClipLimit    float64 `yaml:"clipLimit,omitempty"`
+	TileGridSize int     `yaml:"tileGridSize,omitempty"`
+}
+
+// DenoiseConfig contains the temporal denoise parameters
+type DenoiseConfig struct {
+	Strength int `yaml:"strength,omitempty"`
+}
+
+// NewConfig creates a new Config
+func NewConfig(configPath string) *Config {
+	c := &Config{}
+	yamlFile, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Printf("yamlFile.Get err   #%v ", err)
+		return nil
+	}
+	err = yaml.Unmarshal(yamlFile, c)
+	if err != nil {
+		log.Printf("Unmarshal: %v", err)
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,287 @@
+package preprocess
+
+import (
+	"image"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/jonoton/go-videosource"
+	"gocv.io/x/gocv"
+)
+
+// Flip Constants
+const (
+	FlipHorizontal = "horizontal"
+	FlipVertical   = "vertical"
+	FlipBoth       = "both"
+)
+
+// Preprocess corrects and enhances images before detection
+type Preprocess struct {
+	Name            string
+	Skip            bool
+	rotate          int
+	flip            string
+	fisheye         *FisheyeConfig
+	claheClipLimit  float64
+	claheTileSize   int
+	clahe           bool
+	denoiseStrength int
+	denoise         bool
+	analysisOnly    bool
+	outputFunc      func(videosource.Image)
+}
+
+// NewPreprocess creates a new Preprocess
+func NewPreprocess(name string) *Preprocess {
+	p := &Preprocess{
+		Name:            name,
+		rotate:          0,
+		flip:            "",
+		fisheye:         nil,
+		claheClipLimit:  2.0,
+		claheTileSize:   8,
+		clahe:           false,
+		denoiseStrength: 50,
+		denoise:         false,
+		analysisOnly:    false,
+		outputFunc:      nil,
+	}
+	return p
+}
+
+// SetConfig on preprocess
+func (p *Preprocess) SetConfig(config *Config) {
+	if config != nil {
+		p.Skip = config.Skip
+		p.rotate = config.Rotate
+		switch config.Flip {
+		case "", FlipHorizontal, FlipVertical, FlipBoth:
+			p.flip = config.Flip
+		default:
+			log.Warnf("Unknown preprocess flip %s for %s", config.Flip, p.Name)
+		}
+		if config.Fisheye != nil {
+			if len(config.Fisheye.CameraMatrix) == 4 && len(config.Fisheye.Distortion) == 4 {
+				p.fisheye = config.Fisheye
+			} else {
+				log.Warnf("Preprocess fisheye for %s needs 4 cameraMatrix and 4 distortion values", p.Name)
+			}
+		}
+		if config.Clahe != nil {
+			p.clahe = true
+			if config.Clahe.ClipLimit > 0 {
+				p.claheClipLimit = config.Clahe.ClipLimit
+			}
+			if config.Clahe.TileGridSize > 0 {
+				p.claheTileSize = config.Clahe.TileGridSize
+			}
+		}
+		if config.Denoise != nil {
+			p.denoise = true
+			if config.Denoise.Strength > 0 {
+				p.denoiseStrength = config.Denoise.Strength
+			}
+			if p.denoiseStrength > 95 {
+				p.denoiseStrength = 95
+			}
+		}
+		p.analysisOnly = config.AnalysisOnly
+	}
+}
+
+// KeepsOutput returns true when the enhanced image is only used for analysis
+func (p *Preprocess) KeepsOutput() bool {
+	return !p.Skip && p.analysisOnly && (p.clahe || p.denoise)
+}
+
+// SetOutputFunc sets the function receiving the unenhanced output image of each frame when KeepsOutput
+func (p *Preprocess) SetOutputFunc(outputFunc func(videosource.Image)) {
+	p.outputFunc = outputFunc
+}
+
+// Run starts the preprocess process
+func (p *Preprocess) Run(input <-chan videosource.ProcessedImage) <-chan videosource.ProcessedImage {
+	r := make(chan videosource.ProcessedImage)
+	go func() {
+		defer close(r)
+		defer func() {
+			// recover from panic if one occurred
+			if recover() != nil {
+				log.Errorln("Recovered from panic in preprocess for", p.Name)
+			}
+		}()
+		var fisheye *fisheyeParams
+		defer func() {
+			if fisheye != nil {
+				fisheye.Close()
+			}
+		}()
+		var clahe *gocv.CLAHE
+		if p.clahe {
+			cur := gocv.NewCLAHEWithParams(p.claheClipLimit, image.Pt(p.claheTileSize, p.claheTileSize))
+			clahe = &cur
+			defer clahe.Close()
+		}
+		denoisePrev := gocv.NewMat()
+		defer denoisePrev.Close()
+
+		for result := range input {
+			if p.Skip || !result.Original.IsFilled() {
+				r <- result
+				continue
+			}
+			img := result.Original
+			if p.fisheye != nil {
+				size := image.Pt(img.Width(), img.Height())
+				if fisheye == nil || fisheye.size != size {
+					if fisheye != nil {
+						fisheye.Close()
+					}
+					fisheye = newFisheyeParams(p.fisheye, size)
+				}
+				img = replaceImage(img, func(src gocv.Mat, dst *gocv.Mat) {
+					gocv.FisheyeUndistortImageWithParams(src, dst, fisheye.k, fisheye.d, fisheye.knew, fisheye.size)
+				})
+			}
+			if rotateFlag, ok := rotateFlag(p.rotate); ok {
+				img = replaceImage(img, func(src gocv.Mat, dst *gocv.Mat) {
+					gocv.Rotate(src, dst, rotateFlag)
+				})
+			}
+			if flipCode, ok := flipCode(p.flip); ok {
+				img = replaceImage(img, func(src gocv.Mat, dst *gocv.Mat) {
+					gocv.Flip(src, dst, flipCode)
+				})
+			}
+			if p.KeepsOutput() && p.outputFunc != nil {
+				p.outputFunc(*img.Ref())
+			}
+			if clahe != nil {
+				img = replaceImage(img, func(src gocv.Mat, dst *gocv.Mat) {
+					applyClahe(clahe, src, dst)
+				})
+			}
+			if p.denoise {
+				img = replaceImage(img, func(src gocv.Mat, dst *gocv.Mat) {
+					applyDenoise(&denoisePrev, p.denoiseStrength, src, dst)
+				})
+			}
+			result.Original = img
+			r <- result
+		}
+	}()
+	return r
+}
+
+// replaceImage returns the result of the operation and cleans up the source image
+func replaceImage(img videosource.Image, operation func(src gocv.Mat, dst *gocv.Mat)) videosource.Image {
+	mat := gocv.NewMat()
+	operation(img.SharedMat.Mat, &mat)
+	if mat.Empty() {
+		mat.Close()
+		return img
+	}
+	result := *videosource.NewImage(mat)
+	img.Cleanup()
+	return result
+}
+
+func rotateFlag(degrees int) (gocv.RotateFlag, bool) {
+	switch (degrees%360 + 360) % 360 {
+	case 90:
+		return gocv.Rotate90Clockwise, true
+	case 180:
+		return gocv.Rotate180Clockwise, true
+	case 270:
+		return gocv.Rotate90CounterClockwise, true
+	}
+	return 0, false
+}
+
+func flipCode(flip string) (int, bool) {
+	switch flip {
+	case FlipHorizontal:
+		return 1, true
+	case FlipVertical:
+		return 0, true
+	case FlipBoth:
+		return -1, true
+	}
+	return 0, false
+}
+
+func applyClahe(clahe *gocv.CLAHE, src gocv.Mat, dst *gocv.Mat) {
+	lab := gocv.NewMat()
+	defer lab.Close()
+	gocv.CvtColor(src, &lab, gocv.ColorBGRToLab)
+	channels := gocv.Split(lab)
+	defer func() {
+		for _, cur := range channels {
+			cur.Close()
+		}
+	}()
+	lightness := gocv.NewMat()
+	clahe.Apply(channels[0], &lightness)
+	channels[0].Close()
+	channels[0] = lightness
+	gocv.Merge(channels, &lab)
+	gocv.CvtColor(lab, dst, gocv.ColorLabToBGR)
+}
+
+// applyDenoise blends the frame with the previous result, where strength is the percent kept from the previous result
+func applyDenoise(prev *gocv.Mat, strength int, src gocv.Mat, dst *gocv.Mat) {
+	if prev.Empty() || prev.Rows() != src.Rows() || prev.Cols() != src.Cols() || prev.Type() != src.Type() {
+		src.CopyTo(dst)
+	} else {
+		previous := float64(strength) / 100
+		gocv.AddWeighted(src, 1-previous, *prev, previous, 0, dst)
+	}
+	dst.CopyTo(prev)
+}
+
+type fisheyeParams struct {
+	size image.Point
+	k    gocv.Mat
+	d    gocv.Mat
+	knew gocv.Mat
+}
+
+// newFisheyeParams creates the camera matrices for the image size, scaling the calibration when sizes differ
+func newFisheyeParams(conf *FisheyeConfig, size image.Point) *fisheyeParams {
+	scaleX, scaleY := 1.0, 1.0
+	if conf.Width > 0 && conf.Height > 0 {
+		scaleX = float64(size.X) / float64(conf.Width)
+		scaleY = float64(size.Y) / float64(conf.Height)
+	}
+	f := &fisheyeParams{
+		size: size,
+		k:    gocv.NewMatWithSize(3, 3, gocv.MatTypeCV64F),
+		d:    gocv.NewMatWithSize(4, 1, gocv.MatTypeCV64F),
+		knew: gocv.NewMat(),
+	}
+	cameraMatrix := [][]float64{
+		{conf.CameraMatrix[0] * scaleX, 0, conf.CameraMatrix[2] * scaleX},
+		{0, conf.CameraMatrix[1] * scaleY, conf.CameraMatrix[3] * scaleY},
+		{0, 0, 1},
+	}
+	for row, values := range cameraMatrix {
+		for col, value := range values {
+			f.k.SetDoubleAt(row, col, value)
+		}
+	}
+	for row, value := range conf.Distortion {
+		f.d.SetDoubleAt(row, 0, value)
+	}
+	rotation := gocv.Eye(3, 3, gocv.MatTypeCV64F)
+	defer rotation.Close()
+	gocv.EstimateNewCameraMatrixForUndistortRectify(f.k, f.d, size, rotation, &f.knew, conf.Balance, size, 1.0)
+	return f
+}
+
+// Close the matrices
+func (f *fisheyeParams) Close() {
+	f.k.Close()
+	f.d.Close()
+	f.knew.Close()
+}
@@ -0,0 +1,49 @@
+package preprocess
+
+import (
+	"testing"
+)
+
+func TestFlipCode(t *testing.T) {
+	tests := []struct {
+		flip     string
+		expected int
+		ok       bool
+	}{
+		{flip: "", ok: false},
+		{flip: FlipHorizontal, expected: 1, ok: true},
+		{flip: FlipVertical, expected: 0, ok: true},
+		{flip: FlipBoth, expected: -1, ok: true},
+		{flip: "sideways", ok: false},
+	}
+	for _, tt := range tests {
+		result, ok := flipCode(tt.flip)
+		if ok != tt.ok || result != tt.expected {
+			t.Errorf("flipCode(%q) = %d, %v, expected %d, %v", tt.flip, result, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestKeepsOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected bool
+	}{
+		{name: "none", config: Config{}, expected: false},
+		{name: "geometric only", config: Config{Rotate: 180, AnalysisOnly: true}, expected: false},
+		{name: "clahe on output", config: Config{Clahe: &ClaheConfig{}}, expected: false},
+		{name: "clahe analysis only", config: Config{Clahe: &ClaheConfig{}, AnalysisOnly: true}, expected: true},
+		{name: "denoise analysis only", config: Config{Denoise: &DenoiseConfig{Strength: 30}, AnalysisOnly: true}, expected: true},
+		{name: "skip", config: Config{Skip: true, Clahe: &ClaheConfig{}, AnalysisOnly: true}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPreprocess("test")
+			p.SetConfig(&tt.config)
+			if result := p.KeepsOutput(); result != tt.expected {
+				t.Errorf("KeepsOutput() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
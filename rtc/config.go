@@ -0,0 +1,14 @@
+package rtc
+
+// Config contains the parameters for the WebRTC streams
+type Config struct {
+	Enabled         bool     `yaml:"enabled,omitempty"`
+	ICEServers      []string `yaml:"iceServers,omitempty"`
+	UDPPortMin      int      `yaml:"udpPortMin,omitempty"`
+	UDPPortMax      int      `yaml:"udpPortMax,omitempty"`
+	PublicIPs       []string `yaml:"publicIPs,omitempty"`
+	IncludeLoopback bool     `yaml:"includeLoopback,omitempty"`
+	Fps             int      `yaml:"fps,omitempty"`
+	Width           int      `yaml:"width,omitempty"`
+	Codec           string   `yaml:"codec,omitempty"`
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package rtc
+
+import (
+	"os"
+	"syscall"
+)
+
+// fifoSupported returns nil when named pipes can be made
+func fifoSupported() error {
+	return nil
+}
+
+func makeFifo(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
+
+// openFifo opens the read end of the named pipe without waiting for a writer, and a write end that
+// holds reads open until it is closed, so the reader neither waits for nor misses the encoder
+func openFifo(path string) (reader *os.File, holder *os.File, err error) {
+	reader, err = os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	holder, err = os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		reader.Close()
+		return nil, nil, err
+	}
+	return reader, holder, nil
+}
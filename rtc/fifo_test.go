@@ -0,0 +1,40 @@
+//go:build !windows
+
+package rtc
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenFifo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.h264")
+	if err := makeFifo(path); err != nil {
+		t.Fatal(err)
+	}
+	reader, holder, err := openFifo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err = holder.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	// closing the only writer ends the reader instead of leaving it waiting
+	holder.Close()
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		done <- data
+	}()
+	select {
+	case data := <-done:
+		if string(data) != "data" {
+			t.Errorf("openFifo() read %q, expected %q", data, "data")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("openFifo() reader did not end after the writer closed")
+	}
+}
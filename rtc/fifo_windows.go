@@ -0,0 +1,23 @@
+//go:build windows
+
+package rtc
+
+import (
+	"errors"
+	"os"
+)
+
+var errFifo = errors.New("named pipes are not supported")
+
+// fifoSupported returns nil when named pipes can be made
+func fifoSupported() error {
+	return errFifo
+}
+
+func makeFifo(path string) error {
+	return errFifo
+}
+
+func openFifo(path string) (reader *os.File, holder *os.File, err error) {
+	return nil, nil, errFifo
+}
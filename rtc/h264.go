@@ -0,0 +1,143 @@
+package rtc
+
+import (
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/h264reader"
+	log "github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+)
+
+// Encoder Errors
+var (
+	ErrEncoderOpen = errors.New("could not open encoder")
+	ErrEncoderSize = errors.New("frame size changed")
+)
+
+const encoderSampleBuffer = 30
+
+var annexBStartCode = []byte{0, 0, 0, 1}
+
+// H264Encoder encodes frames to H.264 access units.
+// OpenCV writes the raw stream into a named pipe that is read back as samples.
+type H264Encoder struct {
+	dir     string
+	path    string
+	fps     int
+	codec   string
+	writer  *gocv.VideoWriter
+	size    image.Point
+	samples chan media.Sample
+	reading bool
+	readWg  sync.WaitGroup
+}
+
+// NewH264Encoder creates a new H264Encoder
+func NewH264Encoder(fps int, codec string) (*H264Encoder, error) {
+	dir, err := os.MkdirTemp("", "scout-rtc-")
+	if err != nil {
+		return nil, err
+	}
+	e := &H264Encoder{
+		dir:     dir,
+		path:    filepath.Join(dir, "stream.h264"),
+		fps:     fps,
+		codec:   codec,
+		samples: make(chan media.Sample, encoderSampleBuffer),
+	}
+	if err = makeFifo(e.path); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return e, nil
+}
+
+// Samples returns the encoded access units and is closed when the encoder is closed.
+// It must be read until closed as the encoder waits for the reader.
+func (e *H264Encoder) Samples() <-chan media.Sample {
+	return e.samples
+}
+
+// Write encodes the frame, where all frames must have the size of the first frame
+func (e *H264Encoder) Write(mat gocv.Mat) error {
+	size := image.Pt(mat.Cols(), mat.Rows())
+	if e.writer == nil {
+		if e.reading {
+			return ErrEncoderOpen
+		}
+		reader, holder, err := openFifo(e.path)
+		if err != nil {
+			log.Errorln("WebRTC could not open encoder pipe", err)
+			return ErrEncoderOpen
+		}
+		e.reading = true
+		e.readWg.Add(1)
+		go e.read(reader)
+		writer, err := gocv.VideoWriterFile(e.path, e.codec, float64(e.fps), size.X, size.Y, true)
+		// the reader now ends with the encoder, or right away when it did not open
+		holder.Close()
+		if err != nil || !writer.IsOpened() {
+			if writer != nil {
+				writer.Close()
+			}
+			e.readWg.Wait()
+			return ErrEncoderOpen
+		}
+		e.writer = writer
+		e.size = size
+	}
+	if size != e.size {
+		return ErrEncoderSize
+	}
+	return e.writer.Write(mat)
+}
+
+func (e *H264Encoder) read(f *os.File) {
+	defer e.readWg.Done()
+	defer close(e.samples)
+	defer f.Close()
+	readAccessUnits(f, time.Second/time.Duration(e.fps), e.samples)
+}
+
+// Close the encoder
+func (e *H264Encoder) Close() {
+	if e.writer != nil {
+		e.writer.Close()
+		e.writer = nil
+	}
+	if e.reading {
+		e.readWg.Wait()
+	} else {
+		close(e.samples)
+	}
+	os.RemoveAll(e.dir)
+}
+
+// readAccessUnits sends the Annex B NAL units of each picture as one sample until the stream ends
+func readAccessUnits(in io.Reader, duration time.Duration, out chan<- media.Sample) {
+	reader, err := h264reader.NewReader(in)
+	if err != nil {
+		return
+	}
+	data := make([]byte, 0)
+	for {
+		nal, err := reader.NextNAL()
+		if err != nil || nal == nil {
+			return
+		}
+		data = append(data, annexBStartCode...)
+		data = append(data, nal.Data...)
+		if nal.UnitType != h264reader.NalUnitTypeCodedSliceIdr && nal.UnitType != h264reader.NalUnitTypeCodedSliceNonIdr {
+			continue
+		}
+		out <- media.Sample{Data: data, Duration: duration}
+		data = make([]byte, 0, len(data))
+	}
+}
@@ -0,0 +1,177 @@
+package rtc
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rtc Errors
+var (
+	ErrGatherTimeout = errors.New("ice gathering timeout")
+)
+
+const gatherTimeout = 5 * time.Second
+
+// Rtc answers WebRTC offers with a H.264 video track
+type Rtc struct {
+	api        *webrtc.API
+	iceServers []webrtc.ICEServer
+	Fps        int
+	Width      int
+	Codec      string
+}
+
+// NewRtc creates a new Rtc, or returns nil when the platform cannot encode streams
+func NewRtc(conf *Config) *Rtc {
+	if err := fifoSupported(); err != nil {
+		log.Warnln("WebRTC disabled,", err)
+		return nil
+	}
+	r := &Rtc{
+		iceServers: make([]webrtc.ICEServer, 0),
+		Fps:        10,
+		Width:      640,
+		Codec:      "avc1",
+	}
+	settings := webrtc.SettingEngine{}
+	if conf != nil {
+		if len(conf.ICEServers) > 0 {
+			r.iceServers = append(r.iceServers, webrtc.ICEServer{URLs: conf.ICEServers})
+		}
+		if conf.UDPPortMin > 0 && conf.UDPPortMax >= conf.UDPPortMin {
+			if err := settings.SetEphemeralUDPPortRange(uint16(conf.UDPPortMin), uint16(conf.UDPPortMax)); err != nil {
+				log.Warnln("WebRTC invalid UDP port range", err)
+			}
+		}
+		if len(conf.PublicIPs) > 0 {
+			settings.SetNAT1To1IPs(conf.PublicIPs, webrtc.ICECandidateTypeHost)
+		}
+		if conf.IncludeLoopback {
+			settings.SetIncludeLoopbackCandidate(true)
+			settings.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+		}
+		if conf.Fps > 0 {
+			r.Fps = conf.Fps
+		}
+		if conf.Width > 0 {
+			r.Width = conf.Width
+		}
+		if len(conf.Codec) == 4 {
+			r.Codec = conf.Codec
+		}
+	}
+	r.api = webrtc.NewAPI(webrtc.WithSettingEngine(settings))
+	return r
+}
+
+// Answer creates a peer sending video to the offer
+func (r *Rtc) Answer(offer webrtc.SessionDescription) (*Peer, error) {
+	pc, err := r.api.NewPeerConnection(webrtc.Configuration{ICEServers: r.iceServers})
+	if err != nil {
+		return nil, err
+	}
+	p := newPeer(pc)
+	fail := func(err error) (*Peer, error) {
+		p.Close()
+		return nil, err
+	}
+	p.track, err = webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264}, "video", "scout")
+	if err != nil {
+		return fail(err)
+	}
+	sender, err := pc.AddTrack(p.track)
+	if err != nil {
+		return fail(err)
+	}
+	go func() {
+		// read to process RTCP
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	if err = pc.SetRemoteDescription(offer); err != nil {
+		return fail(err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return fail(err)
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err = pc.SetLocalDescription(answer); err != nil {
+		return fail(err)
+	}
+	select {
+	case <-gatherComplete:
+	case <-time.After(gatherTimeout):
+		return fail(ErrGatherTimeout)
+	}
+	return p, nil
+}
+
+// Peer is a WebRTC connection receiving the video track
+type Peer struct {
+	pc        *webrtc.PeerConnection
+	track     *webrtc.TrackLocalStaticSample
+	connected chan struct{}
+	done      chan struct{}
+	connOnce  sync.Once
+	doneOnce  sync.Once
+}
+
+func newPeer(pc *webrtc.PeerConnection) *Peer {
+	p := &Peer{
+		pc:        pc,
+		connected: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			p.connOnce.Do(func() {
+				close(p.connected)
+			})
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateDisconnected, webrtc.PeerConnectionStateClosed:
+			p.doneOnce.Do(func() {
+				close(p.done)
+			})
+		}
+	})
+	return p
+}
+
+// LocalDescription returns the answer with all ICE candidates
+func (p *Peer) LocalDescription() *webrtc.SessionDescription {
+	return p.pc.LocalDescription()
+}
+
+// Connected is closed once the connection is established
+func (p *Peer) Connected() <-chan struct{} {
+	return p.connected
+}
+
+// Done is closed when the connection ends
+func (p *Peer) Done() <-chan struct{} {
+	return p.done
+}
+
+// WriteSample sends a H.264 access unit
+func (p *Peer) WriteSample(sample media.Sample) error {
+	return p.track.WriteSample(sample)
+}
+
+// Close the connection
+func (p *Peer) Close() {
+	p.pc.Close()
+	p.doneOnce.Do(func() {
+		close(p.done)
+	})
+}
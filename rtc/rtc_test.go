@@ -0,0 +1,104 @@
+package rtc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+func TestReadAccessUnits(t *testing.T) {
+	stream := []byte{
+		0, 0, 0, 1, 0x67, 0x42, 0x00, 0x1f, // SPS
+		0, 0, 0, 1, 0x68, 0xce, 0x3c, 0x80, // PPS
+		0, 0, 0, 1, 0x65, 0x88, 0x84, 0x00, // IDR slice
+		0, 0, 0, 1, 0x41, 0x9a, 0x02, 0x03, // non-IDR slice
+		0, 0, 0, 1, 0x41, 0x9a, 0x04, 0x05, // non-IDR slice
+	}
+	out := make(chan media.Sample, 10)
+	readAccessUnits(bytes.NewReader(stream), 100*time.Millisecond, out)
+	close(out)
+	samples := make([]media.Sample, 0)
+	for cur := range out {
+		samples = append(samples, cur)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("readAccessUnits() samples = %d, expected 3", len(samples))
+	}
+	if !bytes.Equal(samples[0].Data, stream[:24]) {
+		t.Errorf("readAccessUnits() first sample = %v, expected SPS, PPS, and IDR %v", samples[0].Data, stream[:24])
+	}
+	if !bytes.Equal(samples[1].Data, stream[24:32]) {
+		t.Errorf("readAccessUnits() second sample = %v, expected %v", samples[1].Data, stream[24:32])
+	}
+	for _, cur := range samples {
+		if cur.Duration != 100*time.Millisecond {
+			t.Errorf("readAccessUnits() duration = %v, expected 100ms", cur.Duration)
+		}
+	}
+}
+
+func TestLoopbackPeer(t *testing.T) {
+	r := NewRtc(&Config{Enabled: true, IncludeLoopback: true})
+	viewer, err := r.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewer.Close()
+	if _, err = viewer.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo,
+		webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 1)
+	viewer.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		select {
+		case received <- track.Codec().MimeType:
+		default:
+		}
+	})
+	offer, err := viewer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(viewer)
+	if err = viewer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gatherComplete
+
+	peer, err := r.Answer(*viewer.LocalDescription())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	if err = viewer.SetRemoteDescription(*peer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-peer.Connected():
+	case <-time.After(10 * time.Second):
+		t.Fatal("peer did not connect")
+	}
+	sample := media.Sample{Data: []byte{0, 0, 0, 1, 0x65, 0x88, 0x84, 0x00}, Duration: 100 * time.Millisecond}
+	sendTick := time.NewTicker(50 * time.Millisecond)
+	defer sendTick.Stop()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case mimeType := <-received:
+			if mimeType != webrtc.MimeTypeH264 {
+				t.Errorf("track codec = %s, expected %s", mimeType, webrtc.MimeTypeH264)
+			}
+			return
+		case <-sendTick.C:
+			if err := peer.WriteSample(sample); err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			t.Fatal("no track received")
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package seal
+
+// Config contains the parameters for encryption at rest.
+// One of KeyFile or Passphrase is the key.
+type Config struct {
+	KeyFile    string `yaml:"keyFile,omitempty"`
+	Passphrase string `yaml:"passphrase,omitempty"`
+}
@@ -0,0 +1,191 @@
+package seal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// TmpExt is added to a file while it is sealed or decrypted
+const TmpExt = ".seal.tmp"
+
+// IsSealedFile returns whether the file is sealed
+func IsSealedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	start := make([]byte, len(Magic))
+	if _, err = io.ReadFull(f, start); err != nil {
+		return false
+	}
+	return IsSealed(start)
+}
+
+// SealFile replaces the file with its sealed copy and keeps its modified time.
+// A file that is already sealed is left as is, and a file that changed while sealing, such as one still being written, is left with ErrChanged.
+func (k *Key) SealFile(path string) error {
+	if IsSealedFile(path) {
+		return nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	return writeReplace(path, info, func(dst io.Writer) error {
+		sw, err := k.NewWriter(dst)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(sw, src); err != nil {
+			return err
+		}
+		after, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime()) {
+			return ErrChanged
+		}
+		return sw.Close()
+	})
+}
+
+// OpenFile opens the sealed file for reading its plaintext
+func (k *Key) OpenFile(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sr, err := k.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return sr, nil
+}
+
+// ReadFile returns the plaintext of the file, which is read as is when it is not sealed.
+// The key may be nil for files that are not sealed.
+func ReadFile(key *Key, path string) ([]byte, error) {
+	if !IsSealedFile(path) {
+		return os.ReadFile(path)
+	}
+	if key == nil {
+		return nil, ErrSealed
+	}
+	sr, err := key.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer sr.Close()
+	return io.ReadAll(io.NewSectionReader(sr, 0, sr.Size()))
+}
+
+// DecryptFile writes the plaintext of the sealed file to dst with the same modified time
+func (k *Key) DecryptFile(src string, dst string) error {
+	sr, err := k.OpenFile(src)
+	if err != nil {
+		return err
+	}
+	defer sr.Close()
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return writeReplace(dst, info, func(w io.Writer) error {
+		_, err := io.Copy(w, io.NewSectionReader(sr, 0, sr.Size()))
+		return err
+	})
+}
+
+// DecryptPath decrypts a sealed file, or every file in a directory, to dst and returns how many were written.
+// Files that are not sealed are copied as they are.
+func (k *Key) DecryptPath(src string, dst string) (written int, err error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		if err = k.decryptOrCopy(src, dst); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	err = filepath.WalkDir(src, func(cur string, entry os.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+		rel, err := filepath.Rel(src, cur)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(dst, rel)
+		if err = os.MkdirAll(filepath.Dir(out), os.ModePerm); err != nil {
+			return err
+		}
+		if err = k.decryptOrCopy(cur, out); err != nil {
+			return fmt.Errorf("%s: %w", cur, err)
+		}
+		written++
+		return nil
+	})
+	return
+}
+
+func (k *Key) decryptOrCopy(src string, dst string) error {
+	if IsSealedFile(src) {
+		return k.DecryptFile(src, dst)
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeReplace(dst, info, func(w io.Writer) error {
+		_, err := io.Copy(w, f)
+		return err
+	})
+}
+
+// writeReplace writes a temporary file next to path and renames it over path
+func writeReplace(path string, info os.FileInfo, write func(w io.Writer) error) error {
+	tmpPath := filepath.Clean(path) + TmpExt
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
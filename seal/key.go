@@ -0,0 +1,157 @@
+// seal package
+
+package seal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeySize is the size in bytes of a key in a key file
+const KeySize = 32
+
+const (
+	kdfKey        byte = 0
+	kdfPassphrase byte = 1
+	saltSize           = 16
+	fileKeyInfo        = "scout seal v1"
+)
+
+// Key Errors
+var (
+	ErrNoKey    = errors.New("encryption needs one of keyFile or passphrase")
+	ErrManyKeys = errors.New("encryption has both keyFile and passphrase")
+	ErrKeyFile  = fmt.Errorf("key file must hold %d bytes or %d hex characters", KeySize, KeySize*2)
+	ErrWrongKey = errors.New("file was sealed with a key file and a passphrase was given, or the reverse")
+	ErrChanged  = errors.New("file changed while it was sealed")
+	ErrSealed   = errors.New("file is sealed and there is no key")
+)
+
+// Key seals and opens files with a key from a key file or a passphrase
+type Key struct {
+	kdf     byte
+	secret  []byte
+	salt    []byte
+	mu      sync.Mutex
+	masters map[string][]byte
+}
+
+// LoadKey returns the configured key or nil when not configured
+func LoadKey(conf *Config) (*Key, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	switch {
+	case conf.KeyFile != "" && conf.Passphrase != "":
+		return nil, ErrManyKeys
+	case conf.KeyFile != "":
+		return ReadKeyFile(conf.KeyFile)
+	case conf.Passphrase != "":
+		return NewPassphraseKey(conf.Passphrase)
+	default:
+		return nil, ErrNoKey
+	}
+}
+
+// NewKey returns a Key of KeySize raw bytes
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KeySize {
+		return nil, ErrKeyFile
+	}
+	return &Key{
+		kdf:     kdfKey,
+		secret:  append([]byte{}, raw...),
+		salt:    make([]byte, saltSize),
+		masters: make(map[string][]byte),
+	}, nil
+}
+
+// NewPassphraseKey returns a Key stretched from the passphrase
+func NewPassphraseKey(passphrase string) (*Key, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &Key{
+		kdf:     kdfPassphrase,
+		secret:  []byte(passphrase),
+		salt:    salt,
+		masters: make(map[string][]byte),
+	}, nil
+}
+
+// ReadKeyFile returns the Key in the file of raw or hex bytes
+func ReadKeyFile(keyPath string) (*Key, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := strings.TrimSpace(string(data)); len(trimmed) == KeySize*2 {
+		if raw, err := hex.DecodeString(trimmed); err == nil {
+			return NewKey(raw)
+		}
+	}
+	return NewKey(data)
+}
+
+// GenerateKeyFile writes a new random key in hex to a file that does not exist yet
+func GenerateKeyFile(keyPath string) error {
+	raw := make([]byte, KeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(hex.EncodeToString(raw) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// master returns the secret of a file sealed with the kdf and salt.
+// Passphrases are stretched once per salt.
+func (k *Key) master(kdf byte, salt []byte) ([]byte, error) {
+	if kdf != k.kdf {
+		return nil, ErrWrongKey
+	}
+	if kdf == kdfKey {
+		return k.secret, nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if master, found := k.masters[string(salt)]; found {
+		return master, nil
+	}
+	master, err := scrypt.Key(k.secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	k.masters[string(salt)] = master
+	return master, nil
+}
+
+// fileKey returns the key of one file
+func (k *Key) fileKey(h *header) ([]byte, error) {
+	master, err := k.master(h.kdf, h.kdfSalt)
+	if err != nil {
+		return nil, err
+	}
+	fileKey := make([]byte, 32)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, master, h.fileSalt, []byte(fileKeyInfo)), fileKey); err != nil {
+		return nil, err
+	}
+	return fileKey, nil
+}
@@ -0,0 +1,208 @@
+package seal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) *Key {
+	t.Helper()
+	raw := make([]byte, KeySize)
+	rand.Read(raw)
+	k, err := NewKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func sealBytes(t *testing.T, k *Key, plain []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	sw, err := k.NewWriter(&sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// uneven writes cross chunk boundaries
+	for len(plain) > 0 {
+		n := min(len(plain), 1000)
+		sw.Write(plain[:n])
+		plain = plain[n:]
+	}
+	if err = sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	k := testKey(t)
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		sealed := sealBytes(t, k, plain)
+		if !IsSealed(sealed) {
+			t.Fatalf("IsSealed() = false, expected true for size %d", size)
+		}
+		sr, err := k.NewReader(bytes.NewReader(sealed), int64(len(sealed)))
+		if err != nil {
+			t.Fatalf("NewReader() = %v for size %d", err, size)
+		}
+		if sr.Size() != int64(size) {
+			t.Errorf("Size() = %d, expected %d", sr.Size(), size)
+		}
+		read, err := io.ReadAll(io.NewSectionReader(sr, 0, sr.Size()))
+		if err != nil || !bytes.Equal(read, plain) {
+			t.Errorf("ReadAll() = %v, expected the plaintext of size %d", err, size)
+		}
+		if size > ChunkSize+50 {
+			part := make([]byte, 100)
+			off := int64(ChunkSize - 50)
+			if n, err := sr.ReadAt(part, off); n != 100 || err != nil || !bytes.Equal(part, plain[off:off+100]) {
+				t.Errorf("ReadAt() = %d %v, expected the range across chunks", n, err)
+			}
+		}
+	}
+}
+
+func TestSealDamaged(t *testing.T) {
+	k := testKey(t)
+	plain := make([]byte, 2*ChunkSize+10)
+	sealed := sealBytes(t, k, plain)
+
+	truncated := sealed[:HeaderSize+ChunkSize+tagSize]
+	sr, err := k.NewReader(bytes.NewReader(truncated), int64(len(truncated)))
+	if err == nil {
+		_, err = io.ReadAll(io.NewSectionReader(sr, 0, sr.Size()))
+	}
+	if !errors.Is(err, ErrDamaged) {
+		t.Errorf("truncated = %v, expected %v", err, ErrDamaged)
+	}
+
+	flipped := append([]byte{}, sealed...)
+	flipped[HeaderSize+10] ^= 1
+	sr, _ = k.NewReader(bytes.NewReader(flipped), int64(len(flipped)))
+	if _, err = sr.ReadAt(make([]byte, 10), 0); !errors.Is(err, ErrDamaged) {
+		t.Errorf("flipped = %v, expected %v", err, ErrDamaged)
+	}
+
+	sr, _ = testKey(t).NewReader(bytes.NewReader(sealed), int64(len(sealed)))
+	if _, err = sr.ReadAt(make([]byte, 10), 0); !errors.Is(err, ErrDamaged) {
+		t.Errorf("wrong key = %v, expected %v", err, ErrDamaged)
+	}
+
+	if _, err = k.NewReader(bytes.NewReader(plain), int64(len(plain))); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("plain = %v, expected %v", err, ErrNotSealed)
+	}
+}
+
+func TestSealPassphrase(t *testing.T) {
+	k, err := NewPassphraseKey("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := sealBytes(t, k, []byte("footage"))
+	// a restarted Scout stretches the passphrase with a new salt but still opens old files
+	restarted, _ := NewPassphraseKey("correct horse")
+	sr, err := restarted.NewReader(bytes.NewReader(sealed), int64(len(sealed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _ := io.ReadAll(io.NewSectionReader(sr, 0, sr.Size()))
+	if string(read) != "footage" {
+		t.Errorf("ReadAll() = %q, expected %q", read, "footage")
+	}
+	if _, err = testKey(t).NewReader(bytes.NewReader(sealed), int64(len(sealed))); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("NewReader() = %v, expected %v", err, ErrWrongKey)
+	}
+}
+
+func TestSealFile(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "scout.key")
+	if err := GenerateKeyFile(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKeyFile(keyPath); err == nil {
+		t.Error("GenerateKeyFile() expected an error for an existing file")
+	}
+	k, err := LoadKey(&Config{KeyFile: keyPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "cam1.jpg")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.WriteFile(path, []byte("image"), 0644)
+	os.Chtimes(path, modTime, modTime)
+
+	if err = k.SealFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = k.SealFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); !IsSealedFile(path) || !info.ModTime().Equal(modTime) {
+		t.Errorf("SealFile() expected the file sealed with its modified time")
+	}
+	out := filepath.Join(dir, "out.jpg")
+	if err = k.DecryptFile(path, out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "image" {
+		t.Errorf("DecryptFile() = %q, expected %q", data, "image")
+	}
+
+	os.WriteFile(filepath.Join(dir, "cam1.json"), []byte("{}"), 0644)
+	exported := filepath.Join(t.TempDir(), "export")
+	written, err := k.DecryptPath(dir, exported)
+	if err != nil || written != 4 {
+		t.Fatalf("DecryptPath() = %d %v, expected 4 files", written, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(exported, "cam1.jpg")); string(data) != "image" {
+		t.Errorf("DecryptPath() = %q, expected %q", data, "image")
+	}
+	if data, _ := os.ReadFile(filepath.Join(exported, "cam1.json")); string(data) != "{}" {
+		t.Errorf("DecryptPath() = %q, expected the plain file copied", data)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	k := testKey(t)
+	plain := filepath.Join(dir, "cam1.json")
+	sealed := filepath.Join(dir, "cam1_sealed.json")
+	os.WriteFile(plain, []byte("{}"), 0644)
+	os.WriteFile(sealed, []byte(`{"Monitor":"cam1"}`), 0644)
+	if err := k.SealFile(sealed); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		key      *Key
+		path     string
+		expected string
+		err      error
+	}{
+		{name: "plain without key", key: nil, path: plain, expected: "{}"},
+		{name: "plain with key", key: k, path: plain, expected: "{}"},
+		{name: "sealed with key", key: k, path: sealed, expected: `{"Monitor":"cam1"}`},
+		{name: "sealed without key", key: nil, path: sealed, err: ErrSealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ReadFile(tt.key, tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReadFile() = %v, expected %v", err, tt.err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("ReadFile() = %q, expected %q", data, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Sealed files start with a header followed by chunks of ChunkSize bytes,
+// each encrypted with AES-256-GCM under a key of the file and a nonce of its index.
+// The last chunk is marked in its nonce so a truncated file fails to open.
+const (
+	Magic      = "SCOUTENC"
+	version    = 1
+	HeaderSize = len(Magic) + 2 + saltSize*2
+	ChunkSize  = 64 * 1024
+	tagSize    = 16
+	nonceSize  = 12
+)
+
+// Stream Errors
+var (
+	ErrNotSealed   = errors.New("not a sealed file")
+	ErrVersion     = errors.New("unsupported sealed file version")
+	ErrDamaged     = errors.New("sealed file is damaged or the key is wrong")
+	ErrWriteClosed = errors.New("write after close")
+)
+
+type header struct {
+	kdf      byte
+	kdfSalt  []byte
+	fileSalt []byte
+	raw      []byte
+}
+
+func parseHeader(raw []byte) (*header, error) {
+	if len(raw) < HeaderSize || string(raw[:len(Magic)]) != Magic {
+		return nil, ErrNotSealed
+	}
+	if raw[len(Magic)] != version {
+		return nil, ErrVersion
+	}
+	saltStart := len(Magic) + 2
+	return &header{
+		kdf:      raw[len(Magic)+1],
+		kdfSalt:  raw[saltStart : saltStart+saltSize],
+		fileSalt: raw[saltStart+saltSize : HeaderSize],
+		raw:      raw[:HeaderSize],
+	}, nil
+}
+
+// IsSealed returns whether the data starts like a sealed file
+func IsSealed(start []byte) bool {
+	return bytes.HasPrefix(start, []byte(Magic))
+}
+
+func newAEAD(fileKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(index int64, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	if last {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+// Writer seals what is written to it
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	index  int64
+	closed bool
+}
+
+// NewWriter writes the header of a new sealed file to w.
+// Close must be called to write the last chunk.
+func (k *Key) NewWriter(w io.Writer) (*Writer, error) {
+	raw := make([]byte, HeaderSize)
+	copy(raw, Magic)
+	raw[len(Magic)] = version
+	raw[len(Magic)+1] = k.kdf
+	copy(raw[len(Magic)+2:], k.salt)
+	if _, err := rand.Read(raw[len(Magic)+2+saltSize:]); err != nil {
+		return nil, err
+	}
+	h, _ := parseHeader(raw)
+	fileKey, err := k.fileKey(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(raw); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: raw,
+		buf:    make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+func (sw *Writer) Write(p []byte) (n int, err error) {
+	if sw.closed {
+		return 0, ErrWriteClosed
+	}
+	for len(p) > 0 {
+		// a full chunk is only written once more follows so the last chunk is never empty
+		if len(sw.buf) == ChunkSize {
+			if err = sw.flush(false); err != nil {
+				return
+			}
+		}
+		copied := copy(sw.buf[len(sw.buf):ChunkSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+copied]
+		p = p[copied:]
+		n += copied
+	}
+	return
+}
+
+func (sw *Writer) flush(last bool) error {
+	sealed := sw.aead.Seal(nil, chunkNonce(sw.index, last), sw.buf, sw.header)
+	if _, err := sw.w.Write(sealed); err != nil {
+		return err
+	}
+	sw.index++
+	sw.buf = sw.buf[:0]
+	return nil
+}
+
+// Close writes the last chunk without closing the underlying writer
+func (sw *Writer) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	return sw.flush(true)
+}
+
+// Reader opens a sealed file for reading at any offset
+type Reader struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	header []byte
+	size   int64
+	chunks int64
+	cached int64
+	plain  []byte
+}
+
+// NewReader opens the sealed file of size bytes in r
+func (k *Key) NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	raw := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(raw, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNotSealed
+		}
+		return nil, err
+	}
+	h, err := parseHeader(raw)
+	if err != nil {
+		return nil, err
+	}
+	payload := size - int64(HeaderSize)
+	chunks := (payload + ChunkSize + tagSize - 1) / (ChunkSize + tagSize)
+	if chunks == 0 || payload-(chunks-1)*(ChunkSize+tagSize) < tagSize {
+		return nil, ErrDamaged
+	}
+	fileKey, err := k.fileKey(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:      r,
+		aead:   aead,
+		header: raw,
+		size:   payload - chunks*tagSize,
+		chunks: chunks,
+		cached: -1,
+	}, nil
+}
+
+// Size returns the size of the plaintext
+func (sr *Reader) Size() int64 {
+	return sr.size
+}
+
+func (sr *Reader) chunk(index int64) ([]byte, error) {
+	if index == sr.cached {
+		return sr.plain, nil
+	}
+	offset := int64(HeaderSize) + index*(ChunkSize+tagSize)
+	length := int64(ChunkSize + tagSize)
+	last := index == sr.chunks-1
+	if last {
+		length = sr.size - index*ChunkSize + tagSize
+	}
+	sealed := make([]byte, length)
+	if _, err := sr.r.ReadAt(sealed, offset); err != nil && !(errors.Is(err, io.EOF) && last) {
+		return nil, err
+	}
+	plain, err := sr.aead.Open(sealed[:0], chunkNonce(index, last), sealed, sr.header)
+	if err != nil {
+		return nil, ErrDamaged
+	}
+	sr.cached = index
+	sr.plain = plain
+	return plain, nil
+}
+
+// ReadAt reads the plaintext at offset
+func (sr *Reader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	for n < len(p) {
+		if off >= sr.size {
+			return n, io.EOF
+		}
+		plain, chunkErr := sr.chunk(off / ChunkSize)
+		if chunkErr != nil {
+			return n, chunkErr
+		}
+		copied := copy(p[n:], plain[off%ChunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// Close closes the underlying reader when it is a Closer
+func (sr *Reader) Close() error {
+	if closer, ok := sr.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}